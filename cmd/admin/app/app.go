@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/pressly/goose/v3"
+	"github.com/wiredmatt/go_short/internal/config"
+	"github.com/wiredmatt/go_short/internal/storage"
+)
+
+const usage = `Usage: go_short_admin <command> [args]
+
+Offline database operations against the configured DB_CONNECTION_STRING.
+
+Commands:
+  migrate up              Apply all pending migrations
+  migrate down            Roll back the most recent migration
+  migrate to <version>    Migrate up or down to a specific version
+  migrate status          Show applied and pending migrations
+  reset -yes              Roll back every migration (refused when ENVIRONMENT=production)
+`
+
+// ErrProduction is returned when a destructive command is attempted in production
+var ErrProduction = errors.New("refusing to reset the database in production")
+
+// Run executes an admin command and returns the process exit code
+func Run(cfg *config.Config, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	// goose reports progress through its own logger
+	goose.SetLogger(log.New(stdout, "", 0))
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = runMigrate(cfg, args[1:])
+	case "reset":
+		err = runReset(cfg, args[1:], stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command: %s\n\n%s", args[0], usage)
+		return 2
+	}
+
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "%v\n\n%s", err, usage)
+		return 2
+	default:
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+}
+
+// usageError marks errors caused by invalid arguments
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return usageError{"migrate requires a subcommand"}
+	}
+
+	if err := requirePostgres(cfg); err != nil {
+		return err
+	}
+	conn := cfg.Database.ConnectionString
+
+	switch args[0] {
+	case "up":
+		return storage.MigrateUp(conn)
+	case "down":
+		return storage.MigrateDown(conn)
+	case "to":
+		if len(args) != 2 {
+			return usageError{"migrate to requires a <version> argument"}
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return usageError{fmt.Sprintf("invalid version: %s", args[1])}
+		}
+		return storage.MigrateTo(conn, version)
+	case "status":
+		return storage.MigrationStatus(conn)
+	default:
+		return usageError{fmt.Sprintf("unknown migrate subcommand: %s", args[0])}
+	}
+}
+
+func runReset(cfg *config.Config, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("reset", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	confirm := fs.Bool("yes", false, "confirm that every table should be dropped")
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+
+	if cfg.IsProduction() {
+		return ErrProduction
+	}
+
+	if !*confirm {
+		return usageError{"reset drops every table, pass -yes to confirm"}
+	}
+
+	return storage.ResetStore(context.Background(), cfg.Database)
+}
+
+func requirePostgres(cfg *config.Config) error {
+	if cfg.Database.Type != "postgres" {
+		return fmt.Errorf("migrations are only supported for postgres, DB_TYPE is %q", cfg.Database.Type)
+	}
+	return nil
+}
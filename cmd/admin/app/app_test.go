@@ -0,0 +1,80 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/config"
+)
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg, err := config.LoadForTest()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Database.Type = "memory"
+	cfg.App.Environment = "development"
+	return cfg
+}
+
+func run(cfg *config.Config, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(cfg, args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	cfg := testConfig(t)
+
+	code, _, stderr := run(cfg)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage")
+
+	code, _, stderr = run(cfg, "frobnicate")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "unknown command")
+
+	code, _, stderr = run(cfg, "migrate")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "migrate requires a subcommand")
+}
+
+func TestRun_MigrateRequiresPostgres(t *testing.T) {
+	cfg := testConfig(t)
+
+	code, _, stderr := run(cfg, "migrate", "up")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "only supported for postgres")
+}
+
+func TestRun_MigrateToInvalidVersion(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Database.Type = "postgres"
+
+	code, _, stderr := run(cfg, "migrate", "to", "latest")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "invalid version")
+}
+
+func TestRun_ResetRefusedInProduction(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.App.Environment = "production"
+
+	code, _, stderr := run(cfg, "reset", "-yes")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, ErrProduction.Error())
+}
+
+func TestRun_ResetRequiresConfirmation(t *testing.T) {
+	cfg := testConfig(t)
+
+	code, _, stderr := run(cfg, "reset")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "pass -yes to confirm")
+
+	code, _, stderr = run(cfg, "reset", "-yes")
+	assert.Equal(t, 0, code, stderr)
+}
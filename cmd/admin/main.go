@@ -0,0 +1,18 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/wiredmatt/go_short/cmd/admin/app"
+	"github.com/wiredmatt/go_short/internal/config"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	os.Exit(app.Run(cfg, os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -2,28 +2,90 @@ package app
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 
 	"github.com/wiredmatt/go_short/internal/api"
 	"github.com/wiredmatt/go_short/internal/config"
+	"github.com/wiredmatt/go_short/internal/health"
+	"github.com/wiredmatt/go_short/internal/logging"
+	"github.com/wiredmatt/go_short/internal/metadata"
+	"github.com/wiredmatt/go_short/internal/metrics"
+	"github.com/wiredmatt/go_short/internal/model"
 	"github.com/wiredmatt/go_short/internal/shortener"
 	"github.com/wiredmatt/go_short/internal/storage"
+	"github.com/wiredmatt/go_short/internal/telemetry"
 )
 
 type App struct {
-	Cfg    *config.Config
-	Store  storage.Store
-	Server *http.Server
+	Cfg             *config.Config
+	Logger          *logging.Logger
+	Store           storage.Store
+	Health          *health.Checker
+	Lifecycle       *Lifecycle
+	Server          *http.Server
+	ShutdownTracing telemetry.ShutdownFunc
 }
 
 func NewApp(ctx context.Context, cfg *config.Config) (*App, error) {
-	store, err := storage.NewStore(ctx, cfg.Database)
+	logger, err := logging.Open(cfg.Logging)
 	if err != nil {
 		return nil, err
 	}
+	// Route the standard log package and slog's default through the same sink
+	slog.SetDefault(logger.Logger)
 
-	shortService := shortener.NewService(store, cfg.App.BaseURL, cfg.App.ShortCodeLength)
-	router := api.NewRouter(shortService)
+	shutdownTracing, err := telemetry.Setup(ctx, cfg.Telemetry)
+	if err != nil {
+		logger.Close()
+		return nil, err
+	}
+
+	store, err := storage.NewStore(ctx, cfg.Database, logger.Logger)
+	if err != nil {
+		shutdownTracing(ctx)
+		logger.Close()
+		return nil, err
+	}
+
+	metrics.SetActiveLinksSource(store.CountActive)
+
+	lifecycle := NewLifecycle(logger.Logger)
+
+	serviceOptions := []shortener.Option{
+		shortener.WithLogger(logger.Logger),
+		shortener.WithRunner(lifecycle),
+		shortener.WithDefaultRedirectType(cfg.App.DefaultRedirectType),
+		shortener.WithPasswordAttempts(cfg.App.PasswordMaxAttempts, cfg.App.PasswordAttemptWindow),
+		shortener.WithQuotas(model.Limits{
+			ActiveLinks:      cfg.App.QuotaActiveLinks,
+			MonthlyRedirects: cfg.App.QuotaMonthlyRedirects,
+		}),
+	}
+	if cfg.App.MetadataFetch {
+		fetcher := metadata.NewFetcher(cfg.App.MetadataFetchTimeout, int64(cfg.App.MetadataMaxBytes))
+		serviceOptions = append(serviceOptions, shortener.WithMetadataFetcher(fetcher))
+	}
+	shortService := shortener.NewService(store, cfg.App.BaseURL, cfg.App.ShortCodeLength, serviceOptions...)
+	checker := health.NewChecker(health.Check{
+		Name:    "storage",
+		Timeout: cfg.Server.HealthCheckTimeout,
+		Func:    store.Ping,
+	})
+
+	trustedProxies, err := cfg.App.TrustedProxyPrefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	router := api.NewRouter(shortService,
+		api.WithHealth(checker),
+		api.WithLogger(logger.Logger),
+		api.WithAdmin(cfg.App.AdminToken, logger.Level),
+		api.WithRedirectCacheMaxAge(cfg.App.RedirectCacheMaxAge),
+		api.WithPendingLinkURL(cfg.App.PendingLinkURL),
+		api.WithTrustedProxies(trustedProxies),
+	)
 
 	server := &http.Server{
 		Addr:         cfg.GetServerAddress(),
@@ -34,8 +96,12 @@ func NewApp(ctx context.Context, cfg *config.Config) (*App, error) {
 	}
 
 	return &App{
-		Cfg:    cfg,
-		Store:  store,
-		Server: server,
+		Cfg:             cfg,
+		Logger:          logger,
+		Store:           store,
+		Health:          checker,
+		Lifecycle:       lifecycle,
+		Server:          server,
+		ShutdownTracing: shutdownTracing,
 	}, nil
 }
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Lifecycle owns the background work started while serving requests so that
+// shutdown can wait for it instead of abandoning it
+type Lifecycle struct {
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	draining bool
+	inFlight map[string]int
+	rejected map[string]int
+}
+
+func NewLifecycle(logger *slog.Logger) *Lifecycle {
+	return &Lifecycle{
+		logger:   logger,
+		inFlight: make(map[string]int),
+		rejected: make(map[string]int),
+	}
+}
+
+// Go runs fn in the background unless the lifecycle is draining, in which
+// case the task is dropped and reported by Drain
+func (l *Lifecycle) Go(task string, fn func()) {
+	l.mu.Lock()
+	if l.draining {
+		l.rejected[task]++
+		l.mu.Unlock()
+		return
+	}
+	l.inFlight[task]++
+	l.wg.Add(1)
+	l.mu.Unlock()
+
+	go func() {
+		defer l.done(task)
+		fn()
+	}()
+}
+
+func (l *Lifecycle) done(task string) {
+	l.mu.Lock()
+	l.inFlight[task]--
+	if l.inFlight[task] == 0 {
+		delete(l.inFlight, task)
+	}
+	l.mu.Unlock()
+	l.wg.Done()
+}
+
+// DrainReport lists the background tasks that did not run to completion, by
+// task name
+type DrainReport struct {
+	// Abandoned tasks were still running when the drain timed out
+	Abandoned map[string]int
+	// Rejected tasks were submitted after draining started
+	Rejected map[string]int
+}
+
+// Dropped returns the number of tasks that did not complete
+func (r DrainReport) Dropped() int {
+	n := 0
+	for _, count := range r.Abandoned {
+		n += count
+	}
+	for _, count := range r.Rejected {
+		n += count
+	}
+	return n
+}
+
+// Drain stops accepting new tasks and waits for in-flight ones until ctx is
+// done
+func (l *Lifecycle) Drain(ctx context.Context) DrainReport {
+	l.mu.Lock()
+	l.draining = true
+	l.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return DrainReport{
+		Abandoned: copyCounts(l.inFlight),
+		Rejected:  copyCounts(l.rejected),
+	}
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	out := make(map[string]int, len(counts))
+	for task, n := range counts {
+		out[task] = n
+	}
+	return out
+}
+
+// Shutdown stops the app in dependency order: readiness is failed first so
+// load balancers stop routing, then the server stops accepting requests, the
+// background work started by those requests is drained, and only then are the
+// store, tracing and log sinks closed. ctx bounds the whole sequence.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+	logger := a.Logger.Logger
+
+	a.Health.SetShuttingDown()
+	if delay := a.Cfg.Server.ShutdownDrainDelay; delay > 0 {
+		logger.Info("Waiting for load balancers to stop routing", slog.Duration("delay", delay))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	if err := a.Server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("server shutdown: %w", err))
+	}
+
+	report := a.Lifecycle.Drain(ctx)
+	if dropped := report.Dropped(); dropped > 0 {
+		logger.Warn("Background tasks dropped during shutdown",
+			slog.Int("dropped", dropped),
+			slog.Any("abandoned", report.Abandoned),
+			slog.Any("rejected", report.Rejected),
+		)
+	} else {
+		logger.Info("Background tasks drained")
+	}
+
+	// Abandoned tasks may still be using the store, but the process is about
+	// to exit and they have been reported
+	a.Store.Close()
+
+	// Flush spans still buffered by the exporter. Draining may have used up
+	// ctx, so the flush gets its own deadline.
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.ShutdownTracing(flushCtx); err != nil {
+		errs = append(errs, fmt.Errorf("flush traces: %w", err))
+	}
+
+	if len(errs) > 0 {
+		logger.Error("Shutdown finished with errors", slog.String("error", errors.Join(errs...).Error()))
+	} else {
+		logger.Info("Shutdown complete")
+	}
+
+	if err := a.Logger.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close log file: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
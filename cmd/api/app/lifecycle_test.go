@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/config"
+	"github.com/wiredmatt/go_short/internal/storage"
+)
+
+func newTestLifecycle() *Lifecycle {
+	return NewLifecycle(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestLifecycle_DrainWaitsForTasks(t *testing.T) {
+	lifecycle := newTestLifecycle()
+
+	var completed atomic.Int32
+	for i := 0; i < 10; i++ {
+		lifecycle.Go("increment_click_count", func() {
+			time.Sleep(10 * time.Millisecond)
+			completed.Add(1)
+		})
+	}
+
+	report := lifecycle.Drain(context.Background())
+
+	if completed.Load() != 10 {
+		t.Errorf("expected 10 completed tasks, got %d", completed.Load())
+	}
+	if report.Dropped() != 0 {
+		t.Errorf("expected no dropped tasks, got %+v", report)
+	}
+}
+
+func TestLifecycle_DrainTimeoutReportsAbandoned(t *testing.T) {
+	lifecycle := newTestLifecycle()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	lifecycle.Go("increment_click_count", func() { <-release })
+	lifecycle.Go("increment_click_count", func() { <-release })
+	lifecycle.Go("quick", func() {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report := lifecycle.Drain(ctx)
+
+	if report.Abandoned["increment_click_count"] != 2 {
+		t.Errorf("expected 2 abandoned click increments, got %+v", report.Abandoned)
+	}
+	if _, ok := report.Abandoned["quick"]; ok {
+		t.Errorf("expected finished task not to be reported, got %+v", report.Abandoned)
+	}
+	if report.Dropped() != 2 {
+		t.Errorf("expected 2 dropped tasks, got %d", report.Dropped())
+	}
+}
+
+func TestLifecycle_RejectsTasksAfterDrain(t *testing.T) {
+	lifecycle := newTestLifecycle()
+	lifecycle.Drain(context.Background())
+
+	ran := make(chan struct{}, 1)
+	lifecycle.Go("increment_click_count", func() { ran <- struct{}{} })
+
+	select {
+	case <-ran:
+		t.Fatal("expected task submitted after drain not to run")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	report := lifecycle.Drain(context.Background())
+	if report.Rejected["increment_click_count"] != 1 {
+		t.Errorf("expected 1 rejected task, got %+v", report.Rejected)
+	}
+}
+
+// closeRecordingStore records whether the app closed it
+type closeRecordingStore struct {
+	storage.Store
+	closed atomic.Bool
+}
+
+func (s *closeRecordingStore) Close() {
+	s.closed.Store(true)
+	s.Store.Close()
+}
+
+func TestApp_ShutdownDrainsAndClosesStore(t *testing.T) {
+	cfg, err := config.LoadForTest()
+	if err != nil {
+		panic(err)
+	}
+
+	app, err := NewApp(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	store := &closeRecordingStore{Store: app.Store}
+	app.Store = store
+
+	var completed atomic.Bool
+	app.Lifecycle.Go("increment_click_count", func() {
+		time.Sleep(20 * time.Millisecond)
+		completed.Store(true)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := app.Shutdown(ctx); err != nil {
+		t.Fatalf("expected clean shutdown, got %v", err)
+	}
+
+	if !completed.Load() {
+		t.Error("expected background task to finish before shutdown returned")
+	}
+	if !store.closed.Load() {
+		t.Error("expected store to be closed")
+	}
+	if !app.Health.ShuttingDown() {
+		t.Error("expected readiness to report shutting down")
+	}
+}
+
+func TestApp_ShutdownFlushesTracesAfterDeadline(t *testing.T) {
+	cfg, err := config.LoadForTest()
+	if err != nil {
+		panic(err)
+	}
+	cfg.Server.ShutdownDrainDelay = 0
+
+	app, err := NewApp(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var flushErr error
+	app.ShutdownTracing = func(ctx context.Context) error {
+		flushErr = ctx.Err()
+		return nil
+	}
+
+	// Draining used up the whole deadline
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	app.Shutdown(ctx)
+
+	if flushErr != nil {
+		t.Errorf("expected traces to be flushed with a live context, got %v", flushErr)
+	}
+}
@@ -19,10 +19,11 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Bounds initialization only, shutdown gets its own deadline
+	startCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	app, err := app.NewApp(ctx, cfg)
+	app, err := app.NewApp(startCtx, cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize app: %v", err)
 	}
@@ -43,10 +44,16 @@ func main() {
 
 	log.Println("Shutting down server...")
 
-	// Attempt graceful shutdown
-	if err := app.Server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	// A zero SHUTDOWN_TIMEOUT waits for everything to finish
+	shutdownCtx, cancelShutdown := context.WithCancel(context.Background())
+	if cfg.Server.ShutdownTimeout > 0 {
+		shutdownCtx, cancelShutdown = context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	}
+	defer cancelShutdown()
 
-	log.Println("Server exited")
+	if err := app.Shutdown(shutdownCtx); err != nil {
+		// The logger is closed by now, so report on stderr
+		log.SetOutput(os.Stderr)
+		log.Fatalf("Server forced to shutdown: %v", err)
+	}
 }
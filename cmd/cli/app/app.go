@@ -0,0 +1,191 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `Usage: go_short [flags] <command> [flags] [args]
+
+Commands:
+  shorten <url>    Create a short URL
+  resolve <code>   Show the URL a code redirects to
+  list             List the user's mappings
+  delete <code>    Delete one of the user's mappings
+  stats <code>     Show click statistics for a mapping
+
+Flags (may be given before or after the command, but before its arguments):
+`
+
+// Options holds the settings shared by every command
+type Options struct {
+	BaseURL string
+	APIKey  string
+	UserID  string
+	Output  string
+}
+
+// command runs a single subcommand against the API
+type command struct {
+	args    int
+	argName string
+	needsID bool
+	run     func(c *Client, opts Options, args []string, w io.Writer) error
+}
+
+var commands = map[string]command{
+	"shorten": {args: 1, argName: "url", needsID: true, run: runShorten},
+	"resolve": {args: 1, argName: "code", run: runResolve},
+	"list":    {args: 0, needsID: true, run: runList},
+	"delete":  {args: 1, argName: "code", needsID: true, run: runDelete},
+	"stats":   {args: 1, argName: "code", needsID: true, run: runStats},
+}
+
+// Run executes the CLI with the given arguments and returns the process exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	opts := Options{
+		BaseURL: getEnv("GO_SHORT_URL", "http://localhost:4000"),
+		APIKey:  os.Getenv("GO_SHORT_API_KEY"),
+		UserID:  os.Getenv("GO_SHORT_USER_ID"),
+		Output:  getEnv("GO_SHORT_OUTPUT", FormatTable),
+	}
+
+	global := newFlagSet("go_short", &opts, stderr)
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if global.NArg() == 0 {
+		global.Usage()
+		return 2
+	}
+
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command: %s\n\n", name)
+		global.Usage()
+		return 2
+	}
+
+	local := newFlagSet(name, &opts, stderr)
+	if err := local.Parse(global.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if local.NArg() != cmd.args {
+		if cmd.args == 0 {
+			fmt.Fprintf(stderr, "%s takes no arguments\n", name)
+		} else {
+			fmt.Fprintf(stderr, "%s requires exactly one <%s> argument\n", name, cmd.argName)
+		}
+		return 2
+	}
+
+	if !validFormat(opts.Output) {
+		fmt.Fprintf(stderr, "invalid output format %q (expected table, json or csv)\n", opts.Output)
+		return 2
+	}
+
+	if cmd.needsID && opts.UserID == "" {
+		fmt.Fprintf(stderr, "%s requires a user ID (-user or GO_SHORT_USER_ID)\n", name)
+		return 2
+	}
+
+	client := NewClient(opts.BaseURL, opts.APIKey)
+	if err := cmd.run(client, opts, local.Args(), stdout); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func newFlagSet(name string, opts *Options, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.BaseURL, "url", opts.BaseURL, "base URL of the go_short API (env GO_SHORT_URL)")
+	fs.StringVar(&opts.APIKey, "api-key", opts.APIKey, "API key sent as X-API-Key (env GO_SHORT_API_KEY)")
+	fs.StringVar(&opts.UserID, "user", opts.UserID, "user ID that owns the mappings (env GO_SHORT_USER_ID)")
+	fs.StringVar(&opts.Output, "o", opts.Output, "output format: table, json or csv (env GO_SHORT_OUTPUT)")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runShorten(c *Client, opts Options, args []string, w io.Writer) error {
+	shortURL, err := c.Shorten(opts.UserID, args[0])
+	if err != nil {
+		return err
+	}
+
+	value := map[string]string{"short_url": shortURL, "original_url": args[0]}
+	return render(w, opts.Output, value, table{
+		headers: []string{"SHORT_URL", "ORIGINAL_URL"},
+		rows:    [][]string{{shortURL, args[0]}},
+	})
+}
+
+func runResolve(c *Client, opts Options, args []string, w io.Writer) error {
+	location, err := c.Resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	value := map[string]string{"code": args[0], "original_url": location}
+	return render(w, opts.Output, value, table{
+		headers: []string{"CODE", "ORIGINAL_URL"},
+		rows:    [][]string{{args[0], location}},
+	})
+}
+
+func runList(c *Client, opts Options, _ []string, w io.Writer) error {
+	mappings, err := c.List(opts.UserID)
+	if err != nil {
+		return err
+	}
+
+	if mappings == nil {
+		mappings = []Mapping{}
+	}
+	return render(w, opts.Output, mappings, mappingsTable(mappings))
+}
+
+func runDelete(c *Client, opts Options, args []string, w io.Writer) error {
+	if err := c.Delete(opts.UserID, args[0]); err != nil {
+		return err
+	}
+
+	value := map[string]string{"code": args[0], "status": "deleted"}
+	return render(w, opts.Output, value, table{
+		headers: []string{"CODE", "STATUS"},
+		rows:    [][]string{{args[0], "deleted"}},
+	})
+}
+
+func runStats(c *Client, opts Options, args []string, w io.Writer) error {
+	mapping, err := c.Stats(opts.UserID, args[0])
+	if err != nil {
+		return err
+	}
+
+	return render(w, opts.Output, mapping, mappingsTable([]Mapping{*mapping}))
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/api"
+	"github.com/wiredmatt/go_short/internal/shortener"
+	"github.com/wiredmatt/go_short/internal/storage"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	service := shortener.NewService(storage.NewMemoryStore(), "http://"+server.Listener.Addr().String(), 6)
+	server.Config.Handler = api.NewRouter(service)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := Run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func shortenForTest(t *testing.T, serverURL, userID, url string) string {
+	t.Helper()
+
+	code, out, errOut := run(t, "-url", serverURL, "-user", userID, "-o", "json", "shorten", url)
+	require.Equal(t, 0, code, errOut)
+
+	var res map[string]string
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+
+	parts := strings.Split(res["short_url"], "/")
+	return parts[len(parts)-1]
+}
+
+func TestRun_ShortenAndResolve(t *testing.T) {
+	server := newTestServer(t)
+
+	code := shortenForTest(t, server.URL, "user1", "https://example.com/cli")
+	assert.Len(t, code, 6)
+
+	exit, out, errOut := run(t, "-url", server.URL, "resolve", code)
+	assert.Equal(t, 0, exit, errOut)
+	assert.Contains(t, out, "ORIGINAL_URL")
+	assert.Contains(t, out, "https://example.com/cli")
+}
+
+func TestRun_ListFormats(t *testing.T) {
+	server := newTestServer(t)
+
+	code := shortenForTest(t, server.URL, "user1", "https://example.com/list")
+
+	t.Run("table", func(t *testing.T) {
+		exit, out, errOut := run(t, "-url", server.URL, "-user", "user1", "list")
+		assert.Equal(t, 0, exit, errOut)
+		assert.True(t, strings.HasPrefix(out, "CODE"))
+		assert.Contains(t, out, code)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		exit, out, errOut := run(t, "-url", server.URL, "-user", "user1", "list", "-o", "json")
+		assert.Equal(t, 0, exit, errOut)
+
+		var mappings []Mapping
+		require.NoError(t, json.Unmarshal([]byte(out), &mappings))
+		require.Len(t, mappings, 1)
+		assert.Equal(t, code, mappings[0].Code)
+		assert.Equal(t, "https://example.com/list", mappings[0].Original)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		exit, out, errOut := run(t, "-url", server.URL, "-user", "user1", "-o", "csv", "list")
+		assert.Equal(t, 0, exit, errOut)
+
+		records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "CODE", records[0][0])
+		assert.Equal(t, code, records[1][0])
+	})
+}
+
+func TestRun_StatsAndDelete(t *testing.T) {
+	server := newTestServer(t)
+
+	code := shortenForTest(t, server.URL, "user1", "https://example.com/stats")
+
+	exit, out, errOut := run(t, "-url", server.URL, "-user", "user1", "-o", "json", "stats", code)
+	assert.Equal(t, 0, exit, errOut)
+
+	var mapping Mapping
+	require.NoError(t, json.Unmarshal([]byte(out), &mapping))
+	assert.Equal(t, code, mapping.Code)
+	assert.Equal(t, 0, mapping.Clicks)
+
+	// Another user cannot delete the mapping
+	exit, _, errOut = run(t, "-url", server.URL, "-user", "user2", "delete", code)
+	assert.Equal(t, 1, exit)
+	assert.Contains(t, errOut, "403")
+
+	exit, _, errOut = run(t, "-url", server.URL, "-user", "user1", "delete", code)
+	assert.Equal(t, 0, exit, errOut)
+
+	exit, _, errOut = run(t, "-url", server.URL, "resolve", code)
+	assert.Equal(t, 1, exit)
+	assert.Contains(t, errOut, "404")
+}
+
+func TestRun_SendsAPIKey(t *testing.T) {
+	var gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("X-API-Key")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"mappings":[]}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("GO_SHORT_API_KEY", "secret")
+
+	exit, _, errOut := run(t, "-url", server.URL, "-user", "user1", "list")
+	assert.Equal(t, 0, exit, errOut)
+	assert.Equal(t, "secret", gotKey)
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no command", nil, "Usage"},
+		{"unknown command", []string{"frobnicate"}, "unknown command"},
+		{"missing argument", []string{"-user", "u", "shorten"}, "requires exactly one <url> argument"},
+		{"missing user", []string{"list"}, "requires a user ID"},
+		{"bad format", []string{"-user", "u", "-o", "xml", "list"}, "invalid output format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GO_SHORT_USER_ID", "")
+
+			exit, _, errOut := run(t, tt.args...)
+			assert.Equal(t, 2, exit)
+			assert.Contains(t, errOut, tt.want)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Mapping mirrors the URLMappingOutput returned by the API
+type Mapping struct {
+	Code      string  `json:"code"`
+	Original  string  `json:"original_url"`
+	ShortURL  string  `json:"short_url"`
+	CreatedAt string  `json:"created_at"`
+	ExpiresAt *string `json:"expires_at,omitempty"`
+	Clicks    int     `json:"clicks"`
+}
+
+// Client talks to a running go_short API over HTTP
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+			// Resolve needs to read the Location header instead of following it
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Shorten creates a new short URL and returns it
+func (c *Client) Shorten(userID, originalURL string) (string, error) {
+	body := map[string]string{
+		"userId": userID,
+		"url":    originalURL,
+	}
+
+	var res struct {
+		ShortURL string `json:"short_url"`
+	}
+	if err := c.do(http.MethodPost, "/shorten", nil, body, &res); err != nil {
+		return "", err
+	}
+	return res.ShortURL, nil
+}
+
+// Resolve returns the original URL a code redirects to
+func (c *Client) Resolve(code string) (string, error) {
+	res, err := c.send(http.MethodGet, "/"+url.PathEscape(code), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 300 || res.StatusCode >= 400 {
+		return "", decodeError(res)
+	}
+
+	location := res.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("redirect response without Location header")
+	}
+	return location, nil
+}
+
+// List returns all mappings owned by userID
+func (c *Client) List(userID string) ([]Mapping, error) {
+	var res struct {
+		Mappings []Mapping `json:"mappings"`
+	}
+	if err := c.do(http.MethodGet, "/mappings", url.Values{"userId": {userID}}, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Mappings, nil
+}
+
+// Delete removes a mapping owned by userID
+func (c *Client) Delete(userID, code string) error {
+	return c.do(http.MethodDelete, "/mappings/"+url.PathEscape(code), url.Values{"userId": {userID}}, nil, nil)
+}
+
+// Stats returns the mapping details for a code owned by userID
+func (c *Client) Stats(userID, code string) (*Mapping, error) {
+	var res Mapping
+	path := "/mappings/" + url.PathEscape(code) + "/stats"
+	if err := c.do(http.MethodGet, path, url.Values{"userId": {userID}}, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// do sends a request and decodes a successful JSON response into out
+func (c *Client) do(method, path string, query url.Values, body, out any) error {
+	res, err := c.send(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return decodeError(res)
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) send(method, path string, query url.Values, body any) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", c.BaseURL, err)
+	}
+	return res, nil
+}
+
+// decodeError turns a huma error response into a Go error
+func decodeError(res *http.Response) error {
+	var apiErr struct {
+		Title  string `json:"title"`
+		Detail string `json:"detail"`
+	}
+
+	payload, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err := json.Unmarshal(payload, &apiErr); err == nil && (apiErr.Detail != "" || apiErr.Title != "") {
+		msg := apiErr.Detail
+		if msg == "" {
+			msg = apiErr.Title
+		}
+		return fmt.Errorf("api error (%d): %s", res.StatusCode, msg)
+	}
+
+	return fmt.Errorf("api error (%d): %s", res.StatusCode, http.StatusText(res.StatusCode))
+}
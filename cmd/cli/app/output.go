@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// table is the tabular representation of a command result, shared by the
+// table and CSV output modes
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+func validFormat(format string) bool {
+	switch format {
+	case FormatTable, FormatJSON, FormatCSV:
+		return true
+	default:
+		return false
+	}
+}
+
+// render writes value as JSON, or t as a table/CSV, depending on format
+func render(w io.Writer, format string, value any, t table) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(t.headers); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(t.rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.headers, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+func mappingsTable(mappings []Mapping) table {
+	t := table{
+		headers: []string{"CODE", "SHORT_URL", "ORIGINAL_URL", "CLICKS", "CREATED_AT", "EXPIRES_AT"},
+		rows:    make([][]string, 0, len(mappings)),
+	}
+
+	for _, m := range mappings {
+		expiresAt := ""
+		if m.ExpiresAt != nil {
+			expiresAt = *m.ExpiresAt
+		}
+		t.rows = append(t.rows, []string{
+			m.Code,
+			m.ShortURL,
+			m.Original,
+			strconv.Itoa(m.Clicks),
+			m.CreatedAt,
+			expiresAt,
+		})
+	}
+
+	return t
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/wiredmatt/go_short/cmd/cli/app"
+)
+
+func main() {
+	os.Exit(app.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+type notActivePage struct {
+	ActivatesAt time.Time
+}
+
+// notActiveResponse answers a request for a link before its activation time.
+// With a pending link URL configured every client is redirected there;
+// otherwise browsers get a placeholder page and API clients a problem
+// response. Neither may be cached, so the link works as soon as it activates.
+func notActiveResponse(err error, accept, pendingLinkURL string) (*ResolveOutput, error) {
+	if pendingLinkURL != "" {
+		return &ResolveOutput{
+			Location:     pendingLinkURL,
+			CacheControl: "no-store",
+			Status:       http.StatusFound,
+		}, nil
+	}
+
+	if !strings.Contains(accept, "text/html") {
+		return nil, huma.ErrorWithHeaders(huma.NewError(http.StatusNotFound, err.Error()), http.Header{"Cache-Control": {"no-store"}})
+	}
+
+	var notActiveErr *shortener.NotActiveError
+	if !errors.As(err, &notActiveErr) {
+		return nil, huma.NewError(http.StatusNotFound, "not found")
+	}
+
+	body, renderErr := renderPage("not_active.html", notActivePage{ActivatesAt: notActiveErr.ActivatesAt.UTC()})
+	if renderErr != nil {
+		return nil, huma.NewError(http.StatusInternalServerError, renderErr.Error())
+	}
+
+	return &ResolveOutput{
+		ContentType:  "text/html; charset=utf-8",
+		CacheControl: "no-store",
+		Status:       http.StatusNotFound,
+		Body:         body,
+	}, nil
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_ShortenActivatesAt(t *testing.T) {
+	launch := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", mock.MatchedBy(func(req shortener.ShortenRequest) bool {
+		return req.ActivatesAt != nil && req.ActivatesAt.Equal(launch)
+	})).Return("abc123", nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","activates_at":"2030-01-02T15:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_UpdateMappingActivatesAt(t *testing.T) {
+	launch := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("UpdateMapping", "user123", "", "abc123", shortener.MappingUpdate{ActivatesAt: &launch}).
+		Return(&model.URLMapping{Code: "abc123", Original: "https://example.com", UserID: "user123", CreatedAt: time.Now(), ActivatesAt: &launch}, nil)
+	mockService.On("UpdateMapping", "user123", "", "abc123", shortener.MappingUpdate{ActivatesAt: &time.Time{}}).
+		Return(&model.URLMapping{Code: "abc123", Original: "https://example.com", UserID: "user123", CreatedAt: time.Now()}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/mappings/abc123?userId=user123", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := patch(`{"activates_at":"2030-01-02T15:00:00Z"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response URLMappingOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2030-01-02T15:00:00Z", *response.ActivatesAt)
+
+	// An empty string activates the link right away
+	w = patch(`{"activates_at":""}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = URLMappingOutput{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(t, response.ActivatesAt)
+
+	w = patch(`{"activates_at":"tomorrow"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertNumberOfCalls(t, "UpdateMapping", 2)
+}
+
+func TestRouter_UpdateMappingErrors(t *testing.T) {
+	launch := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("UpdateMapping", "user123", "", "abc123", shortener.MappingUpdate{ActivatesAt: &launch}).Return(nil, shortener.ErrInvalidActivation)
+	mockService.On("UpdateMapping", "other", "", "abc123", shortener.MappingUpdate{ActivatesAt: &launch}).Return(nil, shortener.ErrForbidden)
+
+	router := NewRouter(mockService)
+
+	tests := []struct {
+		userID   string
+		expected int
+	}{
+		{"", http.StatusBadRequest},
+		{"user123", http.StatusBadRequest},
+		{"other", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("PATCH", "/mappings/abc123?userId="+tt.userID, bytes.NewBufferString(`{"activates_at":"2030-01-02T15:00:00Z"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.expected, w.Code, tt.userID)
+	}
+}
+
+func TestRouter_ResolveNotActive(t *testing.T) {
+	launch := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "launch", ClientID: testClientID}).Return(nil, &shortener.NotActiveError{ActivatesAt: launch})
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/launch", nil)
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `<time datetime="2030-01-02T15:00:00Z">`)
+
+	req = httptest.NewRequest("GET", "/launch", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), "link is not active yet, activates at 2030-01-02T15:00:00Z")
+}
+
+func TestRouter_ResolveNotActivePendingLinkURL(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "launch", ClientID: testClientID}).Return(nil, &shortener.NotActiveError{ActivatesAt: time.Now().Add(time.Hour)})
+
+	router := NewRouter(mockService, WithPendingLinkURL("https://example.com/coming-soon"))
+
+	req := httptest.NewRequest("GET", "/launch", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/coming-soon", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+}
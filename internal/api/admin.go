@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/logging"
+)
+
+type AdminInput struct {
+	Token string `header:"X-Admin-Token" doc:"Admin token configured with ADMIN_TOKEN"`
+}
+
+type SetLogLevelInput struct {
+	AdminInput
+	Body struct {
+		Level string `json:"level" example:"debug" doc:"One of debug, info, warn, error"`
+	}
+}
+
+type LogLevelOutput struct {
+	Body struct {
+		Level string `json:"level" example:"info"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+// registerAdminRoutes registers the operational endpoints. They are only
+// available when an admin token is configured.
+func registerAdminRoutes(humaAPI huma.API, opts routerOptions) {
+	if opts.adminToken == "" || opts.logLevel == nil {
+		return
+	}
+
+	level := opts.logLevel
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/admin/log-level",
+		Summary: "Get the current log level",
+		Tags:    []string{"admin"},
+	}, func(ctx context.Context, in *AdminInput) (*LogLevelOutput, error) {
+		if err := checkAdminToken(opts.adminToken, in.Token); err != nil {
+			return nil, err
+		}
+		return logLevelOutput(level.Level()), nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodPut,
+		Path:    "/admin/log-level",
+		Summary: "Change the log level",
+		Tags:    []string{"admin"},
+	}, func(ctx context.Context, in *SetLogLevelInput) (*LogLevelOutput, error) {
+		if err := checkAdminToken(opts.adminToken, in.Token); err != nil {
+			return nil, err
+		}
+
+		parsed, err := logging.ParseLevel(in.Body.Level)
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		previous := level.Level()
+		level.Set(parsed)
+		opts.logger.InfoContext(ctx, "log level changed",
+			slog.String("from", previous.String()),
+			slog.String("to", parsed.String()),
+		)
+
+		return logLevelOutput(parsed), nil
+	})
+}
+
+func checkAdminToken(expected, got string) error {
+	if got == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(got)) != 1 {
+		return huma.NewError(http.StatusUnauthorized, "invalid admin token")
+	}
+	return nil
+}
+
+func logLevelOutput(level slog.Level) *LogLevelOutput {
+	out := &LogLevelOutput{Status: http.StatusOK}
+	out.Body.Level = strings.ToLower(level.String())
+	return out
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/logging"
+)
+
+func decodeLevel(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var body struct {
+		Level string `json:"level"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Level
+}
+
+func TestRouter_AdminLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level})))
+
+	router := NewRouter(new(MockShortenerService), WithLogger(logger), WithAdmin("s3cret", level))
+
+	request := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/log-level", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("rejects missing and wrong tokens", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("GET", "", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, request("PUT", "wrong", `{"level":"debug"}`).Code)
+		assert.Equal(t, slog.LevelInfo, level.Level())
+	})
+
+	t.Run("rejects unknown levels", func(t *testing.T) {
+		w := request("PUT", "s3cret", `{"level":"verbose"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, slog.LevelInfo, level.Level())
+	})
+
+	t.Run("changes the level at runtime", func(t *testing.T) {
+		buf.Reset()
+		// Successful requests are logged at debug, hidden at the default level
+		w := request("GET", "s3cret", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "info", decodeLevel(t, w))
+		assert.NotContains(t, buf.String(), "request completed")
+
+		w = request("PUT", "s3cret", `{"level":"debug"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "debug", decodeLevel(t, w))
+		assert.Equal(t, slog.LevelDebug, level.Level())
+
+		buf.Reset()
+		w = request("GET", "s3cret", "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "request completed", line["msg"])
+		assert.Equal(t, "/admin/log-level", line["path"])
+	})
+}
+
+func TestRouter_AdminDisabledWithoutToken(t *testing.T) {
+	router := NewRouter(new(MockShortenerService), WithAdmin("", new(slog.LevelVar)))
+
+	req := httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(`{"level":"debug"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Contains(t, []int{http.StatusNotFound, http.StatusMethodNotAllowed}, w.Code)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+type DomainOutput struct {
+	Name      string `json:"name" example:"go.example.com"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ListDomainsOutput struct {
+	Body struct {
+		Domains []DomainOutput `json:"domains"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+type RegisterDomainInput struct {
+	UserID string `query:"userId"`
+	Body   struct {
+		Name string `json:"name" maxLength:"253" example:"go.example.com" doc:"Host name whose DNS points to this server"`
+	}
+}
+
+type RegisterDomainOutput struct {
+	Body   DomainOutput
+	Status int `json:"status" example:"201"`
+}
+
+type DomainInput struct {
+	Name   string `path:"name"`
+	UserID string `query:"userId"`
+}
+
+type DeleteDomainOutput struct {
+	Status int `json:"status" example:"204"`
+}
+
+func registerDomainRoutes(humaAPI huma.API, service shortener.Shortener) {
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/domains",
+		Summary: "List a user's custom domains",
+	}, func(ctx context.Context, in *UserInput) (*ListDomainsOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		domains, err := service.ListDomains(ctx, in.UserID)
+		if err != nil {
+			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		var out ListDomainsOutput
+		out.Body.Domains = make([]DomainOutput, len(domains))
+		for i, domain := range domains {
+			out.Body.Domains[i] = toDomainOutput(domain)
+		}
+		out.Status = http.StatusOK
+		return &out, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodPost,
+		Path:    "/domains",
+		Summary: "Register a custom domain",
+		Description: "Links can then be created on the domain with the domain field of POST /shorten. " +
+			"They are served on requests whose Host is the domain, so its DNS must point to this server. " +
+			"Returns 409 if the domain is already registered, by any user.",
+		DefaultStatus: http.StatusCreated,
+	}, func(ctx context.Context, in *RegisterDomainInput) (*RegisterDomainOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		domain, err := service.RegisterDomain(ctx, in.UserID, in.Body.Name)
+		if err != nil {
+			return nil, domainError(err)
+		}
+
+		return &RegisterDomainOutput{Body: toDomainOutput(*domain), Status: http.StatusCreated}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:        http.MethodDelete,
+		Path:          "/domains/{name}",
+		Summary:       "Delete a custom domain",
+		Description:   "Returns 409 while the domain still has links; delete them first.",
+		DefaultStatus: http.StatusNoContent,
+	}, func(ctx context.Context, in *DomainInput) (*DeleteDomainOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		if err := service.DeleteDomain(ctx, in.UserID, in.Name); err != nil {
+			return nil, domainError(err)
+		}
+
+		return &DeleteDomainOutput{Status: http.StatusNoContent}, nil
+	})
+}
+
+func toDomainOutput(domain model.Domain) DomainOutput {
+	return DomainOutput{
+		Name:      domain.Name,
+		CreatedAt: domain.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// domainError translates service errors about domains into HTTP errors
+func domainError(err error) error {
+	switch {
+	case errors.Is(err, shortener.ErrInvalidDomain):
+		return huma.NewError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, shortener.ErrDomainNotFound):
+		return huma.NewError(http.StatusNotFound, err.Error())
+	case errors.Is(err, shortener.ErrForbidden):
+		return huma.NewError(http.StatusForbidden, err.Error())
+	case errors.Is(err, shortener.ErrDomainExists), errors.Is(err, shortener.ErrDomainInUse):
+		return huma.NewError(http.StatusConflict, err.Error())
+	default:
+		return huma.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_Domains(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("RegisterDomain", "user123", "go.example.com").Return(&model.Domain{Name: "go.example.com", UserID: "user123", CreatedAt: createdAt}, nil)
+	mockService.On("RegisterDomain", "user123", "taken.example.com").Return(nil, shortener.ErrDomainExists)
+	mockService.On("RegisterDomain", "user123", "localhost").Return(nil, shortener.ErrInvalidDomain)
+	mockService.On("ListDomains", "user123").Return([]model.Domain{{Name: "go.example.com", UserID: "user123", CreatedAt: createdAt}}, nil)
+	mockService.On("DeleteDomain", "user123", "go.example.com").Return(nil)
+	mockService.On("DeleteDomain", "user123", "busy.example.com").Return(shortener.ErrDomainInUse)
+	mockService.On("DeleteDomain", "intruder", "go.example.com").Return(shortener.ErrForbidden)
+
+	router := NewRouter(mockService)
+
+	register := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/domains?userId=user123", bytes.NewBufferString(`{"name":"`+name+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := register("go.example.com")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var domain DomainOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &domain))
+	assert.Equal(t, DomainOutput{Name: "go.example.com", CreatedAt: "2024-03-01T12:00:00Z"}, domain)
+
+	assert.Equal(t, http.StatusConflict, register("taken.example.com").Code)
+	assert.Equal(t, http.StatusBadRequest, register("localhost").Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/domains?userId=user123", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ListDomainsOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list.Body))
+	assert.Equal(t, []DomainOutput{domain}, list.Body.Domains)
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/domains/go.example.com?userId=user123", http.StatusNoContent},
+		{"/domains/busy.example.com?userId=user123", http.StatusConflict},
+		{"/domains/go.example.com?userId=intruder", http.StatusForbidden},
+		{"/domains/go.example.com", http.StatusBadRequest},
+	} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", tt.path, nil))
+		assert.Equal(t, tt.status, w.Code, tt.path)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ShortenOnDomain(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", Domain: "go.example.com"}).Return("abc123", nil)
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", Domain: "missing.example.com"}).Return("", shortener.ErrDomainNotFound)
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", Domain: "other.example.com"}).Return("", shortener.ErrForbidden)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	shorten := func(domain string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","domain":"`+domain+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := shorten("go.example.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ShortenOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response.Body))
+	assert.Equal(t, "https://go.example.com/abc123", response.Body.ShortURL)
+
+	assert.Equal(t, http.StatusBadRequest, shorten("missing.example.com").Code)
+	assert.Equal(t, http.StatusForbidden, shorten("other.example.com").Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ResolveOnDomain(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "abc123", ClientID: testClientID}).
+		Return(&shortener.Resolution{URL: "https://example.com", StatusCode: http.StatusFound}, nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/abc123", nil)
+	req.Host = "go.example.com"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, []string{"go.example.com"}, mockService.resolveHosts)
+}
+
+func TestRouter_MappingOnDomain(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("GetMapping", "user123", "go.example.com", "abc123").
+		Return(&model.URLMapping{Domain: "go.example.com", Code: "abc123", Original: "https://example.com", UserID: "user123", CreatedAt: time.Now()}, nil)
+	mockService.On("DeleteMapping", "user123", "go.example.com", "abc123").Return(nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/mappings/abc123/stats?userId=user123&domain=go.example.com", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response URLMappingOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "go.example.com", response.Domain)
+	assert.Equal(t, "https://go.example.com/abc123", response.ShortURL)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/mappings/abc123?userId=user123&domain=go.example.com", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/health"
+)
+
+func probe(t *testing.T, router http.Handler, path string) (int, health.Report) {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestRouter_Probes(t *testing.T) {
+	var storageErr error
+	checker := health.NewChecker(health.Check{
+		Name: "storage",
+		Func: func(context.Context) error { return storageErr },
+	})
+	router := NewRouter(new(MockShortenerService), WithHealth(checker))
+
+	t.Run("ready when dependencies are up", func(t *testing.T) {
+		code, report := probe(t, router, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusUp, report.Status)
+		assert.Equal(t, health.StatusUp, report.Checks["storage"].Status)
+	})
+
+	t.Run("not ready when storage is down", func(t *testing.T) {
+		storageErr = errors.New("connection refused")
+		defer func() { storageErr = nil }()
+
+		code, report := probe(t, router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, "connection refused", report.Checks["storage"].Error)
+
+		// A dependency outage must not fail liveness
+		code, report = probe(t, router, "/livez")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusUp, report.Status)
+	})
+
+	t.Run("not ready while shutting down", func(t *testing.T) {
+		checker.SetShuttingDown()
+
+		code, report := probe(t, router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusShuttingDown, report.Status)
+
+		code, _ = probe(t, router, "/livez")
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
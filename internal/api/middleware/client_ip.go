@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+type clientIPKey struct{}
+
+// ClientIP returns a middleware that works out the address of the client and
+// sets it in the context. X-Forwarded-For and X-Real-IP are only believed
+// when the request comes from one of the trusted proxies, since anyone else
+// can send them; otherwise the client is the peer of the connection.
+func ClientIP(trusted []netip.Prefix) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		ip := clientIP(ctx, trusted)
+		next(huma.WithContext(ctx, context.WithValue(ctx.Context(), clientIPKey{}, ip)))
+	}
+}
+
+// GetClientIP returns the client address set by ClientIP, or the peer of the
+// connection when the middleware is not in use
+func GetClientIP(ctx huma.Context) string {
+	if ip, ok := ctx.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return peerIP(ctx.RemoteAddr())
+}
+
+func clientIP(ctx huma.Context, trusted []netip.Prefix) string {
+	peer := peerIP(ctx.RemoteAddr())
+	if !isTrusted(peer, trusted) {
+		return peer
+	}
+
+	// Each proxy appends the address it got the request from, so the
+	// client is the rightmost address that is not one of our proxies
+	if forwarded := ctx.Header("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			if !isTrusted(hop, trusted) || i == 0 {
+				return hop
+			}
+		}
+	}
+
+	if realIP := strings.TrimSpace(ctx.Header("X-Real-IP")); realIP != "" {
+		if _, err := netip.ParseAddr(realIP); err == nil {
+			return realIP
+		}
+	}
+	return peer
+}
+
+// peerIP strips the port from a connection's remote address
+func peerIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// isTrusted reports whether ip is within one of the trusted prefixes
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,43 +2,39 @@ package middleware
 
 import (
 	"log/slog"
-	"os"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 )
 
-// actual logger
-var requestLogger *slog.Logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-	Level: slog.LevelInfo,
-}))
+// RequestLogger returns a middleware that logs every request through logger,
+// failed requests at Error and the rest at Debug. The request_id is added
+// from the context by the logger's handler.
+func RequestLogger(logger *slog.Logger) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		start := time.Now()
 
-// middleware
-func RequestLogger(ctx huma.Context, next func(huma.Context)) {
-	start := time.Now()
+		next(ctx)
 
-	next(ctx)
+		duration := time.Since(start)
+		status := ctx.Status()
+		path := ctx.Operation().Path
+		method := ctx.Method()
 
-	duration := time.Since(start)
-	status := ctx.Status()
-	path := ctx.Operation().Path
-	method := ctx.Method()
-
-	if status >= 400 {
-		requestLogger.Error("request failed",
-			slog.String("request_id", GetRequestID(ctx)),
-			slog.String("method", method),
-			slog.String("path", path),
-			slog.Int("status", status),
-			slog.Int64("duration_ms", duration.Milliseconds()),
-		)
-	} else {
-		requestLogger.Debug("request completed",
-			slog.String("request_id", GetRequestID(ctx)),
-			slog.String("method", method),
-			slog.String("path", path),
-			slog.Int("status", status),
-			slog.Int64("duration_ms", duration.Milliseconds()),
-		)
+		if status >= 400 {
+			logger.ErrorContext(ctx.Context(), "request failed",
+				slog.String("method", method),
+				slog.String("path", path),
+				slog.Int("status", status),
+				slog.Int64("duration_ms", duration.Milliseconds()),
+			)
+		} else {
+			logger.DebugContext(ctx.Context(), "request completed",
+				slog.String("method", method),
+				slog.String("path", path),
+				slog.Int("status", status),
+				slog.Int64("duration_ms", duration.Milliseconds()),
+			)
+		}
 	}
 }
@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wiredmatt/go_short/internal/metrics"
 )
 
 var (
@@ -24,6 +26,15 @@ var (
 		[]string{"path", "status"},
 	)
 
+	RequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "go_short_request_duration_seconds",
+			Help:    "Latency of requests processed by the go_short web server, by route and method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"route", "method"},
+	)
+
 	metricsInitialized = false
 )
 
@@ -32,16 +43,14 @@ func PrometheusInit() {
 		return
 	}
 
-	// Try to register the collectors, but don't panic if they're already registered
-	if err := prometheus.Register(RequestCount); err != nil {
-		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
-			panic(err)
-		}
-	}
+	collectors := append([]prometheus.Collector{RequestCount, ErrorCount, RequestDuration}, metrics.Collectors()...)
 
-	if err := prometheus.Register(ErrorCount); err != nil {
-		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
-			panic(err)
+	// Try to register the collectors, but don't panic if they're already registered
+	for _, collector := range collectors {
+		if err := prometheus.Register(collector); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				panic(err)
+			}
 		}
 	}
 
@@ -49,11 +58,14 @@ func PrometheusInit() {
 }
 
 func TrackMetrics(ctx huma.Context, next func(huma.Context)) {
+	start := time.Now()
+
 	next(ctx)
 
 	path := ctx.Operation().Path
 	status := ctx.Status()
 
+	RequestDuration.WithLabelValues(path, ctx.Method()).Observe(time.Since(start).Seconds())
 	RequestCount.WithLabelValues(path, fmt.Sprintf("%d", status)).Inc()
 	if status >= 400 {
 		ErrorCount.WithLabelValues(path, fmt.Sprintf("%d", status)).Inc()
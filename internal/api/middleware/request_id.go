@@ -3,21 +3,49 @@ package middleware
 import (
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/google/uuid"
+	"github.com/wiredmatt/go_short/internal/logging"
 )
 
-type ctxKey string
+const (
+	RequestIDHeader = "X-Request-ID"
 
-const requestIDKey ctxKey = "requestID"
+	// maxRequestIDLength bounds inbound IDs so clients cannot bloat log lines
+	maxRequestIDLength = 128
+)
 
-// Middleware that generates a request ID and sets it in the context + response header
+// Middleware that reuses a valid inbound X-Request-ID or generates a new one,
+// then sets it in the context + response header
 func RequestID(ctx huma.Context, next func(huma.Context)) {
-	reqID := uuid.New().String()
-	ctx.SetHeader("X-Request-ID", reqID)
-	next(ctx)
+	reqID := ctx.Header(RequestIDHeader)
+	if !validRequestID(reqID) {
+		reqID = uuid.New().String()
+	}
+
+	ctx.SetHeader(RequestIDHeader, reqID)
+	next(huma.WithContext(ctx, logging.WithRequestID(ctx.Context(), reqID)))
 }
 
 // Helper function to retrieve request ID from context
 func GetRequestID(ctx huma.Context) string {
-	id, _ := ctx.Context().Value(requestIDKey).(string)
-	return id
+	return logging.RequestID(ctx.Context())
+}
+
+// validRequestID accepts IDs made of letters, digits and the separators
+// commonly used by proxies and tracing systems
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
 }
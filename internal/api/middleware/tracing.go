@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/danielgtaylor/huma/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/wiredmatt/go_short/internal/api"
+
+// headerCarrier adapts huma request headers to the OpenTelemetry propagation API
+type headerCarrier struct {
+	ctx huma.Context
+}
+
+func (c headerCarrier) Get(key string) string {
+	return c.ctx.Header(key)
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.ctx.SetHeader(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+	c.ctx.EachHeader(func(name, _ string) {
+		keys = append(keys, name)
+	})
+	return keys
+}
+
+// Middleware that starts a server span per request, continuing any trace
+// received through the W3C traceparent header
+func Tracing(ctx huma.Context, next func(huma.Context)) {
+	parent := otel.GetTextMapPropagator().Extract(ctx.Context(), headerCarrier{ctx})
+
+	route := ctx.Operation().Path
+	method := ctx.Method()
+	url := ctx.URL()
+
+	spanCtx, span := otel.Tracer(tracerName).Start(parent, fmt.Sprintf("%s %s", method, route),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(url.Path),
+		),
+	)
+	defer span.End()
+
+	next(huma.WithContext(ctx, spanCtx))
+
+	status := ctx.Status()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+	}
+}
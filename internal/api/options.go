@@ -0,0 +1,89 @@
+package api
+
+import (
+	"log/slog"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/health"
+	"github.com/wiredmatt/go_short/internal/logging"
+)
+
+// routerOptions holds the dependencies of NewRouter that have defaults
+type routerOptions struct {
+	logger              *slog.Logger
+	logLevel            *slog.LevelVar
+	adminToken          string
+	health              *health.Checker
+	redirectCacheMaxAge time.Duration
+	pendingLinkURL      string
+	trustedProxies      []netip.Prefix
+}
+
+// defaultRedirectCacheMaxAge is how long permanent redirects may be cached
+// when WithRedirectCacheMaxAge is not used
+const defaultRedirectCacheMaxAge = 24 * time.Hour
+
+// Option customizes the router built by NewRouter
+type Option func(*routerOptions)
+
+// WithLogger sets the logger used by the request logging middleware
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *routerOptions) {
+		o.logger = logger
+	}
+}
+
+// WithAdmin enables the /admin endpoints, authenticated with token. level is
+// the level of the process logger, adjustable through /admin/log-level.
+func WithAdmin(token string, level *slog.LevelVar) Option {
+	return func(o *routerOptions) {
+		o.adminToken = token
+		o.logLevel = level
+	}
+}
+
+// WithHealth sets the checker behind /livez and /readyz. Without it readiness
+// only reflects that the process is up.
+func WithHealth(checker *health.Checker) Option {
+	return func(o *routerOptions) {
+		o.health = checker
+	}
+}
+
+// WithRedirectCacheMaxAge sets how long clients may cache permanent redirects
+func WithRedirectCacheMaxAge(maxAge time.Duration) Option {
+	return func(o *routerOptions) {
+		o.redirectCacheMaxAge = maxAge
+	}
+}
+
+// WithPendingLinkURL redirects links that are not active yet to url instead
+// of serving the built-in placeholder
+func WithPendingLinkURL(url string) Option {
+	return func(o *routerOptions) {
+		o.pendingLinkURL = url
+	}
+}
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For and X-Real-IP
+// headers tell the client address. Without it, the client is the peer of
+// the connection.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(o *routerOptions) {
+		o.trustedProxies = prefixes
+	}
+}
+
+func newRouterOptions(opts []Option) routerOptions {
+	o := routerOptions{
+		logger:              logging.New(os.Stdout, slog.LevelInfo),
+		health:              health.NewChecker(),
+		redirectCacheMaxAge: defaultRedirectCacheMaxAge,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+type OrganizationOutput struct {
+	ID        string `json:"id" example:"org_Xy12Ab34Cd56"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+type CreateOrganizationInput struct {
+	UserID string `query:"userId"`
+	Body   struct {
+		Name string `json:"name" maxLength:"100"`
+	}
+}
+
+type CreateOrganizationOutput struct {
+	Body   OrganizationOutput
+	Status int `json:"status" example:"201"`
+}
+
+// MemberOutput is a membership, listed either by organization or by user
+type MemberOutput struct {
+	OrgID    string `json:"org_id"`
+	UserID   string `json:"user_id"`
+	Role     string `json:"role" enum:"owner,admin,editor,viewer"`
+	JoinedAt string `json:"joined_at"`
+}
+
+type ListOrganizationsOutput struct {
+	Body struct {
+		Organizations []MemberOutput `json:"organizations"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+type OrganizationInput struct {
+	OrgID  string `path:"orgId"`
+	UserID string `query:"userId"`
+}
+
+type ListMembersOutput struct {
+	Body struct {
+		Members []MemberOutput `json:"members"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+type MemberInput struct {
+	OrganizationInput
+	MemberID string `path:"memberId"`
+}
+
+type SetMemberInput struct {
+	MemberInput
+	Body struct {
+		Role string `json:"role" enum:"owner,admin,editor,viewer"`
+	}
+}
+
+type SetMemberOutput struct {
+	Body   MemberOutput
+	Status int `json:"status" example:"200"`
+}
+
+type RemoveMemberOutput struct {
+	Status int `json:"status" example:"204"`
+}
+
+func registerOrganizationRoutes(humaAPI huma.API, service shortener.Shortener) {
+	huma.Register(humaAPI, huma.Operation{
+		Method:        http.MethodPost,
+		Path:          "/orgs",
+		Summary:       "Create an organization",
+		Description:   "The user becomes its owner. Links are created for it with the org_id field of POST /shorten.",
+		DefaultStatus: http.StatusCreated,
+	}, func(ctx context.Context, in *CreateOrganizationInput) (*CreateOrganizationOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		org, err := service.CreateOrganization(ctx, in.UserID, in.Body.Name)
+		if err != nil {
+			return nil, organizationError(err)
+		}
+
+		return &CreateOrganizationOutput{
+			Body: OrganizationOutput{
+				ID:        org.ID,
+				Name:      org.Name,
+				CreatedAt: org.CreatedAt.Format(time.RFC3339),
+			},
+			Status: http.StatusCreated,
+		}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/orgs",
+		Summary: "List the organizations a user belongs to",
+	}, func(ctx context.Context, in *UserInput) (*ListOrganizationsOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		memberships, err := service.ListOrganizations(ctx, in.UserID)
+		if err != nil {
+			return nil, organizationError(err)
+		}
+
+		var out ListOrganizationsOutput
+		out.Body.Organizations = toMemberOutputs(memberships)
+		out.Status = http.StatusOK
+		return &out, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/orgs/{orgId}/members",
+		Summary:     "List the members of an organization",
+		Description: "Available to every member.",
+	}, func(ctx context.Context, in *OrganizationInput) (*ListMembersOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		members, err := service.ListMembers(ctx, in.UserID, in.OrgID)
+		if err != nil {
+			return nil, organizationError(err)
+		}
+
+		var out ListMembersOutput
+		out.Body.Members = toMemberOutputs(members)
+		out.Status = http.StatusOK
+		return &out, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodPut,
+		Path:    "/orgs/{orgId}/members/{memberId}",
+		Summary: "Add a member or change their role",
+		Description: "Owners and admins manage members; only owners can make or change owners. " +
+			"Returns 409 when this would leave the organization without an owner.",
+	}, func(ctx context.Context, in *SetMemberInput) (*SetMemberOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		member, err := service.SetMember(ctx, in.UserID, in.OrgID, in.MemberID, model.Role(in.Body.Role))
+		if err != nil {
+			return nil, organizationError(err)
+		}
+
+		return &SetMemberOutput{Body: toMemberOutput(*member), Status: http.StatusOK}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:        http.MethodDelete,
+		Path:          "/orgs/{orgId}/members/{memberId}",
+		Summary:       "Remove a member",
+		Description:   "Members may remove themselves to leave. The last owner cannot leave.",
+		DefaultStatus: http.StatusNoContent,
+	}, func(ctx context.Context, in *MemberInput) (*RemoveMemberOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		if err := service.RemoveMember(ctx, in.UserID, in.OrgID, in.MemberID); err != nil {
+			return nil, organizationError(err)
+		}
+
+		return &RemoveMemberOutput{Status: http.StatusNoContent}, nil
+	})
+}
+
+func toMemberOutput(member model.Membership) MemberOutput {
+	return MemberOutput{
+		OrgID:    member.OrgID,
+		UserID:   member.UserID,
+		Role:     string(member.Role),
+		JoinedAt: member.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toMemberOutputs(members []model.Membership) []MemberOutput {
+	out := make([]MemberOutput, len(members))
+	for i, member := range members {
+		out[i] = toMemberOutput(member)
+	}
+	return out
+}
+
+// organizationError translates service errors about organizations into HTTP errors
+func organizationError(err error) error {
+	switch {
+	case errors.Is(err, shortener.ErrInvalidOrganization), errors.Is(err, shortener.ErrInvalidRole):
+		return huma.NewError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, shortener.ErrOrganizationNotFound), errors.Is(err, shortener.ErrMemberNotFound):
+		return huma.NewError(http.StatusNotFound, err.Error())
+	case errors.Is(err, shortener.ErrForbidden), errors.Is(err, shortener.ErrInsufficientRole):
+		return huma.NewError(http.StatusForbidden, err.Error())
+	case errors.Is(err, shortener.ErrLastOwner):
+		return huma.NewError(http.StatusConflict, err.Error())
+	default:
+		return huma.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
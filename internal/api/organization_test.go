@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_Organizations(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("CreateOrganization", "alice", "Acme").Return(&model.Organization{ID: "org_abc", Name: "Acme", CreatedAt: createdAt}, nil)
+	mockService.On("CreateOrganization", "alice", "").Return(nil, shortener.ErrInvalidOrganization)
+	mockService.On("ListOrganizations", "alice").Return([]model.Membership{{OrgID: "org_abc", UserID: "alice", Role: model.RoleOwner, CreatedAt: createdAt}}, nil)
+	mockService.On("ListMembers", "mallory", "org_abc").Return([]model.Membership(nil), shortener.ErrForbidden)
+
+	router := NewRouter(mockService)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orgs?userId=alice", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(`{"name":"Acme"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var org OrganizationOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+	assert.Equal(t, OrganizationOutput{ID: "org_abc", Name: "Acme", CreatedAt: "2024-03-01T12:00:00Z"}, org)
+	assert.Equal(t, http.StatusBadRequest, create(`{"name":""}`).Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/orgs?userId=alice", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ListOrganizationsOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list.Body))
+	assert.Equal(t, []MemberOutput{{OrgID: "org_abc", UserID: "alice", Role: "owner", JoinedAt: "2024-03-01T12:00:00Z"}}, list.Body.Organizations)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/orgs/org_abc/members?userId=mallory", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_OrganizationMembers(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("SetMember", "alice", "org_abc", "bob", model.RoleEditor).Return(&model.Membership{OrgID: "org_abc", UserID: "bob", Role: model.RoleEditor, CreatedAt: createdAt}, nil)
+	mockService.On("SetMember", "bob", "org_abc", "alice", model.RoleViewer).Return(nil, shortener.ErrInsufficientRole)
+	mockService.On("SetMember", "alice", "org_abc", "alice", model.RoleAdmin).Return(nil, shortener.ErrLastOwner)
+	mockService.On("RemoveMember", "alice", "org_abc", "bob").Return(nil)
+	mockService.On("RemoveMember", "alice", "org_abc", "carol").Return(shortener.ErrMemberNotFound)
+
+	router := NewRouter(mockService)
+
+	set := func(userID, memberID, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/orgs/org_abc/members/"+memberID+"?userId="+userID, bytes.NewBufferString(`{"role":"`+role+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := set("alice", "bob", "editor")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var member MemberOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &member))
+	assert.Equal(t, "editor", member.Role)
+
+	assert.Equal(t, http.StatusForbidden, set("bob", "alice", "viewer").Code)
+	assert.Equal(t, http.StatusConflict, set("alice", "alice", "admin").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, set("alice", "bob", "superuser").Code)
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/orgs/org_abc/members/bob?userId=alice", http.StatusNoContent},
+		{"/orgs/org_abc/members/carol?userId=alice", http.StatusNotFound},
+		{"/orgs/org_abc/members/bob", http.StatusBadRequest},
+	} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", tt.path, nil))
+		assert.Equal(t, tt.status, w.Code, tt.path)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_OrganizationLinks(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "bob", URL: "https://example.com", OrgID: "org_abc"}).Return("abc123", nil)
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "vic", URL: "https://example.com", OrgID: "org_abc"}).Return("", shortener.ErrInsufficientRole)
+	mockService.On("ListMappings", "vic", "org_abc", model.MappingFilter{}).Return([]model.URLMapping{
+		{Code: "abc123", Original: "https://example.com", UserID: "bob", OrgID: "org_abc", CreatedAt: time.Now()},
+	}, nil)
+	mockService.On("ListMappings", "mallory", "org_abc", model.MappingFilter{}).Return([]model.URLMapping(nil), shortener.ErrForbidden)
+	mockService.On("UpdateMapping", "vic", "", "abc123", shortener.MappingUpdate{}).Return(nil, shortener.ErrInsufficientRole)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	for userID, status := range map[string]int{"bob": http.StatusOK, "vic": http.StatusForbidden} {
+		req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"`+userID+`","url":"https://example.com","org_id":"org_abc"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, userID)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/mappings?userId=vic&orgId=org_abc", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ListMappingsOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list.Body))
+	assert.Len(t, list.Body.Mappings, 1)
+	assert.Equal(t, "org_abc", list.Body.Mappings[0].OrgID)
+	assert.Equal(t, "bob", list.Body.Mappings[0].CreatedBy)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/mappings?userId=mallory&orgId=org_abc", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req := httptest.NewRequest("PATCH", "/mappings/abc123?userId=vic", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_ResolveForwardsQueryAndPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		req  shortener.ResolveRequest
+	}{
+		{"query", "/abc123?utm_source=x&ref=a%20b", shortener.ResolveRequest{Code: "abc123", RawQuery: "utm_source=x&ref=a%20b", ClientID: testClientID}},
+		{"path", "/abc123/docs/page%20one", shortener.ResolveRequest{Code: "abc123", PathSuffix: "docs/page one", ClientID: testClientID}},
+		{"path and query", "/abc123/docs?q=go", shortener.ResolveRequest{Code: "abc123", PathSuffix: "docs", RawQuery: "q=go", ClientID: testClientID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &MockShortenerService{}
+			mockService.On("Resolve", tt.req).Return(&shortener.Resolution{URL: "https://example.com/resolved", StatusCode: http.StatusFound}, nil)
+
+			router := NewRouter(mockService)
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusFound, w.Code)
+			assert.Equal(t, "https://example.com/resolved", w.Header().Get("Location"))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRouter_ResolvePathDoesNotShadowRoutes(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("GetMapping", "user123", "", "abc123").Return(nil, shortener.ErrNotFound)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/abc123/stats?userId=user123", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "Resolve", mock.Anything)
+}
+
+func TestRouter_ShortenPassthrough(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{
+		UserID:           "user123",
+		URL:              "https://docs.example.com",
+		QueryPassthrough: model.QueryPassthroughOverride,
+		PathPassthrough:  true,
+	}).Return("docs12", nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	body := `{"userId":"user123","url":"https://docs.example.com","query_passthrough":"override","path_passthrough":true}`
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	mockService.AssertExpectations(t)
+
+	// Unknown policies are rejected before reaching the service
+	body = `{"userId":"user123","url":"https://docs.example.com","query_passthrough":"replace"}`
+	req = httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+// UnlockInput is a submission of the password form served for protected links
+type UnlockInput struct {
+	ResolveInput
+	RawBody []byte `contentType:"application/x-www-form-urlencoded"`
+}
+
+type UnlockPathInput struct {
+	UnlockInput
+	Rest string `path:"rest"`
+}
+
+// formPassword returns the password field of a form submission
+func (in *UnlockInput) formPassword() string {
+	form, _ := url.ParseQuery(string(in.RawBody))
+	return form.Get("password")
+}
+
+type passwordPage struct {
+	Error    string
+	Disabled bool
+}
+
+// passwordError reports whether err means a protected link was not unlocked
+func passwordError(err error) bool {
+	return errors.Is(err, shortener.ErrPasswordRequired) ||
+		errors.Is(err, shortener.ErrIncorrectPassword) ||
+		errors.Is(err, shortener.ErrTooManyAttempts)
+}
+
+// passwordResponse answers a request that did not unlock a protected link.
+// Browsers get the password form, API clients a problem response.
+func passwordResponse(err error, accept string) (*ResolveOutput, error) {
+	status := http.StatusUnauthorized
+	retryAfter := ""
+	var attemptsErr *shortener.TooManyAttemptsError
+	if errors.As(err, &attemptsErr) {
+		status = http.StatusTooManyRequests
+		retryAfter = strconv.Itoa(int(math.Ceil(attemptsErr.RetryAfter.Seconds())))
+	}
+
+	if !strings.Contains(accept, "text/html") {
+		apiErr := huma.NewError(status, err.Error())
+		if retryAfter != "" {
+			return nil, huma.ErrorWithHeaders(apiErr, http.Header{"Retry-After": {retryAfter}})
+		}
+		return nil, apiErr
+	}
+
+	page := passwordPage{}
+	switch {
+	case errors.Is(err, shortener.ErrIncorrectPassword):
+		page.Error = "Incorrect password."
+	case attemptsErr != nil:
+		page.Error = "Too many incorrect attempts. Try again later."
+		page.Disabled = true
+	}
+
+	body, renderErr := renderPage("password.html", page)
+	if renderErr != nil {
+		return nil, huma.NewError(http.StatusInternalServerError, renderErr.Error())
+	}
+
+	return &ResolveOutput{
+		ContentType:  "text/html; charset=utf-8",
+		CacheControl: "no-store",
+		RetryAfter:   retryAfter,
+		Status:       status,
+		Body:         body,
+	}, nil
+}
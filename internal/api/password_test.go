@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_ProtectedLinkForm(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: testClientID}).Return(nil, shortener.ErrPasswordRequired)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/secret", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `<form method="post" action="">`)
+	assert.NotContains(t, w.Body.String(), "Incorrect password")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ProtectedLinkAPIClient(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: testClientID}).Return(nil, shortener.ErrPasswordRequired)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: testClientID, Password: "s3cret"}).
+		Return(&shortener.Resolution{URL: "https://example.com/doc", StatusCode: http.StatusMovedPermanently, NoStore: true}, nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/secret", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "password required")
+
+	req = httptest.NewRequest("GET", "/secret", nil)
+	req.Header.Set("X-Link-Password", "s3cret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com/doc", w.Header().Get("Location"))
+	// Protected redirects are never cached, even permanent ones
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ClientIPBehindTrustedProxy(t *testing.T) {
+	mockService := &MockShortenerService{}
+	for _, clientID := range []string{"198.51.100.7", "203.0.113.5", testClientID} {
+		mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: clientID}).Return(nil, shortener.ErrPasswordRequired).Once()
+	}
+
+	resolve := func(router http.Handler, header, value string) {
+		req := httptest.NewRequest("GET", "/secret", nil)
+		req.Header.Set(header, value)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// The client is the rightmost forwarded address that is not a proxy,
+	// so addresses it made up itself further left are skipped
+	router := NewRouter(mockService, WithTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}))
+	resolve(router, "X-Forwarded-For", "10.9.9.9, 198.51.100.7, 192.0.2.50")
+	resolve(router, "X-Real-IP", "203.0.113.5")
+
+	// Other peers cannot pick the address they are limited by
+	resolve(NewRouter(mockService), "X-Forwarded-For", "198.51.100.7")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_UnlockForm(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: testClientID, Password: "s3cret pass"}).
+		Return(&shortener.Resolution{URL: "https://example.com/doc", StatusCode: http.StatusFound, NoStore: true}, nil)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: testClientID, Password: "wrong"}).
+		Return(nil, shortener.ErrIncorrectPassword)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("POST", "/secret", strings.NewReader("password=s3cret+pass"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://example.com/doc", w.Header().Get("Location"))
+
+	req = httptest.NewRequest("POST", "/secret", strings.NewReader("password=wrong"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Incorrect password")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_UnlockRateLimited(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: testClientID, Password: "guess"}).
+		Return(nil, &shortener.TooManyAttemptsError{RetryAfter: 90*time.Second + 200*time.Millisecond})
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/secret", nil)
+	req.Header.Set("X-Link-Password", "guess")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "91", w.Header().Get("Retry-After"))
+
+	req = httptest.NewRequest("POST", "/secret", strings.NewReader("password=guess"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "91", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Too many incorrect attempts")
+	assert.Contains(t, w.Body.String(), "disabled")
+
+	mockService.AssertExpectations(t)
+}
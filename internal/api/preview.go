@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+// previewSuffix appended to a code, as in /abc123+, shows where the link goes
+// instead of following it
+const previewSuffix = "+"
+
+type previewPage struct {
+	URL       string
+	Title     string
+	CreatedAt time.Time
+}
+
+// previewCode splits the preview suffix off code
+func previewCode(code string) (string, bool) {
+	if trimmed, ok := strings.CutSuffix(code, previewSuffix); ok && trimmed != "" {
+		return trimmed, true
+	}
+	return code, false
+}
+
+// previewResponse serves the page showing the destination of a link. It is
+// never stored, as the destination may depend on the request or change.
+func previewResponse(resolution *shortener.Resolution) (*ResolveOutput, error) {
+	body, err := renderPage("preview.html", previewPage{
+		URL:       resolution.URL,
+		Title:     resolution.Title,
+		CreatedAt: resolution.CreatedAt.UTC(),
+	})
+	if err != nil {
+		return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+	}
+
+	return &ResolveOutput{
+		ContentType:  "text/html; charset=utf-8",
+		CacheControl: "no-store",
+		Vary:         resolution.Vary,
+		Status:       http.StatusOK,
+		Body:         body,
+	}, nil
+}
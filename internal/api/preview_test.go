@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_Preview(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "abc123", ClientID: testClientID, Preview: true}).
+		Return(&shortener.Resolution{URL: "https://example.com/a?b=1&c=2", StatusCode: http.StatusFound, Interstitial: true, Title: "<Quarterly> report", CreatedAt: createdAt}, nil)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "docs", PathSuffix: "guide", ClientID: testClientID, Preview: true}).
+		Return(&shortener.Resolution{URL: "https://docs.example.com/guide", StatusCode: http.StatusFound, Interstitial: true, CreatedAt: createdAt}, nil)
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/abc123+", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	body := w.Body.String()
+	assert.Contains(t, body, "<h1>&lt;Quarterly&gt; report</h1>")
+	assert.Contains(t, body, `href="https://example.com/a?b=1&amp;c=2"`)
+	assert.Contains(t, body, `<time datetime="2024-03-01T12:00:00Z">Fri, 01 Mar 2024</time>`)
+
+	// Prefix links preview the destination of the whole path
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/docs+/guide", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>Link preview</h1>")
+	assert.Contains(t, w.Body.String(), `href="https://docs.example.com/guide"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_PreviewProtected(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", ClientID: testClientID, Preview: true}).
+		Return(nil, shortener.ErrPasswordRequired)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "secret", Password: "s3cret", ClientID: testClientID, Preview: true}).
+		Return(&shortener.Resolution{URL: "https://example.com/report.pdf", StatusCode: http.StatusFound, NoStore: true, Interstitial: true}, nil)
+
+	router := NewRouter(mockService)
+
+	// The destination is only shown once the password is given
+	req := httptest.NewRequest("GET", "/secret+", nil)
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "report.pdf")
+
+	form := url.Values{"password": {"s3cret"}}
+	req = httptest.NewRequest("POST", "/secret+", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="https://example.com/report.pdf"`)
+}
+
+func TestRouter_Interstitial(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "abc123", ClientID: testClientID}).
+		Return(&shortener.Resolution{URL: "https://example.com", StatusCode: http.StatusMovedPermanently, Interstitial: true}, nil)
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/abc123", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `href="https://example.com"`)
+}
+
+func TestRouter_ShortenTitle(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", Title: "Quarterly report", Interstitial: true}).Return("abc123", nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","title":"Quarterly report","interstitial":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","title":"`+strings.Repeat("a", 201)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_UpdateMappingTitle(t *testing.T) {
+	title, interstitial := "Renamed", false
+
+	mockService := &MockShortenerService{}
+	mockService.On("UpdateMapping", "user123", "", "abc123", shortener.MappingUpdate{Title: &title, Interstitial: &interstitial}).
+		Return(&model.URLMapping{Code: "abc123", Original: "https://example.com", UserID: "user123", CreatedAt: time.Now(), Title: title}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("PATCH", "/mappings/abc123?userId=user123", bytes.NewBufferString(`{"title":"Renamed","interstitial":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response URLMappingOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Renamed", response.Title)
+	assert.False(t, response.Interstitial)
+	mockService.AssertExpectations(t)
+}
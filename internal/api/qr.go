@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/shortener"
+	"rsc.io/qr"
+)
+
+const (
+	// qrCacheControl lets clients and CDNs keep QR codes for a day. The image
+	// only depends on the short URL and the query, so it rarely changes.
+	qrCacheControl = "public, max-age=86400"
+
+	qrFormatPNG = "png"
+	qrFormatSVG = "svg"
+)
+
+var qrLevels = map[string]qr.Level{"L": qr.L, "M": qr.M, "Q": qr.Q, "H": qr.H}
+
+type QRInput struct {
+	Code        string   `path:"code"`
+	Format      string   `query:"format" enum:"png,svg" default:"png"`
+	Size        int      `query:"size" minimum:"64" maximum:"2048" default:"256" doc:"Width and height of the image in pixels"`
+	Margin      int      `query:"margin" minimum:"0" maximum:"16" default:"4" doc:"Quiet zone around the code, in modules"`
+	Level       string   `query:"ec" enum:"L,M,Q,H" default:"M" doc:"Error correction level: L recovers 7% of the code, M 15%, Q 25% and H 30%"`
+	Foreground  string   `query:"fg" pattern:"^([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$" default:"000000" doc:"Hex color of the modules, without '#'"`
+	Background  string   `query:"bg" pattern:"^([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$" default:"ffffff" doc:"Hex color of the background, without '#'"`
+	IfNoneMatch []string `header:"If-None-Match"`
+	host        string
+}
+
+// Resolve captures the host, which selects the domain of the code
+func (in *QRInput) Resolve(ctx huma.Context) []error {
+	in.host = ctx.Host()
+	return nil
+}
+
+type QROutput struct {
+	ContentType  string `header:"Content-Type"`
+	CacheControl string `header:"Cache-Control"`
+	ETag         string `header:"ETag"`
+	Status       int    `json:"status" example:"200"`
+	Body         []byte
+}
+
+// etag returns the strong ETag of the QR code of shortURL drawn as in asks
+func (in *QRInput) etag(shortURL string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		shortURL, in.Format, strconv.Itoa(in.Size), strconv.Itoa(in.Margin),
+		in.Level, strings.ToLower(in.Foreground), strings.ToLower(in.Background),
+	}, "\n")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified reports whether the client already has the image tagged etag
+func (in *QRInput) notModified(etag string) bool {
+	for _, header := range in.IfNoneMatch {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func registerQRRoutes(humaAPI huma.API, service shortener.Shortener) {
+	// The route is more specific than /{code}/{rest...}, so it takes
+	// precedence over prefix links
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/{code}/qr",
+		Summary: "Get the QR code of a link",
+		Description: "Encodes the link's short URL as a PNG or SVG image. " +
+			"Responses carry an ETag, so clients can revalidate with If-None-Match and get a 304. " +
+			"Prefix links cannot forward a path of just qr, which is served by this route.",
+		Responses: map[string]*huma.Response{
+			"200": {Content: map[string]*huma.MediaType{"image/png": {}, "image/svg+xml": {}}},
+		},
+	}, func(ctx context.Context, in *QRInput) (*QROutput, error) {
+		shortURL, err := service.ShortURL(ctx, in.host, in.Code)
+		if errors.Is(err, shortener.ErrNotFound) {
+			return nil, huma.NewError(http.StatusNotFound, "not found")
+		}
+		if err != nil {
+			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		out := &QROutput{CacheControl: qrCacheControl, ETag: in.etag(shortURL)}
+		if in.notModified(out.ETag) {
+			out.Status = http.StatusNotModified
+			return out, nil
+		}
+
+		code, err := qr.Encode(shortURL, qrLevels[in.Level])
+		if err != nil {
+			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		fg, bg := hexColor(in.Foreground), hexColor(in.Background)
+		if in.Format == qrFormatSVG {
+			out.ContentType = "image/svg+xml"
+			out.Body = qrSVG(code, in.Size, in.Margin, fg, bg)
+		} else {
+			if code.Size+2*in.Margin > in.Size {
+				return nil, huma.NewError(http.StatusBadRequest,
+					fmt.Sprintf("size must be at least %d pixels to draw this code with a margin of %d", code.Size+2*in.Margin, in.Margin))
+			}
+			out.ContentType = "image/png"
+			if out.Body, err = qrPNG(code, in.Size, in.Margin, fg, bg); err != nil {
+				return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+			}
+		}
+		out.Status = http.StatusOK
+		return out, nil
+	})
+}
+
+// qrPNG draws code on a size by size image. Modules are a whole number of
+// pixels wide, which keeps them sharp for scanners; pixels left over are
+// spread around the margin.
+func qrPNG(code *qr.Code, size, margin int, fg, bg color.RGBA) ([]byte, error) {
+	modules := code.Size + 2*margin
+	scale := size / modules
+	offset := (size-scale*modules)/2 + margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{bg, fg})
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := 0; px < scale; px++ {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// qrSVG draws code as a single path in a viewBox of one unit per module,
+// scaled to size pixels
+func qrSVG(code *qr.Code, size, margin int, fg, bg color.RGBA) []byte {
+	modules := code.Size + 2*margin
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/><path fill="%s" d="`, cssColor(bg), cssColor(fg))
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			// Runs of dark modules become one rectangle
+			run := 1
+			for code.Black(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+margin, y+margin, run, run)
+			x += run - 1
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+// hexColor parses a color of 3 or 6 hex digits, validated by the input schema
+func hexColor(value string) color.RGBA {
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	rgb, _ := strconv.ParseUint(value, 16, 32)
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}
+}
+
+func cssColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_QRCodePNG(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ShortURL", "example.com", "abc123").Return("https://short.url/abc123", nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/abc123/qr?size=300&fg=f00&bg=00ff00", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	img, err := png.Decode(w.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	red, green := color.RGBA{R: 0xff, A: 0xff}, color.RGBA{G: 0xff, A: 0xff}
+	colors := map[color.RGBA]bool{}
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 300; x++ {
+			colors[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)] = true
+		}
+	}
+	assert.Equal(t, map[color.RGBA]bool{red: true, green: true}, colors)
+	assert.Equal(t, green, color.RGBAModel.Convert(img.At(0, 0)))
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_QRCodeSVG(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ShortURL", "example.com", "abc123").Return("https://short.url/abc123", nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/abc123/qr?format=svg&margin=0&ec=H", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, `<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256"`))
+	assert.Contains(t, body, `fill="#000000"`)
+	assert.Contains(t, body, `fill="#ffffff"`)
+	// The finder pattern's first row is a run of 7 dark modules at the origin
+	assert.Contains(t, body, `d="M0 0h7v1h-7z`)
+}
+
+func TestRouter_QRCodeETag(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ShortURL", "example.com", "abc123").Return("https://short.url/abc123", nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/abc123/qr", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+
+	req = httptest.NewRequest("GET", "/abc123/qr", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Empty(t, w.Body.Bytes())
+
+	// Other parameters draw another image
+	req = httptest.NewRequest("GET", "/abc123/qr?format=svg", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestRouter_QRCodeErrors(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ShortURL", "example.com", "abc123").Return("https://short.url/abc123", nil)
+	mockService.On("ShortURL", "example.com", "missing").Return("", shortener.ErrNotFound)
+	mockService.On("ShortURL", "example.com", "long").Return("https://short.url/"+strings.Repeat("long", 25), nil)
+
+	router := NewRouter(mockService)
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"unknown code", "/missing/qr", http.StatusNotFound},
+		{"unknown format", "/abc123/qr?format=gif", http.StatusUnprocessableEntity},
+		{"size too large", "/abc123/qr?size=4096", http.StatusUnprocessableEntity},
+		{"unknown level", "/abc123/qr?ec=X", http.StatusUnprocessableEntity},
+		{"invalid color", "/abc123/qr?fg=red", http.StatusUnprocessableEntity},
+		{"size too small for margin", "/long/qr?size=64&margin=16", http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tc.target, nil))
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestQRPNG_Scale(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ShortURL", "example.com", "abc123").Return("https://short.url/abc123", nil)
+
+	router := NewRouter(mockService)
+
+	// Leftover pixels pad the margin with background
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/abc123/qr?size=110&margin=0", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	assert.Equal(t, white, color.RGBAModel.Convert(img.At(0, 0)))
+	assert.Equal(t, white, color.RGBAModel.Convert(img.At(109, 109)))
+}
+
+func TestRouter_QRCodeTakesPrecedenceOverPrefixLinks(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ShortURL", "example.com", "abc123").Return("https://short.url/abc123", nil)
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/abc123/qr", nil))
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	mockService.AssertNotCalled(t, "Resolve", mock.Anything)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+type UsageInput struct {
+	UserID string `query:"userId"`
+	OrgID  string `query:"orgId" doc:"Report the usage of this organization instead of the user's personal links"`
+}
+
+type UsageCounter struct {
+	Used  int `json:"used" example:"42"`
+	Limit int `json:"limit" example:"100" doc:"0 means no limit"`
+}
+
+type UsageOutput struct {
+	Body struct {
+		UserID string `json:"user_id,omitempty"`
+		OrgID  string `json:"org_id,omitempty"`
+		// Month is the start of the calendar month redirects are counted in
+		Month            string       `json:"month" example:"2024-05-01T00:00:00Z"`
+		ActiveLinks      UsageCounter `json:"active_links"`
+		MonthlyRedirects UsageCounter `json:"monthly_redirects"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+type SetQuotaInput struct {
+	AdminInput
+	UserID string `query:"userId"`
+	OrgID  string `query:"orgId"`
+	Body   struct {
+		ActiveLinks      *int `json:"active_links,omitempty" minimum:"0" doc:"Omit to use the default, 0 for no limit"`
+		MonthlyRedirects *int `json:"monthly_redirects,omitempty" minimum:"0" doc:"Omit to use the default, 0 for no limit"`
+	}
+}
+
+type QuotaOutput struct {
+	Body struct {
+		ActiveLinks      int `json:"active_links" example:"100" doc:"0 means no limit"`
+		MonthlyRedirects int `json:"monthly_redirects" example:"10000" doc:"0 means no limit"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+// registerQuotaRoutes registers the usage endpoint, and the endpoint
+// overriding quotas when an admin token is configured
+func registerQuotaRoutes(humaAPI huma.API, service shortener.Shortener, opts routerOptions) {
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/usage",
+		Summary: "Report usage against quotas",
+		Description: "Reports the active links and this month's redirects of the user's personal links, " +
+			"or of an organization's links when orgId is set, with the limits of each.",
+	}, func(ctx context.Context, in *UsageInput) (*UsageOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		usage, err := service.Usage(ctx, in.UserID, in.OrgID)
+		if err != nil {
+			return nil, quotaError(err)
+		}
+
+		var out UsageOutput
+		out.Body.UserID = usage.Account.UserID
+		out.Body.OrgID = usage.Account.OrgID
+		out.Body.Month = usage.Month.Format(time.RFC3339)
+		out.Body.ActiveLinks = UsageCounter{Used: usage.ActiveLinks, Limit: usage.Limits.ActiveLinks}
+		out.Body.MonthlyRedirects = UsageCounter{Used: usage.MonthlyRedirects, Limit: usage.Limits.MonthlyRedirects}
+		out.Status = http.StatusOK
+		return &out, nil
+	})
+
+	if opts.adminToken == "" {
+		return
+	}
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodPut,
+		Path:    "/admin/quotas",
+		Summary: "Override the quota of a user or organization",
+		Description: "Replaces the limits of the user's personal links, or of the organization's links. " +
+			"Limits left out fall back to the defaults.",
+		Tags: []string{"admin"},
+	}, func(ctx context.Context, in *SetQuotaInput) (*QuotaOutput, error) {
+		if err := checkAdminToken(opts.adminToken, in.Token); err != nil {
+			return nil, err
+		}
+
+		limits, err := service.SetQuota(ctx, model.Quota{
+			Account:          model.Account{UserID: in.UserID, OrgID: in.OrgID},
+			ActiveLinks:      in.Body.ActiveLinks,
+			MonthlyRedirects: in.Body.MonthlyRedirects,
+		})
+		if err != nil {
+			return nil, quotaError(err)
+		}
+
+		var out QuotaOutput
+		out.Body.ActiveLinks = limits.ActiveLinks
+		out.Body.MonthlyRedirects = limits.MonthlyRedirects
+		out.Status = http.StatusOK
+		return &out, nil
+	})
+}
+
+// quotaExceeded reports whether err was caused by an account reaching its active link quota
+func quotaExceeded(err error) bool {
+	return errors.Is(err, shortener.ErrActiveLinksQuota)
+}
+
+// quotaError translates service errors into HTTP errors
+func quotaError(err error) error {
+	switch {
+	case errors.Is(err, shortener.ErrInvalidAccount), errors.Is(err, shortener.ErrInvalidQuota):
+		return huma.NewError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, shortener.ErrOrganizationNotFound):
+		return huma.NewError(http.StatusNotFound, err.Error())
+	case errors.Is(err, shortener.ErrForbidden):
+		return huma.NewError(http.StatusForbidden, err.Error())
+	default:
+		return huma.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
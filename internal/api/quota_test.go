@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_Usage(t *testing.T) {
+	month := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	mockService := &MockShortenerService{}
+	mockService.On("Usage", "alice", "").Return(&shortener.Usage{
+		Account:          model.Account{UserID: "alice"},
+		Month:            month,
+		ActiveLinks:      42,
+		MonthlyRedirects: 1234,
+		Limits:           model.Limits{ActiveLinks: 100},
+	}, nil)
+	mockService.On("Usage", "mallory", "org_abc").Return(nil, shortener.ErrForbidden)
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/usage?userId=alice", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var out UsageOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out.Body))
+	assert.Equal(t, "alice", out.Body.UserID)
+	assert.Equal(t, "2024-05-01T00:00:00Z", out.Body.Month)
+	assert.Equal(t, UsageCounter{Used: 42, Limit: 100}, out.Body.ActiveLinks)
+	assert.Equal(t, UsageCounter{Used: 1234, Limit: 0}, out.Body.MonthlyRedirects)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/usage?userId=mallory&orgId=org_abc", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/usage", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ShortenQuotaExceeded(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", mock.Anything).Return("", shortener.ErrActiveLinksQuota)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"url":"https://example.com","userId":"alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "active link quota reached")
+}
+
+func TestRouter_ResolveRedirectQuotaExceeded(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "busy", ClientID: testClientID}).Return(nil, shortener.ErrRedirectQuota)
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/busy", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "monthly redirect quota reached")
+}
+
+func TestRouter_SetQuota(t *testing.T) {
+	links := 500
+
+	mockService := &MockShortenerService{}
+	mockService.On("SetQuota", model.Quota{Account: model.Account{UserID: "alice"}, ActiveLinks: &links}).
+		Return(model.Limits{ActiveLinks: 500, MonthlyRedirects: 10000}, nil)
+	mockService.On("SetQuota", model.Quota{Account: model.Account{OrgID: "org_missing"}}).
+		Return(model.Limits{}, shortener.ErrOrganizationNotFound)
+
+	router := NewRouter(mockService, WithAdmin("s3cret", new(slog.LevelVar)))
+
+	set := func(query, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/admin/quotas?"+query, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := set("userId=alice", "s3cret", `{"active_links":500}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var out QuotaOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out.Body))
+	assert.Equal(t, 500, out.Body.ActiveLinks)
+	assert.Equal(t, 10000, out.Body.MonthlyRedirects)
+
+	assert.Equal(t, http.StatusNotFound, set("orgId=org_missing", "s3cret", `{}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, set("userId=alice", "wrong", `{"active_links":1}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, set("userId=alice", "s3cret", `{"active_links":-1}`).Code)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_ResolveRedirectType(t *testing.T) {
+	expiresSoon := time.Now().Add(90 * time.Second)
+
+	tests := []struct {
+		name         string
+		resolution   shortener.Resolution
+		cacheControl string
+		hasExpires   bool
+	}{
+		{"found", shortener.Resolution{StatusCode: http.StatusFound}, "private, no-cache", false},
+		{"temporary", shortener.Resolution{StatusCode: http.StatusTemporaryRedirect}, "private, no-cache", false},
+		{"moved permanently", shortener.Resolution{StatusCode: http.StatusMovedPermanently}, "public, max-age=3600", true},
+		{"permanent redirect", shortener.Resolution{StatusCode: http.StatusPermanentRedirect}, "public, max-age=3600", true},
+		{"permanent until expiry", shortener.Resolution{StatusCode: http.StatusMovedPermanently, ExpiresAt: &expiresSoon}, "public, max-age=89", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolution := tt.resolution
+			resolution.URL = "https://example.com"
+
+			mockService := &MockShortenerService{}
+			mockService.On("Resolve", shortener.ResolveRequest{Code: "abc123", ClientID: testClientID}).Return(&resolution, nil)
+
+			router := NewRouter(mockService, WithRedirectCacheMaxAge(time.Hour))
+
+			req := httptest.NewRequest("GET", "/abc123", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.resolution.StatusCode, w.Code)
+			assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+			// Allow a second of drift between the request and the expiry computation
+			if tt.resolution.ExpiresAt != nil {
+				assert.Contains(t, []string{tt.cacheControl, "public, max-age=88"}, w.Header().Get("Cache-Control"))
+			} else {
+				assert.Equal(t, tt.cacheControl, w.Header().Get("Cache-Control"))
+			}
+
+			if tt.hasExpires {
+				expires, err := http.ParseTime(w.Header().Get("Expires"))
+				assert.NoError(t, err)
+				assert.True(t, expires.After(time.Now()))
+			} else {
+				assert.Empty(t, w.Header().Get("Expires"))
+			}
+		})
+	}
+}
+
+func TestRedirectCacheHeaders(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Second)
+
+	cacheControl, expires := redirectCacheHeaders(&shortener.Resolution{StatusCode: http.StatusMovedPermanently}, 24*time.Hour, now)
+	assert.Equal(t, "public, max-age=86400", cacheControl)
+	assert.Equal(t, "Thu, 02 Jan 2025 12:00:00 GMT", expires)
+
+	// A link at its expiry must not be cached at all
+	cacheControl, expires = redirectCacheHeaders(&shortener.Resolution{StatusCode: http.StatusMovedPermanently, ExpiresAt: &expired}, 24*time.Hour, now)
+	assert.Equal(t, "no-store", cacheControl)
+	assert.Empty(t, expires)
+}
+
+func TestRouter_ShortenRedirectType(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", RedirectType: 301}).Return("abc123", nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","redirect_type":301}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+
+	// Unsupported redirect types are rejected before reaching the service
+	req = httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","redirect_type":303}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockService.AssertNumberOfCalls(t, "Shorten", 1)
+}
+
+func TestRouter_ResolveClickLimitReached(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "used", ClientID: testClientID}).Return(nil, shortener.ErrClickLimitReached)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/used", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_MappingStatsMaxClicks(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("GetMapping", "user123", "", "limited").Return(&model.URLMapping{
+		Code:      "limited",
+		Original:  "https://example.com",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+		Clicks:    3,
+		MaxClicks: 5,
+	}, nil)
+	mockService.On("GetMapping", "user123", "", "unlimited").Return(&model.URLMapping{
+		Code:      "unlimited",
+		Original:  "https://example.com",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+		Clicks:    3,
+	}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/limited/stats?userId=user123", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, float64(5), response["max_clicks"])
+	assert.Equal(t, float64(2), response["remaining_clicks"])
+
+	req = httptest.NewRequest("GET", "/mappings/unlimited/stats?userId=user123", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = nil
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotContains(t, response, "max_clicks")
+	assert.NotContains(t, response, "remaining_clicks")
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/logging"
+	"github.com/wiredmatt/go_short/internal/shortener"
+	"github.com/wiredmatt/go_short/internal/storage"
+)
+
+func TestRouter_RequestIDHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		inbound  string
+		expected string
+	}{
+		{"honors valid inbound ID", "client-req.42:a_b", "client-req.42:a_b"},
+		{"generates ID when missing", "", ""},
+		{"replaces ID with invalid characters", "bad id\n", ""},
+		{"replaces overlong ID", strings.Repeat("a", 129), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			router := NewRouter(mockService)
+
+			req := httptest.NewRequest("GET", "/health", nil)
+			if tt.inbound != "" {
+				req.Header.Set("X-Request-ID", tt.inbound)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			got := w.Header().Get("X-Request-ID")
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, got)
+			} else {
+				_, err := uuid.Parse(got)
+				assert.NoError(t, err, "expected a generated UUID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRouter_RequestIDCorrelatesLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelDebug)
+
+	store := storage.NewInstrumentedStore(storage.NewMemoryStore(), "memory", logger)
+	router := NewRouter(shortener.NewService(store, "https://short.url", 6, shortener.WithLogger(logger)))
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	req.Header.Set("X-Request-ID", "req-correlated")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	messages := map[string]bool{}
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal([]byte(raw), &line))
+		assert.Equal(t, "req-correlated", line["request_id"], "log line %q", raw)
+		messages[line["msg"].(string)] = true
+	}
+
+	// Both the storage and the service layer logged for this request
+	assert.True(t, messages["store operation"])
+	assert.True(t, messages["Resolve missed"])
+}
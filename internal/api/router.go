@@ -2,6 +2,8 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -9,6 +11,8 @@ import (
 	humago "github.com/danielgtaylor/huma/v2/adapters/humago"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/wiredmatt/go_short/internal/api/middleware"
+	"github.com/wiredmatt/go_short/internal/health"
+	"github.com/wiredmatt/go_short/internal/model"
 	"github.com/wiredmatt/go_short/internal/shortener"
 )
 
@@ -19,10 +23,32 @@ type HealthOutput struct {
 	Status int `json:"status" example:"200"`
 }
 
+type ProbeOutput struct {
+	Body   health.Report
+	Status int `json:"status" example:"200"`
+}
+
 type ShortenInput struct {
 	Body struct {
-		UserID string `json:"userId"`
-		URL    string `json:"url"`
+		UserID           string        `json:"userId"`
+		URL              string        `json:"url,omitempty" doc:"Absolute http(s) destination of the link, optional when variants are set"`
+		Domain           string        `json:"domain,omitempty" doc:"One of the user's registered domains to create the link on, the default domain if omitted"`
+		OrgID            string        `json:"org_id,omitempty" doc:"Create the link for this organization, where the user must be an owner, admin or editor. Omit for a personal link."`
+		RedirectType     int           `json:"redirect_type,omitempty" enum:"301,302,307,308" doc:"HTTP status used to redirect, defaults to the server's DEFAULT_REDIRECT_TYPE"`
+		QueryPassthrough string        `json:"query_passthrough,omitempty" enum:"merge,override,append" doc:"Forward the request's query string: merge keeps the destination's value on conflicts, override replaces it, append keeps both. Omit to drop it."`
+		PathPassthrough  bool          `json:"path_passthrough,omitempty" doc:"Make this a prefix link: path segments after the code are appended to the destination. A path of just qr serves the link's QR code instead."`
+		UTM              UTMParams     `json:"utm,omitempty" doc:"Campaign parameters composed into url as utm_* query parameters, replacing any it has. Values are lowercased and spaces become '_'. Source, medium and campaign are required once any is set."`
+		UTMTemplate      string        `json:"utm_template,omitempty" doc:"Name of a saved UTM template; parameters set in utm override the template's"`
+		Password         string        `json:"password,omitempty" doc:"Require this password, 4 to 72 bytes, to follow the link"`
+		MaxClicks        int           `json:"max_clicks,omitempty" minimum:"0" doc:"Stop redirecting after this many clicks, 1 for single use links. 0 or omitted for no limit."`
+		ActivatesAt      *time.Time    `json:"activates_at,omitempty" doc:"Time the link starts redirecting. Until then it serves a placeholder."`
+		Rules            []RoutingRule `json:"rules,omitempty" maxItems:"20" doc:"Send requests matching all of a rule's conditions to its url instead. Rules are checked in order and url is the fallback."`
+		Variants         []Variant     `json:"variants,omitempty" maxItems:"10" doc:"Split traffic between 2 to 10 destinations by weight, in place of url. Routing rules still take precedence."`
+		StickyVariants   bool          `json:"sticky_variants,omitempty" doc:"Keep each visitor on the variant they got first, with a cookie"`
+		Title            string        `json:"title,omitempty" maxLength:"200" doc:"Shown on the link's preview page"`
+		Interstitial     bool          `json:"interstitial,omitempty" doc:"Show a page with the destination instead of redirecting right away"`
+		Tags             []string      `json:"tags,omitempty" maxItems:"20" doc:"Labels for the link, 1 to 50 letters, digits, spaces, '-', '_' or '.' each. They are lowercased."`
+		Folder           string        `json:"folder,omitempty" maxLength:"100" doc:"Folder to group the link in"`
 	}
 }
 type ShortenOutput struct {
@@ -33,24 +59,114 @@ type ShortenOutput struct {
 }
 
 type ResolveInput struct {
-	Code string `path:"code"`
+	Code     string `path:"code"`
+	Password string `header:"X-Link-Password" doc:"Password of a protected link"`
+	Accept   string `header:"Accept"`
+	Variant  string `cookie:"go_short_variant" doc:"Variant a sticky split link served before"`
+	host     string
+	rawQuery string
+	clientIP string
+	header   http.Header
+}
+
+// Resolve captures the host, which selects the domain of the code, the raw
+// query string, which is forwarded as is rather than through declared
+// parameters, the headers routing rules match on and the client address
+// password attempts are limited by
+func (in *ResolveInput) Resolve(ctx huma.Context) []error {
+	in.host = ctx.Host()
+	in.rawQuery = ctx.URL().RawQuery
+	ctx.EachHeader(func(name, value string) {
+		if in.header == nil {
+			in.header = http.Header{}
+		}
+		in.header.Add(name, value)
+	})
+	in.clientIP = middleware.GetClientIP(ctx)
+	return nil
+}
+
+// resolveRequest builds the service request for in. Codes ending in the
+// preview suffix ask for the link's preview page.
+func (in *ResolveInput) resolveRequest(pathSuffix, password string) shortener.ResolveRequest {
+	code, preview := previewCode(in.Code)
+	return shortener.ResolveRequest{
+		Host:       in.host,
+		Code:       code,
+		RawQuery:   in.rawQuery,
+		PathSuffix: pathSuffix,
+		Password:   password,
+		ClientID:   in.clientIP,
+		Header:     in.header,
+		Variant:    in.Variant,
+		Preview:    preview,
+	}
+}
+
+type ResolvePathInput struct {
+	ResolveInput
+	Rest string `path:"rest"`
 }
+
 type ResolveOutput struct {
-	Location string `header:"Location"`
-	Status   int    `json:"status" example:"302"`
+	Location     string `header:"Location"`
+	CacheControl string `header:"Cache-Control"`
+	Expires      string `header:"Expires"`
+	ContentType  string `header:"Content-Type"`
+	RetryAfter   string `header:"Retry-After"`
+	Vary         string `header:"Vary"`
+	SetCookie    string `header:"Set-Cookie"`
+	Status       int    `json:"status" example:"302"`
+	// Body is the password form of protected links, sent to browsers
+	Body []byte
+}
+
+// UserInput identifies the user listing their own resources
+type UserInput struct {
+	UserID string `query:"userId"`
 }
 
 type ListMappingsInput struct {
 	UserID string `query:"userId"`
+	OrgID  string `query:"orgId" doc:"List the links of this organization instead of the user's personal ones. The user must be a member."`
+	Tag    string `query:"tag" doc:"Only list links carrying this tag"`
+	Folder string `query:"folder" doc:"Only list links in this folder"`
 }
 
 type URLMappingOutput struct {
-	Code      string  `json:"code"`
-	Original  string  `json:"original_url"`
-	ShortURL  string  `json:"short_url"`
-	CreatedAt string  `json:"created_at"`
-	ExpiresAt *string `json:"expires_at,omitempty"`
-	Clicks    int     `json:"clicks"`
+	Domain           string          `json:"domain,omitempty" doc:"Omitted for links on the default domain"`
+	OrgID            string          `json:"org_id,omitempty" doc:"Organization owning the link, omitted for personal links"`
+	CreatedBy        string          `json:"created_by"`
+	Code             string          `json:"code"`
+	Original         string          `json:"original_url"`
+	ShortURL         string          `json:"short_url"`
+	CreatedAt        string          `json:"created_at"`
+	ExpiresAt        *string         `json:"expires_at,omitempty"`
+	ActivatesAt      *string         `json:"activates_at,omitempty"`
+	Clicks           int             `json:"clicks"`
+	MaxClicks        int             `json:"max_clicks,omitempty"`
+	RemainingClicks  *int            `json:"remaining_clicks,omitempty" doc:"Clicks left before the link stops redirecting, omitted for links without max_clicks"`
+	RedirectType     int             `json:"redirect_type,omitempty" doc:"Omitted for links that follow the server default"`
+	QueryPassthrough string          `json:"query_passthrough,omitempty"`
+	PathPassthrough  bool            `json:"path_passthrough,omitempty"`
+	UTM              *UTMParams      `json:"utm,omitempty"`
+	Protected        bool            `json:"protected,omitempty" doc:"Set for password protected links"`
+	Rules            []RoutingRule   `json:"rules,omitempty"`
+	Variants         []VariantOutput `json:"variants,omitempty" doc:"Destinations of a split link with the clicks each served"`
+	StickyVariants   bool            `json:"sticky_variants,omitempty"`
+	Title            string          `json:"title,omitempty"`
+	Interstitial     bool            `json:"interstitial,omitempty"`
+	Tags             []string        `json:"tags,omitempty"`
+	Folder           string          `json:"folder,omitempty"`
+	Metadata         *MetadataOutput `json:"metadata,omitempty" doc:"Read from the destination page shortly after the link is created, omitted until then"`
+}
+
+type MetadataOutput struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty" doc:"Absolute URL of the page's preview image"`
+	SiteName    string `json:"site_name,omitempty"`
+	FetchedAt   string `json:"fetched_at"`
 }
 
 type ListMappingsOutput struct {
@@ -60,16 +176,81 @@ type ListMappingsOutput struct {
 	Status int `json:"status" example:"200"`
 }
 
-func NewRouter(service shortener.Shortener) *http.ServeMux {
+type MappingInput struct {
+	Code   string `path:"code"`
+	UserID string `query:"userId"`
+	Domain string `query:"domain" doc:"Domain of the link, omit for the default domain"`
+}
+
+type UpdateMappingInput struct {
+	MappingInput
+	Body struct {
+		ActivatesAt    *string        `json:"activates_at,omitempty" doc:"RFC 3339 time the link starts redirecting, or an empty string to activate it right away. Omit to leave it unchanged."`
+		Rules          *[]RoutingRule `json:"rules,omitempty" maxItems:"20" doc:"Replaces the routing rules, an empty list removes them. Omit to leave them unchanged."`
+		Variants       *[]Variant     `json:"variants,omitempty" maxItems:"10" doc:"Replaces the variants, an empty list stops splitting traffic. Click counts of variants are kept."`
+		StickyVariants *bool          `json:"sticky_variants,omitempty"`
+		Title          *string        `json:"title,omitempty" maxLength:"200" doc:"Replaces the title, an empty string removes it"`
+		Interstitial   *bool          `json:"interstitial,omitempty"`
+		Tags           *[]string      `json:"tags,omitempty" maxItems:"20" doc:"Replaces the tags, an empty list removes them"`
+		Folder         *string        `json:"folder,omitempty" maxLength:"100" doc:"Moves the link to this folder, an empty string takes it out of its folder"`
+	}
+}
+
+// mappingUpdate parses the body of in into the service update
+func (in *UpdateMappingInput) mappingUpdate() (shortener.MappingUpdate, error) {
+	var update shortener.MappingUpdate
+	if in.Body.ActivatesAt != nil {
+		var activatesAt time.Time
+		if *in.Body.ActivatesAt != "" {
+			var err error
+			if activatesAt, err = time.Parse(time.RFC3339, *in.Body.ActivatesAt); err != nil {
+				return update, huma.NewError(http.StatusBadRequest, "activates_at must be an RFC 3339 time")
+			}
+		}
+		update.ActivatesAt = &activatesAt
+	}
+	if in.Body.Rules != nil {
+		rules := toRoutingRuleModels(*in.Body.Rules)
+		update.Rules = &rules
+	}
+	if in.Body.Variants != nil {
+		variants := toVariantModels(*in.Body.Variants)
+		update.Variants = &variants
+	}
+	update.StickyVariants = in.Body.StickyVariants
+	update.Title = in.Body.Title
+	update.Interstitial = in.Body.Interstitial
+	update.Tags = in.Body.Tags
+	update.Folder = in.Body.Folder
+	return update, nil
+}
+
+type MappingStatsOutput struct {
+	Body   URLMappingOutput
+	Status int `json:"status" example:"200"`
+}
+
+type DeleteMappingOutput struct {
+	Status int `json:"status" example:"204"`
+}
+
+func NewRouter(service shortener.Shortener, opts ...Option) *http.ServeMux {
+	options := newRouterOptions(opts)
+
 	apiMux := http.NewServeMux()
 
 	// Initialize Huma on this mux
-	humaAPI := humago.New(apiMux, huma.DefaultConfig("URL Shortener API", "1.0.0"))
+	config := huma.DefaultConfig("URL Shortener API", "1.0.0")
+	// The default /schemas/{schema} would clash with /{code}/qr
+	config.SchemasPath = "/openapi/schemas"
+	humaAPI := humago.New(apiMux, config)
 
 	middleware.PrometheusInit()
 
+	humaAPI.UseMiddleware(middleware.Tracing)
 	humaAPI.UseMiddleware(middleware.RequestID)
-	humaAPI.UseMiddleware(middleware.RequestLogger)
+	humaAPI.UseMiddleware(middleware.ClientIP(options.trustedProxies))
+	humaAPI.UseMiddleware(middleware.RequestLogger(options.logger))
 	humaAPI.UseMiddleware(middleware.TrackMetrics)
 
 	huma.Register(humaAPI, huma.Operation{
@@ -84,33 +265,160 @@ func NewRouter(service shortener.Shortener) *http.ServeMux {
 	})
 
 	huma.Register(humaAPI, huma.Operation{
-		Method:  http.MethodPost,
-		Path:    "/shorten",
-		Summary: "Create a shortened URL",
+		Method:      http.MethodGet,
+		Path:        "/livez",
+		Summary:     "Liveness probe",
+		Description: "Reports whether the process is running. Dependencies are not checked.",
+	}, func(ctx context.Context, _ *struct{}) (*ProbeOutput, error) {
+		return &ProbeOutput{Body: options.health.Live(), Status: http.StatusOK}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/readyz",
+		Summary:     "Readiness probe",
+		Description: "Checks every dependency and reports each one. Returns 503 if any is down or the server is shutting down.",
+		Responses: map[string]*huma.Response{
+			"503": {Description: "Not ready"},
+		},
+	}, func(ctx context.Context, _ *struct{}) (*ProbeOutput, error) {
+		report := options.health.Ready(ctx)
+		status := http.StatusOK
+		if !report.Up() {
+			status = http.StatusServiceUnavailable
+		}
+		return &ProbeOutput{Body: report, Status: status}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/shorten",
+		Summary:     "Create a shortened URL",
+		Description: "Returns 403 once the account has reached its active link or monthly redirect quota, see GET /usage.",
 	}, func(ctx context.Context, in *ShortenInput) (*ShortenOutput, error) {
-		code, err := service.Shorten(in.Body.UserID, in.Body.URL)
+		code, err := service.Shorten(ctx, shortener.ShortenRequest{
+			UserID:           in.Body.UserID,
+			URL:              in.Body.URL,
+			Domain:           in.Body.Domain,
+			OrgID:            in.Body.OrgID,
+			RedirectType:     in.Body.RedirectType,
+			QueryPassthrough: in.Body.QueryPassthrough,
+			PathPassthrough:  in.Body.PathPassthrough,
+			UTM:              in.Body.UTM.toModel(),
+			UTMTemplate:      in.Body.UTMTemplate,
+			Password:         in.Body.Password,
+			MaxClicks:        in.Body.MaxClicks,
+			ActivatesAt:      in.Body.ActivatesAt,
+			Rules:            toRoutingRuleModels(in.Body.Rules),
+			Variants:         toVariantModels(in.Body.Variants),
+			StickyVariants:   in.Body.StickyVariants,
+			Title:            in.Body.Title,
+			Interstitial:     in.Body.Interstitial,
+			Tags:             in.Body.Tags,
+			Folder:           in.Body.Folder,
+		})
+		if shortenInputError(err) {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, shortener.ErrForbidden) || errors.Is(err, shortener.ErrInsufficientRole) || quotaExceeded(err) {
+			return nil, huma.NewError(http.StatusForbidden, err.Error())
+		}
 		if err != nil {
 			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
 		}
 		var out ShortenOutput
-		out.Body.ShortURL = service.GetBaseURL() + "/" + code
+		out.Body.ShortURL = shortener.LinkURL(service.GetBaseURL(), in.Body.Domain, code)
 		out.Status = http.StatusOK
 		return &out, nil
 	})
 
+	resolve := func(ctx context.Context, in *ResolveInput, req shortener.ResolveRequest) (*ResolveOutput, error) {
+		resolution, err := service.Resolve(ctx, req)
+		if passwordError(err) {
+			return passwordResponse(err, in.Accept)
+		}
+		if errors.Is(err, shortener.ErrNotActive) {
+			return notActiveResponse(err, in.Accept, options.pendingLinkURL)
+		}
+		if errors.Is(err, shortener.ErrClickLimitReached) {
+			return nil, huma.NewError(http.StatusGone, err.Error())
+		}
+		if errors.Is(err, shortener.ErrRedirectQuota) {
+			return nil, huma.NewError(http.StatusTooManyRequests, err.Error())
+		}
+		if err != nil || resolution == nil || resolution.URL == "" {
+			return nil, huma.NewError(http.StatusNotFound, "not found")
+		}
+		if resolution.Interstitial {
+			return previewResponse(resolution)
+		}
+
+		cacheControl, expires := redirectCacheHeaders(resolution, options.redirectCacheMaxAge, time.Now())
+		out := &ResolveOutput{
+			Location:     resolution.URL,
+			CacheControl: cacheControl,
+			Expires:      expires,
+			Vary:         resolution.Vary,
+			Status:       resolution.StatusCode,
+		}
+		if resolution.StickyVariant {
+			out.SetCookie = variantSetCookie(in.Code, resolution.Variant)
+		}
+		return out, nil
+	}
+
 	huma.Register(humaAPI, huma.Operation{
 		Method:  http.MethodGet,
 		Path:    "/{code}",
 		Summary: "Resolve a shortened URL",
+		Description: "Redirects with the link's redirect type. Permanent redirects (301, 308) may be cached until the link expires; " +
+			"temporary ones (302, 307) must be revalidated so every click is counted. " +
+			"The query string is forwarded when the link sets query_passthrough. " +
+			"Links are not served before their activates_at: browsers get a placeholder page, or a redirect to PENDING_LINK_URL when set. " +
+			"Returns 410 once a link has used up its max_clicks, and 429 once its account has used up its monthly redirect quota. " +
+			"Appending + to the code, as in /abc123+, serves a page showing the destination instead of redirecting, as interstitial links always do.",
+		Responses: map[string]*huma.Response{
+			"410": {Description: "The link has reached its click limit"},
+			"429": {Description: "The link's account has reached its monthly redirect quota"},
+		},
 	}, func(ctx context.Context, in *ResolveInput) (*ResolveOutput, error) {
-		originalURL, err := service.Resolve(in.Code)
-		if err != nil || originalURL == "" {
-			return nil, huma.NewError(http.StatusNotFound, "not found")
+		return resolve(ctx, in, in.resolveRequest("", in.Password))
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/{code}/{rest...}",
+		Summary:     "Resolve a prefix link",
+		Description: "Redirects to the link's destination with the rest of the path appended. Returns 404 for links without path_passthrough. A path of just qr is served by the QR code route instead.",
+	}, func(ctx context.Context, in *ResolvePathInput) (*ResolveOutput, error) {
+		return resolve(ctx, &in.ResolveInput, in.resolveRequest(in.Rest, in.Password))
+	})
+
+	// Password forms post back to the link's own URL. A successful unlock
+	// redirects with 303 so the browser follows it with a GET.
+	unlock := func(ctx context.Context, in *UnlockInput, pathSuffix string) (*ResolveOutput, error) {
+		out, err := resolve(ctx, &in.ResolveInput, in.resolveRequest(pathSuffix, in.formPassword()))
+		if err == nil && out.Location != "" {
+			out.Status = http.StatusSeeOther
 		}
-		return &ResolveOutput{
-			Location: originalURL,
-			Status:   http.StatusFound,
-		}, nil
+		return out, err
+	}
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodPost,
+		Path:        "/{code}",
+		Summary:     "Unlock a protected link",
+		Description: "Accepts the password form served for protected links and redirects with 303 once the password is verified.",
+	}, func(ctx context.Context, in *UnlockInput) (*ResolveOutput, error) {
+		return unlock(ctx, in, "")
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodPost,
+		Path:    "/{code}/{rest...}",
+		Summary: "Unlock a protected prefix link",
+	}, func(ctx context.Context, in *UnlockPathInput) (*ResolveOutput, error) {
+		return unlock(ctx, &in.UnlockInput, in.Rest)
 	})
 
 	huma.Register(humaAPI, huma.Operation{
@@ -122,33 +430,95 @@ func NewRouter(service shortener.Shortener) *http.ServeMux {
 			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
 		}
 
-		mappings, err := service.ListMappings(in.UserID)
+		mappings, err := service.ListMappings(ctx, in.UserID, in.OrgID, model.MappingFilter{Tag: in.Tag, Folder: in.Folder})
 		if err != nil {
-			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+			return nil, organizationError(err)
 		}
 
 		var output ListMappingsOutput
 		output.Body.Mappings = make([]URLMappingOutput, len(mappings))
 
 		for i, mapping := range mappings {
-			output.Body.Mappings[i] = URLMappingOutput{
-				Code:      mapping.Code,
-				Original:  mapping.Original,
-				ShortURL:  service.GetBaseURL() + "/" + mapping.Code,
-				CreatedAt: mapping.CreatedAt.Format(time.RFC3339),
-				Clicks:    mapping.Clicks,
-			}
-
-			if mapping.ExpiresAt != nil {
-				expiresAt := mapping.ExpiresAt.Format(time.RFC3339)
-				output.Body.Mappings[i].ExpiresAt = &expiresAt
-			}
+			output.Body.Mappings[i] = toURLMappingOutput(service.GetBaseURL(), mapping)
 		}
 
 		output.Status = http.StatusOK
 		return &output, nil
 	})
 
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/mappings/{code}/stats",
+		Summary: "Get statistics for a URL mapping",
+	}, func(ctx context.Context, in *MappingInput) (*MappingStatsOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		mapping, err := service.GetMapping(ctx, in.UserID, in.Domain, in.Code)
+		if err != nil {
+			return nil, mappingError(err)
+		}
+
+		return &MappingStatsOutput{
+			Body:   toURLMappingOutput(service.GetBaseURL(), *mapping),
+			Status: http.StatusOK,
+		}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodPatch,
+		Path:        "/mappings/{code}",
+		Summary:     "Update a URL mapping",
+		Description: "Changes the settings given in the body and leaves the rest as they are.",
+	}, func(ctx context.Context, in *UpdateMappingInput) (*MappingStatsOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		update, err := in.mappingUpdate()
+		if err != nil {
+			return nil, err
+		}
+
+		mapping, err := service.UpdateMapping(ctx, in.UserID, in.Domain, in.Code, update)
+		if err != nil {
+			return nil, mappingError(err)
+		}
+
+		return &MappingStatsOutput{
+			Body:   toURLMappingOutput(service.GetBaseURL(), *mapping),
+			Status: http.StatusOK,
+		}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:        http.MethodDelete,
+		Path:          "/mappings/{code}",
+		Summary:       "Delete a URL mapping",
+		DefaultStatus: http.StatusNoContent,
+	}, func(ctx context.Context, in *MappingInput) (*DeleteMappingOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		if err := service.DeleteMapping(ctx, in.UserID, in.Domain, in.Code); err != nil {
+			return nil, mappingError(err)
+		}
+
+		return &DeleteMappingOutput{Status: http.StatusNoContent}, nil
+	})
+
+	registerTransferRoutes(humaAPI, service)
+	registerUTMRoutes(humaAPI, service)
+	registerQRRoutes(humaAPI, service)
+	registerDomainRoutes(humaAPI, service)
+	registerOrganizationRoutes(humaAPI, service)
+	registerTagRoutes(humaAPI, service)
+	registerSearchRoutes(humaAPI, service)
+	registerQuotaRoutes(humaAPI, service, options)
+	registerAdminRoutes(humaAPI, options)
+
 	metricsMux := http.NewServeMux()
 	metricsMux.Handle("/metrics", promhttp.Handler())
 
@@ -158,3 +528,120 @@ func NewRouter(service shortener.Shortener) *http.ServeMux {
 
 	return root
 }
+
+// redirectCacheHeaders returns the Cache-Control and Expires headers of a
+// redirect. Permanent redirects are cacheable for maxAge, but never past the
+// link's expiry; temporary ones must be revalidated on every use, and
+// protected or click limited ones are never stored.
+func redirectCacheHeaders(resolution *shortener.Resolution, maxAge time.Duration, now time.Time) (cacheControl, expires string) {
+	// A cached redirect would skip the password check or click limit
+	if resolution.NoStore {
+		return "no-store", ""
+	}
+
+	if !model.PermanentRedirect(resolution.StatusCode) {
+		return "private, no-cache", ""
+	}
+
+	if resolution.ExpiresAt != nil {
+		if remaining := resolution.ExpiresAt.Sub(now); remaining < maxAge {
+			maxAge = remaining
+		}
+	}
+
+	maxAge = maxAge.Truncate(time.Second)
+	if maxAge <= 0 {
+		return "no-store", ""
+	}
+
+	return fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())), now.Add(maxAge).UTC().Format(http.TimeFormat)
+}
+
+func toURLMappingOutput(baseURL string, mapping model.URLMapping) URLMappingOutput {
+	out := URLMappingOutput{
+		Domain:           mapping.Domain,
+		OrgID:            mapping.OrgID,
+		CreatedBy:        mapping.UserID,
+		Code:             mapping.Code,
+		Original:         mapping.Original,
+		ShortURL:         shortener.LinkURL(baseURL, mapping.Domain, mapping.Code),
+		CreatedAt:        mapping.CreatedAt.Format(time.RFC3339),
+		Clicks:           mapping.Clicks,
+		MaxClicks:        mapping.MaxClicks,
+		RemainingClicks:  mapping.RemainingClicks(),
+		RedirectType:     mapping.RedirectType,
+		QueryPassthrough: mapping.QueryPassthrough,
+		PathPassthrough:  mapping.PathPassthrough,
+		UTM:              toUTMParams(mapping.UTM),
+		Protected:        mapping.Protected(),
+		Rules:            toRoutingRules(mapping.Rules),
+		Variants:         toVariantOutputs(mapping),
+		StickyVariants:   mapping.StickyVariants,
+		Title:            mapping.Title,
+		Interstitial:     mapping.Interstitial,
+		Tags:             mapping.Tags,
+		Folder:           mapping.Folder,
+	}
+
+	if mapping.ExpiresAt != nil {
+		expiresAt := mapping.ExpiresAt.Format(time.RFC3339)
+		out.ExpiresAt = &expiresAt
+	}
+	if mapping.ActivatesAt != nil {
+		activatesAt := mapping.ActivatesAt.Format(time.RFC3339)
+		out.ActivatesAt = &activatesAt
+	}
+	if mapping.Metadata != nil {
+		out.Metadata = &MetadataOutput{
+			Title:       mapping.Metadata.Title,
+			Description: mapping.Metadata.Description,
+			Image:       mapping.Metadata.Image,
+			SiteName:    mapping.Metadata.SiteName,
+			FetchedAt:   mapping.Metadata.FetchedAt.Format(time.RFC3339),
+		}
+	}
+
+	return out
+}
+
+// shortenInputError reports whether err was caused by an invalid shorten request
+func shortenInputError(err error) bool {
+	for _, target := range []error{
+		shortener.ErrInvalidRedirectType,
+		shortener.ErrInvalidQueryPassthrough,
+		shortener.ErrInvalidURL,
+		shortener.ErrInvalidUTM,
+		shortener.ErrUTMTemplateNotFound,
+		shortener.ErrInvalidPassword,
+		shortener.ErrInvalidMaxClicks,
+		shortener.ErrInvalidActivation,
+		shortener.ErrInvalidRoutingRule,
+		shortener.ErrInvalidVariants,
+		shortener.ErrInvalidTitle,
+		shortener.ErrInvalidTags,
+		shortener.ErrInvalidFolder,
+		shortener.ErrDomainNotFound,
+		shortener.ErrOrganizationNotFound,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingError translates service errors into HTTP errors
+func mappingError(err error) error {
+	switch {
+	case errors.Is(err, shortener.ErrNotFound):
+		return huma.NewError(http.StatusNotFound, "not found")
+	case errors.Is(err, shortener.ErrForbidden), errors.Is(err, shortener.ErrInsufficientRole):
+		return huma.NewError(http.StatusForbidden, err.Error())
+	case errors.Is(err, shortener.ErrInvalidActivation), errors.Is(err, shortener.ErrInvalidRoutingRule),
+		errors.Is(err, shortener.ErrInvalidVariants), errors.Is(err, shortener.ErrInvalidTitle),
+		errors.Is(err, shortener.ErrInvalidTags), errors.Is(err, shortener.ErrInvalidFolder):
+		return huma.NewError(http.StatusBadRequest, err.Error())
+	default:
+		return huma.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
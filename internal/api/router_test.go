@@ -2,18 +2,29 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
 )
 
+// testClientID is the client address of httptest requests
+const testClientID = "192.0.2.1"
+
 type MockShortenerService struct {
 	mock.Mock
+	// resolveHeaders records the headers of each Resolve call, which are
+	// left out of the expectations so they need not list every header
+	resolveHeaders []http.Header
+	// resolveHosts records the hosts of each Resolve call, likewise
+	resolveHosts []string
 }
 
 func (m *MockShortenerService) GetBaseURL() string {
@@ -21,18 +32,154 @@ func (m *MockShortenerService) GetBaseURL() string {
 	return args.String(0)
 }
 
-func (m *MockShortenerService) Shorten(userID, originalURL string) (string, error) {
-	args := m.Called(userID, originalURL)
+func (m *MockShortenerService) Shorten(_ context.Context, req shortener.ShortenRequest) (string, error) {
+	args := m.Called(req)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockShortenerService) Resolve(code string) (string, error) {
-	args := m.Called(code)
+func (m *MockShortenerService) Resolve(_ context.Context, req shortener.ResolveRequest) (*shortener.Resolution, error) {
+	m.resolveHeaders = append(m.resolveHeaders, req.Header)
+	req.Header = nil
+	m.resolveHosts = append(m.resolveHosts, req.Host)
+	req.Host = ""
+	args := m.Called(req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*shortener.Resolution), args.Error(1)
+}
+
+func (m *MockShortenerService) ListMappings(_ context.Context, userID, orgID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	args := m.Called(userID, orgID, filter)
+	return args.Get(0).([]model.URLMapping), args.Error(1)
+}
+
+func (m *MockShortenerService) GetMapping(_ context.Context, userID, domain, code string) (*model.URLMapping, error) {
+	args := m.Called(userID, domain, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *MockShortenerService) ShortURL(_ context.Context, host, code string) (string, error) {
+	args := m.Called(host, code)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockShortenerService) ListMappings(userID string) ([]model.URLMapping, error) {
+func (m *MockShortenerService) UpdateMapping(_ context.Context, userID, domain, code string, update shortener.MappingUpdate) (*model.URLMapping, error) {
+	args := m.Called(userID, domain, code, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *MockShortenerService) DeleteMapping(_ context.Context, userID, domain, code string) error {
+	args := m.Called(userID, domain, code)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) ImportMappings(_ context.Context, userID string, records []shortener.ImportRecord) shortener.ImportResult {
+	args := m.Called(userID, records)
+	return args.Get(0).(shortener.ImportResult)
+}
+
+func (m *MockShortenerService) SaveUTMTemplate(_ context.Context, userID, name string, utm model.UTM) (*model.UTMTemplate, error) {
+	args := m.Called(userID, name, utm)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UTMTemplate), args.Error(1)
+}
+
+func (m *MockShortenerService) ListUTMTemplates(_ context.Context, userID string) ([]model.UTMTemplate, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.UTMTemplate), args.Error(1)
+}
+
+func (m *MockShortenerService) DeleteUTMTemplate(_ context.Context, userID, name string) error {
+	args := m.Called(userID, name)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) RegisterDomain(_ context.Context, userID, name string) (*model.Domain, error) {
+	args := m.Called(userID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Domain), args.Error(1)
+}
+
+func (m *MockShortenerService) ListDomains(_ context.Context, userID string) ([]model.Domain, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.Domain), args.Error(1)
+}
+
+func (m *MockShortenerService) DeleteDomain(_ context.Context, userID, name string) error {
+	args := m.Called(userID, name)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) CreateOrganization(_ context.Context, userID, name string) (*model.Organization, error) {
+	args := m.Called(userID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Organization), args.Error(1)
+}
+
+func (m *MockShortenerService) ListOrganizations(_ context.Context, userID string) ([]model.Membership, error) {
 	args := m.Called(userID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *MockShortenerService) ListMembers(_ context.Context, userID, orgID string) ([]model.Membership, error) {
+	args := m.Called(userID, orgID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *MockShortenerService) SetMember(_ context.Context, userID, orgID, memberID string, role model.Role) (*model.Membership, error) {
+	args := m.Called(userID, orgID, memberID, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Membership), args.Error(1)
+}
+
+func (m *MockShortenerService) RemoveMember(_ context.Context, userID, orgID, memberID string) error {
+	args := m.Called(userID, orgID, memberID)
+	return args.Error(0)
+}
+
+func (m *MockShortenerService) Usage(_ context.Context, userID, orgID string) (*shortener.Usage, error) {
+	args := m.Called(userID, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*shortener.Usage), args.Error(1)
+}
+
+func (m *MockShortenerService) SetQuota(_ context.Context, quota model.Quota) (model.Limits, error) {
+	args := m.Called(quota)
+	return args.Get(0).(model.Limits), args.Error(1)
+}
+
+func (m *MockShortenerService) ListTags(_ context.Context, userID, orgID string) ([]model.TagCount, error) {
+	args := m.Called(userID, orgID)
+	return args.Get(0).([]model.TagCount), args.Error(1)
+}
+
+func (m *MockShortenerService) RenameTag(_ context.Context, userID, orgID, from, to string) (*model.TagCount, error) {
+	args := m.Called(userID, orgID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TagCount), args.Error(1)
+}
+
+func (m *MockShortenerService) SearchMappings(_ context.Context, userID, orgID, query string, limit int) ([]model.URLMapping, error) {
+	args := m.Called(userID, orgID, query, limit)
 	return args.Get(0).([]model.URLMapping), args.Error(1)
 }
 
@@ -67,7 +214,7 @@ func TestRouter_ShortenEndpoint(t *testing.T) {
 	baseURL := "https://short.url"
 
 	// Setup mock expectations
-	mockService.On("Shorten", "user123", "https://example.com/very/long/url").Return("abc123", nil)
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com/very/long/url"}).Return("abc123", nil)
 	mockService.On("GetBaseURL").Return(baseURL)
 
 	router := NewRouter(mockService)
@@ -105,7 +252,7 @@ func TestRouter_ResolveEndpoint(t *testing.T) {
 	expectedURL := "https://example.com/very/long/url"
 
 	// Setup mock expectations
-	mockService.On("Resolve", "abc123").Return(expectedURL, nil)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "abc123", ClientID: testClientID}).Return(&shortener.Resolution{URL: expectedURL, StatusCode: http.StatusFound}, nil)
 
 	router := NewRouter(mockService)
 
@@ -127,6 +274,9 @@ func TestRouter_NotFound(t *testing.T) {
 	mockService := &MockShortenerService{}
 	router := NewRouter(mockService)
 
+	// Unknown nested paths are resolved as prefix links
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "nonexistent", PathSuffix: "endpoint", ClientID: testClientID}).Return(nil, shortener.ErrNotFound)
+
 	// Test non-existent endpoint
 	req := httptest.NewRequest("GET", "/nonexistent/endpoint", nil)
 	w := httptest.NewRecorder()
@@ -135,6 +285,8 @@ func TestRouter_NotFound(t *testing.T) {
 
 	// Should return 404
 	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
 }
 
 func TestRouter_MethodNotAllowed(t *testing.T) {
@@ -142,7 +294,7 @@ func TestRouter_MethodNotAllowed(t *testing.T) {
 	router := NewRouter(mockService)
 
 	// Setup mock to return error for "shorten" as a code
-	mockService.On("Resolve", "shorten").Return("", assert.AnError)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "shorten", ClientID: testClientID}).Return(nil, assert.AnError)
 
 	// Test wrong method for shorten endpoint
 	req := httptest.NewRequest("GET", "/shorten", nil)
@@ -160,7 +312,7 @@ func TestRouter_ResolveNotFound(t *testing.T) {
 	mockService := &MockShortenerService{}
 
 	// Setup mock to return error
-	mockService.On("Resolve", "nonexistent").Return("", assert.AnError)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "nonexistent", ClientID: testClientID}).Return(nil, assert.AnError)
 
 	router := NewRouter(mockService)
 
@@ -197,7 +349,7 @@ func TestRouter_ShortenServiceError(t *testing.T) {
 	mockService := &MockShortenerService{}
 
 	// Setup mock to return error
-	mockService.On("Shorten", "user123", "https://example.com/very/long/url").Return("", assert.AnError)
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com/very/long/url"}).Return("", assert.AnError)
 	mockService.On("GetBaseURL").Return("https://short.url")
 
 	router := NewRouter(mockService)
@@ -218,3 +370,136 @@ func TestRouter_ShortenServiceError(t *testing.T) {
 	// Assertions
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
+
+func TestRouter_MappingStatsEndpoint(t *testing.T) {
+	mockService := &MockShortenerService{}
+	baseURL := "https://short.url"
+
+	mapping := &model.URLMapping{
+		Code:      "abc123",
+		Original:  "https://example.com/very/long/url",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+		Clicks:    7,
+	}
+
+	mockService.On("GetMapping", "user123", "", "abc123").Return(mapping, nil)
+	mockService.On("GetBaseURL").Return(baseURL)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/abc123/stats?userId=user123", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response URLMappingOutput
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", response.Code)
+	assert.Equal(t, baseURL+"/abc123", response.ShortURL)
+	assert.Equal(t, 7, response.Clicks)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_MappingStatsMetadata(t *testing.T) {
+	mockService := &MockShortenerService{}
+	fetchedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mockService.On("GetMapping", "user123", "", "abc123").Return(&model.URLMapping{
+		Code:      "abc123",
+		Original:  "https://example.com/launch",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+		Metadata:  &model.Metadata{Title: "Launch", Image: "https://example.com/cover.png", FetchedAt: fetchedAt},
+	}, nil)
+	mockService.On("GetMapping", "user123", "", "fresh").Return(&model.URLMapping{
+		Code:      "fresh",
+		Original:  "https://example.com",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+	}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/mappings/abc123/stats?userId=user123", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response URLMappingOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, &MetadataOutput{Title: "Launch", Image: "https://example.com/cover.png", FetchedAt: "2025-06-01T12:00:00Z"}, response.Metadata)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/mappings/fresh/stats?userId=user123", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"metadata"`)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_MappingStatsForbidden(t *testing.T) {
+	mockService := &MockShortenerService{}
+
+	mockService.On("GetMapping", "intruder", "", "abc123").Return(nil, shortener.ErrForbidden)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/abc123/stats?userId=intruder", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_DeleteMappingEndpoint(t *testing.T) {
+	mockService := &MockShortenerService{}
+
+	mockService.On("DeleteMapping", "user123", "", "abc123").Return(nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("DELETE", "/mappings/abc123?userId=user123", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_DeleteMappingNotFound(t *testing.T) {
+	mockService := &MockShortenerService{}
+
+	mockService.On("DeleteMapping", "user123", "", "missing").Return(shortener.ErrNotFound)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("DELETE", "/mappings/missing?userId=user123", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_DeleteMappingMissingUser(t *testing.T) {
+	mockService := &MockShortenerService{}
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("DELETE", "/mappings/abc123", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
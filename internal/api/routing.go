@@ -0,0 +1,52 @@
+package api
+
+import "github.com/wiredmatt/go_short/internal/model"
+
+// RoutingRule is a routing rule of requests and responses
+type RoutingRule struct {
+	Platform string            `json:"platform,omitempty" enum:"ios,android,windows,macos,linux" doc:"Platform detected from the User-Agent"`
+	Device   string            `json:"device,omitempty" enum:"mobile,tablet,desktop" doc:"Device class detected from the User-Agent"`
+	Language string            `json:"language,omitempty" example:"pt-BR" doc:"Most preferred Accept-Language, matched by primary subtag (pt) or full tag (pt-BR)"`
+	Headers  map[string]string `json:"headers,omitempty" doc:"Request headers to match, ignoring case. An empty value only requires the header to be present."`
+	URL      string            `json:"url" example:"https://apps.apple.com/app/id123456789" doc:"Destination of matching requests"`
+}
+
+func (r RoutingRule) toModel() model.RoutingRule {
+	return model.RoutingRule{
+		Platform: r.Platform,
+		Device:   r.Device,
+		Language: r.Language,
+		Headers:  r.Headers,
+		URL:      r.URL,
+	}
+}
+
+// toRoutingRuleModels converts the rules of a request, keeping nil as nil
+func toRoutingRuleModels(rules []RoutingRule) []model.RoutingRule {
+	if rules == nil {
+		return nil
+	}
+	out := make([]model.RoutingRule, len(rules))
+	for i, rule := range rules {
+		out[i] = rule.toModel()
+	}
+	return out
+}
+
+// toRoutingRules converts the rules of a mapping for responses
+func toRoutingRules(rules []model.RoutingRule) []RoutingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]RoutingRule, len(rules))
+	for i, rule := range rules {
+		out[i] = RoutingRule{
+			Platform: rule.Platform,
+			Device:   rule.Device,
+			Language: rule.Language,
+			Headers:  rule.Headers,
+			URL:      rule.URL,
+		}
+	}
+	return out
+}
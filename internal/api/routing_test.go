@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_ShortenRoutingRules(t *testing.T) {
+	rules := []model.RoutingRule{
+		{Platform: model.PlatformIOS, URL: "https://apps.apple.com/app/id123"},
+		{Language: "pt", Headers: map[string]string{"X-Beta": "yes"}, URL: "https://example.com/pt"},
+	}
+
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", Rules: rules}).Return("abc123", nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	body := `{"userId":"user123","url":"https://example.com","rules":[` +
+		`{"platform":"ios","url":"https://apps.apple.com/app/id123"},` +
+		`{"language":"pt","headers":{"X-Beta":"yes"},"url":"https://example.com/pt"}]}`
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+
+	// Unknown platforms are rejected before reaching the service
+	req = httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","rules":[{"platform":"symbian","url":"https://example.com"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockService.AssertNumberOfCalls(t, "Shorten", 1)
+}
+
+func TestRouter_ShortenInvalidRoutingRule(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", Rules: []model.RoutingRule{{URL: "https://example.com/all"}}}).
+		Return("", shortener.ErrInvalidRoutingRule)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(`{"userId":"user123","url":"https://example.com","rules":[{"url":"https://example.com/all"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRouter_ResolveForwardsHeaders(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "app", ClientID: testClientID}).
+		Return(&shortener.Resolution{URL: "https://apps.apple.com/app/id123", StatusCode: http.StatusFound, Vary: "User-Agent"}, nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/app", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)")
+	req.Header.Set("Accept-Language", "pt-BR")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "User-Agent", w.Header().Get("Vary"))
+	assert.Len(t, mockService.resolveHeaders, 1)
+	assert.Equal(t, "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)", mockService.resolveHeaders[0].Get("User-Agent"))
+	assert.Equal(t, "pt-BR", mockService.resolveHeaders[0].Get("Accept-Language"))
+}
+
+func TestRouter_UpdateMappingRoutingRules(t *testing.T) {
+	rules := []model.RoutingRule{{Platform: model.PlatformAndroid, URL: "https://play.google.com/store/apps/details?id=com.example"}}
+
+	mockService := &MockShortenerService{}
+	mockService.On("UpdateMapping", "user123", "", "abc123", shortener.MappingUpdate{Rules: &rules}).
+		Return(&model.URLMapping{Code: "abc123", Original: "https://example.com", UserID: "user123", CreatedAt: time.Now(), Rules: rules}, nil)
+	mockService.On("UpdateMapping", "user123", "", "abc123", shortener.MappingUpdate{Rules: &[]model.RoutingRule{}}).
+		Return(&model.URLMapping{Code: "abc123", Original: "https://example.com", UserID: "user123", CreatedAt: time.Now()}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("PATCH", "/mappings/abc123?userId=user123", bytes.NewBufferString(`{"rules":[{"platform":"android","url":"https://play.google.com/store/apps/details?id=com.example"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response URLMappingOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []RoutingRule{{Platform: "android", URL: "https://play.google.com/store/apps/details?id=com.example"}}, response.Rules)
+
+	// An empty list removes the rules
+	req = httptest.NewRequest("PATCH", "/mappings/abc123?userId=user123", bytes.NewBufferString(`{"rules":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = URLMappingOutput{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Empty(t, response.Rules)
+
+	mockService.AssertExpectations(t)
+}
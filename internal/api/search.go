@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+type SearchMappingsInput struct {
+	UserID string `query:"userId"`
+	OrgID  string `query:"orgId" doc:"Search the links of this organization instead of the user's personal ones. The user must be a member."`
+	Query  string `query:"q" example:"launch blog" doc:"Words to find in the code, title, tags or destination of links. Words match as prefixes and links must match all of them."`
+	Limit  int    `query:"limit" default:"20" minimum:"1" maximum:"100"`
+}
+
+func registerSearchRoutes(humaAPI huma.API, service shortener.Shortener) {
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/mappings/search",
+		Summary: "Search URL mappings",
+		Description: "Finds the user's personal links, or an organization's links when orgId is set, matching every word of q. " +
+			"The most relevant come first: matches in the code or title rank above matches in tags, which rank above matches in the destination.",
+	}, func(ctx context.Context, in *SearchMappingsInput) (*ListMappingsOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		mappings, err := service.SearchMappings(ctx, in.UserID, in.OrgID, in.Query, in.Limit)
+		if err != nil {
+			if errors.Is(err, shortener.ErrInvalidSearch) {
+				return nil, huma.NewError(http.StatusBadRequest, err.Error())
+			}
+			return nil, organizationError(err)
+		}
+
+		var output ListMappingsOutput
+		output.Body.Mappings = make([]URLMappingOutput, len(mappings))
+		for i, mapping := range mappings {
+			output.Body.Mappings[i] = toURLMappingOutput(service.GetBaseURL(), mapping)
+		}
+
+		output.Status = http.StatusOK
+		return &output, nil
+	})
+}
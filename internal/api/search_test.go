@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_SearchMappings(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("SearchMappings", "alice", "", "launch memo", 20).Return([]model.URLMapping{
+		{Code: "memo", Original: "https://example.com/memo", UserID: "alice", Title: "Launch memo"},
+	}, nil)
+	mockService.On("SearchMappings", "alice", "", "--", 5).Return([]model.URLMapping(nil), shortener.ErrInvalidSearch)
+	mockService.On("SearchMappings", "mallory", "org_abc", "launch", 20).Return([]model.URLMapping(nil), shortener.ErrForbidden)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/mappings/search?userId=alice&q=launch+memo")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ListMappingsOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list.Body))
+	if assert.Len(t, list.Body.Mappings, 1) {
+		assert.Equal(t, "memo", list.Body.Mappings[0].Code)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get("/mappings/search?userId=alice&q=--&limit=5").Code)
+	assert.Equal(t, http.StatusForbidden, get("/mappings/search?userId=mallory&orgId=org_abc&q=launch").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/mappings/search?q=launch").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, get("/mappings/search?userId=alice&q=launch&limit=500").Code)
+
+	mockService.AssertExpectations(t)
+}
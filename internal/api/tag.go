@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+type TagsInput struct {
+	UserID string `query:"userId"`
+	OrgID  string `query:"orgId" doc:"Use the tags of this organization's links instead of the user's personal ones"`
+}
+
+type TagOutput struct {
+	Name  string `json:"name" example:"launch"`
+	Links int    `json:"links" doc:"Links carrying the tag"`
+}
+
+type ListTagsOutput struct {
+	Body struct {
+		Tags []TagOutput `json:"tags"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+type RenameTagInput struct {
+	TagsInput
+	Name string `path:"name"`
+	Body struct {
+		Name string `json:"name" maxLength:"50" example:"q3-launch" doc:"New name of the tag"`
+	}
+}
+
+type RenameTagOutput struct {
+	Body   TagOutput
+	Status int `json:"status" example:"200"`
+}
+
+func registerTagRoutes(humaAPI huma.API, service shortener.Shortener) {
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/tags",
+		Summary: "List tags",
+		Description: "Lists the tags on the user's personal links, or on an organization's links when orgId is set, " +
+			"with how many links carry each. Filter GET /mappings with tag to list the links.",
+	}, func(ctx context.Context, in *TagsInput) (*ListTagsOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		tags, err := service.ListTags(ctx, in.UserID, in.OrgID)
+		if err != nil {
+			return nil, tagError(err)
+		}
+
+		var out ListTagsOutput
+		out.Body.Tags = make([]TagOutput, len(tags))
+		for i, tag := range tags {
+			out.Body.Tags[i] = TagOutput{Name: tag.Tag, Links: tag.Links}
+		}
+		out.Status = http.StatusOK
+		return &out, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodPatch,
+		Path:    "/tags/{name}",
+		Summary: "Rename a tag",
+		Description: "Renames the tag on every personal link of the user, or on every link of an organization when orgId is set. " +
+			"Renaming to a tag that exists merges the two. Returns the new name and how many links were changed.",
+	}, func(ctx context.Context, in *RenameTagInput) (*RenameTagOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		tag, err := service.RenameTag(ctx, in.UserID, in.OrgID, in.Name, in.Body.Name)
+		if err != nil {
+			return nil, tagError(err)
+		}
+
+		return &RenameTagOutput{
+			Body:   TagOutput{Name: tag.Tag, Links: tag.Links},
+			Status: http.StatusOK,
+		}, nil
+	})
+}
+
+// tagError translates service errors into HTTP errors
+func tagError(err error) error {
+	switch {
+	case errors.Is(err, shortener.ErrInvalidTags):
+		return huma.NewError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, shortener.ErrTagNotFound), errors.Is(err, shortener.ErrOrganizationNotFound):
+		return huma.NewError(http.StatusNotFound, err.Error())
+	case errors.Is(err, shortener.ErrForbidden), errors.Is(err, shortener.ErrInsufficientRole):
+		return huma.NewError(http.StatusForbidden, err.Error())
+	default:
+		return huma.NewError(http.StatusInternalServerError, err.Error())
+	}
+}
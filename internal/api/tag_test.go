@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_Tags(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ListTags", "alice", "").Return([]model.TagCount{{Tag: "launch", Links: 3}, {Tag: "q3", Links: 1}}, nil)
+	mockService.On("RenameTag", "alice", "", "q3", "Q4").Return(&model.TagCount{Tag: "q4", Links: 1}, nil)
+	mockService.On("RenameTag", "alice", "", "missing", "other").Return(nil, shortener.ErrTagNotFound)
+	mockService.On("RenameTag", "vic", "org_abc", "q3", "q4").Return(nil, shortener.ErrInsufficientRole)
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tags?userId=alice", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ListTagsOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list.Body))
+	assert.Equal(t, []TagOutput{{Name: "launch", Links: 3}, {Name: "q3", Links: 1}}, list.Body.Tags)
+
+	rename := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = rename("/tags/q3?userId=alice", `{"name":"Q4"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var renamed TagOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &renamed))
+	assert.Equal(t, TagOutput{Name: "q4", Links: 1}, renamed)
+
+	assert.Equal(t, http.StatusNotFound, rename("/tags/missing?userId=alice", `{"name":"other"}`).Code)
+	assert.Equal(t, http.StatusForbidden, rename("/tags/q3?userId=vic&orgId=org_abc", `{"name":"q4"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ListMappingsByTag(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ListMappings", "alice", "", model.MappingFilter{Tag: "q3", Folder: "Sales"}).Return([]model.URLMapping{
+		{Code: "deck", Original: "https://example.com/deck", UserID: "alice", Tags: []string{"q3", "slides"}, Folder: "Sales"},
+	}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/mappings?userId=alice&tag=q3&folder=Sales", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list ListMappingsOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list.Body))
+	assert.Len(t, list.Body.Mappings, 1)
+	assert.Equal(t, []string{"q3", "slides"}, list.Body.Mappings[0].Tags)
+	assert.Equal(t, "Sales", list.Body.Mappings[0].Folder)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ShortenWithTags(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "alice", URL: "https://example.com", Tags: []string{"q3"}, Folder: "Sales"}).Return("abc123", nil)
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "alice", URL: "https://example.com", Tags: []string{"a,b"}}).Return("", shortener.ErrInvalidTags)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	shorten := func(body string) int {
+		req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, shorten(`{"userId":"alice","url":"https://example.com","tags":["q3"],"folder":"Sales"}`))
+	assert.Equal(t, http.StatusBadRequest, shorten(`{"userId":"alice","url":"https://example.com","tags":["a,b"]}`))
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// pages holds the HTML pages served to browsers, by file name
+var pages = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// renderPage executes the named page template
+func renderPage(name string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,360 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	// maxImportBytes bounds the size of an import upload
+	maxImportBytes = 10 << 20
+)
+
+// transferColumns is the column order of CSV exports; imports match columns by header name
+var transferColumns = []string{"code", "original_url", "created_at", "expires_at", "clicks", "redirect_type"}
+
+// transferRecord is the NDJSON representation of a mapping
+type transferRecord struct {
+	Code         string  `json:"code"`
+	Original     string  `json:"original_url"`
+	CreatedAt    string  `json:"created_at,omitempty"`
+	ExpiresAt    *string `json:"expires_at,omitempty"`
+	Clicks       int     `json:"clicks"`
+	RedirectType int     `json:"redirect_type,omitempty"`
+}
+
+type ExportMappingsInput struct {
+	UserID string `query:"userId"`
+	Format string `query:"format" enum:"csv,ndjson" default:"csv"`
+}
+
+type ImportMappingsInput struct {
+	UserID      string `query:"userId"`
+	Format      string `query:"format" enum:"csv,ndjson" doc:"Defaults to the request Content-Type"`
+	ContentType string `header:"Content-Type"`
+	RawBody     []byte `contentType:"text/csv"`
+}
+
+type ImportMappingsOutput struct {
+	Body   shortener.ImportResult
+	Status int `json:"status" example:"200"`
+}
+
+func registerTransferRoutes(humaAPI huma.API, service shortener.Shortener) {
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodGet,
+		Path:        "/mappings/export",
+		Summary:     "Export a user's URL mappings",
+		Description: "Streams every personal mapping of the user, leaving out organization links, as CSV or newline-delimited JSON.",
+	}, func(ctx context.Context, in *ExportMappingsInput) (*huma.StreamResponse, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		mappings, err := service.ListMappings(ctx, in.UserID, "", model.MappingFilter{})
+		if err != nil {
+			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		sort.SliceStable(mappings, func(i, j int) bool {
+			return mappings[i].CreatedAt.Before(mappings[j].CreatedAt)
+		})
+
+		return &huma.StreamResponse{
+			Body: func(hctx huma.Context) {
+				contentType, ext := "text/csv", "csv"
+				if in.Format == formatNDJSON {
+					contentType, ext = "application/x-ndjson", "ndjson"
+				}
+
+				hctx.SetHeader("Content-Type", contentType)
+				hctx.SetHeader("Content-Disposition", fmt.Sprintf(`attachment; filename="mappings.%s"`, ext))
+				hctx.SetStatus(http.StatusOK)
+
+				// Headers are already sent at this point, so a write error can only
+				// surface to the client as a truncated body
+				if in.Format == formatNDJSON {
+					_ = writeNDJSON(hctx.BodyWriter(), mappings)
+				} else {
+					_ = writeCSV(hctx.BodyWriter(), mappings)
+				}
+			},
+		}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:       http.MethodPost,
+		Path:         "/mappings/import",
+		Summary:      "Import URL mappings",
+		Description:  "Accepts CSV (with a header row) or newline-delimited JSON. Each row is validated independently and rejected rows are listed in the report.",
+		MaxBodyBytes: maxImportBytes,
+	}, func(ctx context.Context, in *ImportMappingsInput) (*ImportMappingsOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		format := in.Format
+		if format == "" {
+			format = formatFromContentType(in.ContentType)
+		}
+
+		var records []shortener.ImportRecord
+		var rowErrors []shortener.ImportRowError
+		var err error
+		if format == formatNDJSON {
+			records, rowErrors, err = readNDJSON(bytes.NewReader(in.RawBody))
+		} else {
+			records, rowErrors, err = readCSV(bytes.NewReader(in.RawBody))
+		}
+		if err != nil {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+
+		result := service.ImportMappings(ctx, in.UserID, records)
+		result.Failed += len(rowErrors)
+		result.Errors = append(result.Errors, rowErrors...)
+		sort.SliceStable(result.Errors, func(i, j int) bool {
+			return result.Errors[i].Row < result.Errors[j].Row
+		})
+
+		return &ImportMappingsOutput{Body: result, Status: http.StatusOK}, nil
+	})
+}
+
+func formatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return formatNDJSON
+	default:
+		return formatCSV
+	}
+}
+
+func writeCSV(w io.Writer, mappings []model.URLMapping) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(transferColumns); err != nil {
+		return err
+	}
+
+	for _, m := range mappings {
+		expiresAt := ""
+		if m.ExpiresAt != nil {
+			expiresAt = m.ExpiresAt.Format(time.RFC3339)
+		}
+		redirectType := ""
+		if m.RedirectType != 0 {
+			redirectType = strconv.Itoa(m.RedirectType)
+		}
+		err := cw.Write([]string{
+			m.Code,
+			m.Original,
+			m.CreatedAt.Format(time.RFC3339),
+			expiresAt,
+			strconv.Itoa(m.Clicks),
+			redirectType,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeNDJSON(w io.Writer, mappings []model.URLMapping) error {
+	enc := json.NewEncoder(w)
+	for _, m := range mappings {
+		record := transferRecord{
+			Code:         m.Code,
+			Original:     m.Original,
+			CreatedAt:    m.CreatedAt.Format(time.RFC3339),
+			Clicks:       m.Clicks,
+			RedirectType: m.RedirectType,
+		}
+		if m.ExpiresAt != nil {
+			expiresAt := m.ExpiresAt.Format(time.RFC3339)
+			record.ExpiresAt = &expiresAt
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCSV parses a CSV import. Rows that cannot be parsed are returned as row
+// errors; only a missing or malformed header fails the whole file.
+func readCSV(r io.Reader) ([]shortener.ImportRecord, []shortener.ImportRowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("import file is empty")
+		}
+		return nil, nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["original_url"]; !ok {
+		if i, ok := columns["url"]; ok {
+			columns["original_url"] = i
+		} else {
+			return nil, nil, errors.New("CSV header must include an original_url column")
+		}
+	}
+
+	var records []shortener.ImportRecord
+	var rowErrors []shortener.ImportRowError
+
+	for row := 1; ; row++ {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, shortener.ImportRowError{Row: row, Error: err.Error()})
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		record, err := parseTransferRecord(row, transferRecord{
+			Code:     field("code"),
+			Original: field("original_url"),
+		}, field("created_at"), field("expires_at"), field("clicks"))
+		if err == nil {
+			record.RedirectType, err = parseRedirectType(field("redirect_type"))
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, shortener.ImportRowError{Row: row, Code: field("code"), Error: err.Error()})
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, rowErrors, nil
+}
+
+// readNDJSON parses a newline-delimited JSON import, skipping blank lines
+func readNDJSON(r io.Reader) ([]shortener.ImportRecord, []shortener.ImportRowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportBytes)
+
+	var records []shortener.ImportRecord
+	var rowErrors []shortener.ImportRowError
+
+	row := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row++
+
+		var raw transferRecord
+		if err := json.Unmarshal(line, &raw); err != nil {
+			rowErrors = append(rowErrors, shortener.ImportRowError{Row: row, Error: "invalid JSON: " + err.Error()})
+			continue
+		}
+
+		expiresAt := ""
+		if raw.ExpiresAt != nil {
+			expiresAt = *raw.ExpiresAt
+		}
+
+		record, err := parseTransferRecord(row, raw, raw.CreatedAt, expiresAt, "")
+		if err != nil {
+			rowErrors = append(rowErrors, shortener.ImportRowError{Row: row, Code: raw.Code, Error: err.Error()})
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read import: %w", err)
+	}
+
+	return records, rowErrors, nil
+}
+
+// parseTransferRecord converts the textual fields of an import row. clicks is
+// only parsed when non-empty, otherwise raw.Clicks is kept.
+func parseTransferRecord(row int, raw transferRecord, createdAt, expiresAt, clicks string) (shortener.ImportRecord, error) {
+	record := shortener.ImportRecord{
+		Row:          row,
+		Code:         raw.Code,
+		Original:     raw.Original,
+		Clicks:       raw.Clicks,
+		RedirectType: raw.RedirectType,
+	}
+
+	if createdAt != "" {
+		t, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return record, fmt.Errorf("invalid created_at: %s", createdAt)
+		}
+		record.CreatedAt = &t
+	}
+
+	if expiresAt != "" {
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return record, fmt.Errorf("invalid expires_at: %s", expiresAt)
+		}
+		record.ExpiresAt = &t
+	}
+
+	if clicks != "" {
+		n, err := strconv.Atoi(clicks)
+		if err != nil {
+			return record, fmt.Errorf("invalid clicks: %s", clicks)
+		}
+		record.Clicks = n
+	}
+
+	return record, nil
+}
+
+// parseRedirectType parses the optional redirect_type CSV column
+func parseRedirectType(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid redirect_type: %s", raw)
+	}
+	return n, nil
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func transferMappings() []model.URLMapping {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(48 * time.Hour)
+
+	return []model.URLMapping{
+		{
+			Code:      "later1",
+			Original:  "https://example.com/later",
+			UserID:    "user123",
+			CreatedAt: createdAt.Add(time.Hour),
+			Clicks:    1,
+		},
+		{
+			Code:      "first1",
+			Original:  "https://example.com/first?a=1,b=2",
+			UserID:    "user123",
+			CreatedAt: createdAt,
+			ExpiresAt: &expiresAt,
+			Clicks:    42,
+		},
+	}
+}
+
+func TestRouter_ExportCSV(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ListMappings", "user123", "", model.MappingFilter{}).Return(transferMappings(), nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/export?userId=user123", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "mappings.csv")
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, transferColumns, records[0])
+	// Oldest mapping first
+	assert.Equal(t, []string{"first1", "https://example.com/first?a=1,b=2", "2025-01-02T03:04:05Z", "2025-01-04T03:04:05Z", "42", ""}, records[1])
+	assert.Equal(t, "later1", records[2][0])
+	assert.Equal(t, "", records[2][3])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ExportNDJSON(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ListMappings", "user123", "", model.MappingFilter{}).Return(transferMappings(), nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/export?userId=user123&format=ndjson", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var first transferRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "first1", first.Code)
+	assert.Equal(t, 42, first.Clicks)
+	require.NotNil(t, first.ExpiresAt)
+	assert.Equal(t, "2025-01-04T03:04:05Z", *first.ExpiresAt)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ExportMissingUser(t *testing.T) {
+	mockService := &MockShortenerService{}
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/export", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRouter_ImportCSV(t *testing.T) {
+	mockService := &MockShortenerService{}
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	expected := []shortener.ImportRecord{
+		{Row: 1, Code: "abc123", Original: "https://example.com/a", CreatedAt: &createdAt, Clicks: 7},
+		{Row: 3, Original: "https://example.com/c"},
+	}
+	mockService.On("ImportMappings", "user123", expected).Return(shortener.ImportResult{
+		Imported: 2,
+		Errors:   []shortener.ImportRowError{},
+	})
+
+	router := NewRouter(mockService)
+
+	body := "original_url,code,clicks,created_at\n" +
+		"https://example.com/a,abc123,7,2025-01-02T03:04:05Z\n" +
+		"https://example.com/b,bad,notanumber,\n" +
+		"https://example.com/c,,,\n"
+
+	req := httptest.NewRequest("POST", "/mappings/import?userId=user123", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result shortener.ImportResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 2, result.Errors[0].Row)
+	assert.Equal(t, "bad", result.Errors[0].Code)
+	assert.Contains(t, result.Errors[0].Error, "invalid clicks")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ImportNDJSON(t *testing.T) {
+	mockService := &MockShortenerService{}
+
+	mockService.On("ImportMappings", "user123", mock.MatchedBy(func(records []shortener.ImportRecord) bool {
+		return len(records) == 1 && records[0].Code == "xyz789" && records[0].ExpiresAt != nil && records[0].Clicks == 3
+	})).Return(shortener.ImportResult{
+		Imported: 1,
+		Errors:   []shortener.ImportRowError{},
+	})
+
+	router := NewRouter(mockService)
+
+	body := `{"code":"xyz789","original_url":"https://example.com/x","expires_at":"2030-01-01T00:00:00Z","clicks":3}` + "\n\n" +
+		`{not json}` + "\n"
+
+	req := httptest.NewRequest("POST", "/mappings/import?userId=user123", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result shortener.ImportResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 2, result.Errors[0].Row)
+	assert.Contains(t, result.Errors[0].Error, "invalid JSON")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ImportMissingColumn(t *testing.T) {
+	mockService := &MockShortenerService{}
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("POST", "/mappings/import?userId=user123&format=csv", strings.NewReader("code,clicks\nabc,1\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "original_url")
+}
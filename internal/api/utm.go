@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+// UTMParams is the UTM object of requests and responses
+type UTMParams struct {
+	Source   string `json:"source,omitempty" example:"newsletter"`
+	Medium   string `json:"medium,omitempty" example:"email"`
+	Campaign string `json:"campaign,omitempty" example:"spring_sale"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+type UTMTemplateOutput struct {
+	Name      string    `json:"name"`
+	UTM       UTMParams `json:"utm"`
+	CreatedAt string    `json:"created_at"`
+}
+
+type ListUTMTemplatesOutput struct {
+	Body struct {
+		Templates []UTMTemplateOutput `json:"templates"`
+	}
+	Status int `json:"status" example:"200"`
+}
+
+type UTMTemplateInput struct {
+	Name   string `path:"name"`
+	UserID string `query:"userId"`
+}
+
+type SaveUTMTemplateInput struct {
+	UTMTemplateInput
+	Body UTMParams
+}
+
+type SaveUTMTemplateOutput struct {
+	Body   UTMTemplateOutput
+	Status int `json:"status" example:"200"`
+}
+
+type DeleteUTMTemplateOutput struct {
+	Status int `json:"status" example:"204"`
+}
+
+func registerUTMRoutes(humaAPI huma.API, service shortener.Shortener) {
+	huma.Register(humaAPI, huma.Operation{
+		Method:  http.MethodGet,
+		Path:    "/utm-templates",
+		Summary: "List a user's UTM templates",
+	}, func(ctx context.Context, in *UserInput) (*ListUTMTemplatesOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		templates, err := service.ListUTMTemplates(ctx, in.UserID)
+		if err != nil {
+			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		var out ListUTMTemplatesOutput
+		out.Body.Templates = make([]UTMTemplateOutput, len(templates))
+		for i, template := range templates {
+			out.Body.Templates[i] = toUTMTemplateOutput(template)
+		}
+		out.Status = http.StatusOK
+		return &out, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:      http.MethodPut,
+		Path:        "/utm-templates/{name}",
+		Summary:     "Create or replace a UTM template",
+		Description: "Parameters are normalized the same way as on POST /shorten. Links already created from the template are not changed.",
+	}, func(ctx context.Context, in *SaveUTMTemplateInput) (*SaveUTMTemplateOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		template, err := service.SaveUTMTemplate(ctx, in.UserID, in.Name, in.Body.toModel())
+		if errors.Is(err, shortener.ErrInvalidUTMTemplate) {
+			return nil, huma.NewError(http.StatusBadRequest, err.Error())
+		}
+		if err != nil {
+			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return &SaveUTMTemplateOutput{Body: toUTMTemplateOutput(*template), Status: http.StatusOK}, nil
+	})
+
+	huma.Register(humaAPI, huma.Operation{
+		Method:        http.MethodDelete,
+		Path:          "/utm-templates/{name}",
+		Summary:       "Delete a UTM template",
+		DefaultStatus: http.StatusNoContent,
+	}, func(ctx context.Context, in *UTMTemplateInput) (*DeleteUTMTemplateOutput, error) {
+		if in.UserID == "" {
+			return nil, huma.NewError(http.StatusBadRequest, "userId is required")
+		}
+
+		err := service.DeleteUTMTemplate(ctx, in.UserID, in.Name)
+		if errors.Is(err, shortener.ErrUTMTemplateNotFound) {
+			return nil, huma.NewError(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return nil, huma.NewError(http.StatusInternalServerError, err.Error())
+		}
+
+		return &DeleteUTMTemplateOutput{Status: http.StatusNoContent}, nil
+	})
+}
+
+func (p UTMParams) toModel() model.UTM {
+	return model.UTM{
+		Source:   p.Source,
+		Medium:   p.Medium,
+		Campaign: p.Campaign,
+		Term:     p.Term,
+		Content:  p.Content,
+	}
+}
+
+// toUTMParams returns nil for empty parameters so they are left out of responses
+func toUTMParams(utm model.UTM) *UTMParams {
+	if utm.Empty() {
+		return nil
+	}
+	return &UTMParams{
+		Source:   utm.Source,
+		Medium:   utm.Medium,
+		Campaign: utm.Campaign,
+		Term:     utm.Term,
+		Content:  utm.Content,
+	}
+}
+
+func toUTMTemplateOutput(template model.UTMTemplate) UTMTemplateOutput {
+	return UTMTemplateOutput{
+		Name:      template.Name,
+		UTM:       *toUTMParams(template.UTM),
+		CreatedAt: template.CreatedAt.Format(time.RFC3339),
+	}
+}
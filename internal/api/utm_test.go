@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_ShortenWithUTM(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{
+		UserID:      "user123",
+		URL:         "https://example.com",
+		UTM:         model.UTM{Campaign: "Spring Sale"},
+		UTMTemplate: "email",
+	}).Return("abc123", nil)
+	mockService.On("Shorten", shortener.ShortenRequest{
+		UserID:      "user123",
+		URL:         "https://example.com",
+		UTMTemplate: "missing",
+	}).Return("", shortener.ErrUTMTemplateNotFound)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	body := `{"userId":"user123","url":"https://example.com","utm":{"campaign":"Spring Sale"},"utm_template":"email"}`
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body = `{"userId":"user123","url":"https://example.com","utm_template":"missing"}`
+	req = httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_MappingOutputIncludesUTM(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("ListMappings", "user123", "", model.MappingFilter{}).Return([]model.URLMapping{
+		{Code: "abc123", Original: "https://example.com?utm_source=newsletter", CreatedAt: time.Now(), UTM: model.UTM{Source: "newsletter"}},
+		{Code: "def456", Original: "https://example.com", CreatedAt: time.Now()},
+	}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings?userId=user123", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Mappings []URLMappingOutput `json:"mappings"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, &UTMParams{Source: "newsletter"}, response.Mappings[0].UTM)
+	assert.Nil(t, response.Mappings[1].UTM)
+}
+
+func TestRouter_UTMTemplates(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	template := model.UTMTemplate{UserID: "user123", Name: "email", UTM: model.UTM{Source: "newsletter", Medium: "email"}, CreatedAt: created}
+
+	mockService := &MockShortenerService{}
+	mockService.On("SaveUTMTemplate", "user123", "email", model.UTM{Source: "Newsletter", Medium: "email"}).Return(&template, nil)
+	mockService.On("SaveUTMTemplate", "user123", "empty", model.UTM{}).Return(nil, shortener.ErrInvalidUTMTemplate)
+	mockService.On("ListUTMTemplates", "user123").Return([]model.UTMTemplate{template}, nil)
+	mockService.On("DeleteUTMTemplate", "user123", "email").Return(nil)
+	mockService.On("DeleteUTMTemplate", "user123", "missing").Return(shortener.ErrUTMTemplateNotFound)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("PUT", "/utm-templates/email?userId=user123", bytes.NewBufferString(`{"source":"Newsletter","medium":"email"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var saved UTMTemplateOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+	assert.Equal(t, UTMTemplateOutput{Name: "email", UTM: UTMParams{Source: "newsletter", Medium: "email"}, CreatedAt: "2026-03-01T12:00:00Z"}, saved)
+
+	req = httptest.NewRequest("PUT", "/utm-templates/empty?userId=user123", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest("GET", "/utm-templates?userId=user123", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Templates []UTMTemplateOutput `json:"templates"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Templates, 1)
+
+	req = httptest.NewRequest("DELETE", "/utm-templates/email?userId=user123", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/utm-templates/missing?userId=user123", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("GET", "/utm-templates", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
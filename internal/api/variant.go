@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+const (
+	// variantCookie remembers the variant a visitor got from a sticky split
+	// link. It is scoped to the link's path, so each link has its own.
+	variantCookie       = "go_short_variant"
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// Variant is a destination of a split link in requests
+type Variant struct {
+	Name   string `json:"name" example:"a" doc:"1 to 32 letters, digits, '-' or '_', unique within the link"`
+	URL    string `json:"url" example:"https://example.com/landing-a"`
+	Weight int    `json:"weight" minimum:"0" maximum:"1000" example:"50" doc:"Share of traffic relative to the other variants, 0 pauses the variant"`
+}
+
+// VariantOutput is a destination of a split link and the clicks it served
+type VariantOutput struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Clicks int    `json:"clicks"`
+}
+
+// toVariantModels converts the variants of a request, keeping nil as nil
+func toVariantModels(variants []Variant) []model.Variant {
+	if variants == nil {
+		return nil
+	}
+	out := make([]model.Variant, len(variants))
+	for i, v := range variants {
+		out[i] = model.Variant{Name: v.Name, URL: v.URL, Weight: v.Weight}
+	}
+	return out
+}
+
+// toVariantOutputs lists the variants of mapping with their clicks
+func toVariantOutputs(mapping model.URLMapping) []VariantOutput {
+	if len(mapping.Variants) == 0 {
+		return nil
+	}
+	out := make([]VariantOutput, len(mapping.Variants))
+	for i, v := range mapping.Variants {
+		out[i] = VariantOutput{Name: v.Name, URL: v.URL, Weight: v.Weight, Clicks: mapping.VariantClicks[v.Name]}
+	}
+	return out
+}
+
+// variantSetCookie returns the Set-Cookie header that keeps a visitor on
+// variant of the split link code
+func variantSetCookie(code, variant string) string {
+	cookie := http.Cookie{
+		Name:     variantCookie,
+		Value:    variant,
+		Path:     "/" + code,
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return cookie.String()
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/shortener"
+)
+
+func TestRouter_ShortenVariants(t *testing.T) {
+	variants := []model.Variant{{Name: "a", URL: "https://example.com/a", Weight: 50}, {Name: "b", URL: "https://example.com/b", Weight: 50}}
+
+	mockService := &MockShortenerService{}
+	mockService.On("Shorten", shortener.ShortenRequest{UserID: "user123", URL: "https://example.com", Variants: variants, StickyVariants: true}).Return("abc123", nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	body := `{"userId":"user123","url":"https://example.com","sticky_variants":true,"variants":[` +
+		`{"name":"a","url":"https://example.com/a","weight":50},{"name":"b","url":"https://example.com/b","weight":50}]}`
+	req := httptest.NewRequest("POST", "/shorten", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_ResolveStickyVariant(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "split", ClientID: testClientID}).
+		Return(&shortener.Resolution{URL: "https://example.com/b", StatusCode: http.StatusFound, NoStore: true, Variant: "b", StickyVariant: true}, nil)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "split", ClientID: testClientID, Variant: "b"}).
+		Return(&shortener.Resolution{URL: "https://example.com/b", StatusCode: http.StatusFound, NoStore: true, Variant: "b", StickyVariant: true}, nil)
+	mockService.On("Resolve", shortener.ResolveRequest{Code: "plain", ClientID: testClientID}).
+		Return(&shortener.Resolution{URL: "https://example.com/a", StatusCode: http.StatusFound, NoStore: true, Variant: "a"}, nil)
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/split", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "go_short_variant", cookies[0].Name)
+		assert.Equal(t, "b", cookies[0].Value)
+		assert.Equal(t, "/split", cookies[0].Path)
+		assert.True(t, cookies[0].HttpOnly)
+	}
+
+	// The cookie is passed back to the service on the next visit
+	req = httptest.NewRequest("GET", "/split", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	// Links that are not sticky set no cookie
+	req = httptest.NewRequest("GET", "/plain", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRouter_MappingStatsVariants(t *testing.T) {
+	mockService := &MockShortenerService{}
+	mockService.On("GetMapping", "user123", "", "split").Return(&model.URLMapping{
+		Code:      "split",
+		Original:  "https://example.com",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+		Clicks:    3,
+		Variants: []model.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 50},
+			{Name: "b", URL: "https://example.com/b", Weight: 50},
+		},
+		VariantClicks: map[string]int{"a": 2, "b": 1, "removed": 4},
+	}, nil)
+	mockService.On("GetBaseURL").Return("https://short.url")
+
+	router := NewRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/mappings/split/stats?userId=user123", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response URLMappingOutput
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []VariantOutput{
+		{Name: "a", URL: "https://example.com/a", Weight: 50, Clicks: 2},
+		{Name: "b", URL: "https://example.com/b", Weight: 50, Clicks: 1},
+	}, response.Variants)
+}
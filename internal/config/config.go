@@ -3,17 +3,23 @@ package config
 import (
 	"fmt"
 	"log"
+	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	App      AppConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	App       AppConfig
+	Logging   LoggingConfig
+	Telemetry TelemetryConfig
 }
 
 type ServerConfig struct {
@@ -22,18 +28,70 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// HealthCheckTimeout bounds each dependency check of the readiness probe
+	HealthCheckTimeout time.Duration
+	// ShutdownDrainDelay is how long the server keeps serving after readiness
+	// starts failing, giving load balancers time to stop routing to it
+	ShutdownDrainDelay time.Duration
+	// ShutdownTimeout bounds the whole shutdown, including draining
+	// background work
+	ShutdownTimeout time.Duration
 }
 
 type DatabaseConfig struct {
 	Type             string // "memory", "postgres", "redis"
 	ConnectionString string
+	AutoMigrate      bool // apply pending migrations when the store is opened
 }
 
 type AppConfig struct {
 	BaseURL         string
 	Environment     string
-	LogLevel        string
 	ShortCodeLength int
+	AdminToken      string // enables the /admin endpoints when set
+	// DefaultRedirectType is the redirect status of links that don't set one
+	DefaultRedirectType int
+	// RedirectCacheMaxAge is how long clients may cache permanent redirects
+	RedirectCacheMaxAge time.Duration
+	// PasswordMaxAttempts is how many wrong passwords a client may try per
+	// protected link within PasswordAttemptWindow, 0 disables the limit
+	PasswordMaxAttempts   int
+	PasswordAttemptWindow time.Duration
+	// TrustedProxies lists the addresses or CIDR ranges, separated by commas,
+	// of the proxies in front of the server. Only requests from them may set
+	// the client address password attempts are counted by, through
+	// X-Forwarded-For or X-Real-IP.
+	TrustedProxies string
+	// PendingLinkURL is where links that are not active yet redirect to. When
+	// empty they get a built-in placeholder.
+	PendingLinkURL string
+	// QuotaActiveLinks and QuotaMonthlyRedirects are the default limits of
+	// each user and organization, 0 means no limit
+	QuotaActiveLinks      int
+	QuotaMonthlyRedirects int
+	// MetadataFetch makes the title and preview image of new links' pages be
+	// fetched in the background, giving up after MetadataFetchTimeout and
+	// reading at most MetadataMaxBytes of each page. It is off by default, as
+	// it makes the server connect to any address users shorten.
+	MetadataFetch        bool
+	MetadataFetchTimeout time.Duration
+	MetadataMaxBytes     int
+}
+
+type LoggingConfig struct {
+	Level      string // "debug", "info", "warn", "error"
+	Format     string // "json", "text"
+	File       string // log file path, stdout when empty
+	MaxSizeMB  int    // size at which the log file is rotated
+	MaxBackups int    // rotated files kept, 0 keeps all
+	MaxAgeDays int    // days rotated files are kept, 0 keeps all
+}
+
+type TelemetryConfig struct {
+	Exporter    string // "none", "stdout", "otlp"
+	Endpoint    string // OTLP/HTTP endpoint, e.g. http://localhost:4318
+	ServiceName string
+	SampleRatio float64 // fraction of new traces that are recorded, 0 to 1
 }
 
 // Load loads configuration from environment variables
@@ -44,22 +102,39 @@ func Load() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port:         getEnv("PORT", "4000"),
-			Host:         getEnv("HOST", "0.0.0.0"),
-			ReadTimeout:  getDurationEnv("READ_TIMEOUT", 30*time.Second),
-			WriteTimeout: getDurationEnv("WRITE_TIMEOUT", 30*time.Second),
-			IdleTimeout:  getDurationEnv("IDLE_TIMEOUT", 60*time.Second),
+			Port:               getEnv("PORT", "4000"),
+			Host:               getEnv("HOST", "0.0.0.0"),
+			ReadTimeout:        getDurationEnv("READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:       getDurationEnv("WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:        getDurationEnv("IDLE_TIMEOUT", 60*time.Second),
+			HealthCheckTimeout: getDurationEnv("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			ShutdownDrainDelay: getDurationEnv("SHUTDOWN_DRAIN_DELAY", 0),
+			ShutdownTimeout:    getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
 		},
 		Database: DatabaseConfig{
 			Type:             getEnv("DB_TYPE", "postgres"),
 			ConnectionString: buildDbConnectionString(),
+			AutoMigrate:      getBoolEnv("DB_AUTO_MIGRATE", true),
 		},
 		App: AppConfig{
-			BaseURL:         getEnv("BASE_URL", fmt.Sprintf("http://%s:%s", getEnv("HOST", "0.0.0.0"), getEnv("PORT", "4000"))),
-			Environment:     getEnv("ENVIRONMENT", "development"),
-			LogLevel:        getEnv("LOG_LEVEL", "info"),
-			ShortCodeLength: getIntEnv("SHORT_CODE_LENGTH", 6),
+			BaseURL:               getEnv("BASE_URL", fmt.Sprintf("http://%s:%s", getEnv("HOST", "0.0.0.0"), getEnv("PORT", "4000"))),
+			Environment:           getEnv("ENVIRONMENT", "development"),
+			ShortCodeLength:       getIntEnv("SHORT_CODE_LENGTH", 6),
+			AdminToken:            os.Getenv("ADMIN_TOKEN"),
+			DefaultRedirectType:   getIntEnv("DEFAULT_REDIRECT_TYPE", 302),
+			RedirectCacheMaxAge:   getDurationEnv("REDIRECT_CACHE_MAX_AGE", 24*time.Hour),
+			PasswordMaxAttempts:   getIntEnv("PASSWORD_MAX_ATTEMPTS", 5),
+			PasswordAttemptWindow: getDurationEnv("PASSWORD_ATTEMPT_WINDOW", 15*time.Minute),
+			TrustedProxies:        os.Getenv("TRUSTED_PROXIES"),
+			PendingLinkURL:        os.Getenv("PENDING_LINK_URL"),
+			QuotaActiveLinks:      getIntEnv("QUOTA_ACTIVE_LINKS", 0),
+			QuotaMonthlyRedirects: getIntEnv("QUOTA_MONTHLY_REDIRECTS", 0),
+			MetadataFetch:         getBoolEnv("METADATA_FETCH", false),
+			MetadataFetchTimeout:  getDurationEnv("METADATA_FETCH_TIMEOUT", 5*time.Second),
+			MetadataMaxBytes:      getIntEnv("METADATA_MAX_BYTES", 1<<20),
 		},
+		Logging:   loadLoggingConfig(),
+		Telemetry: loadTelemetryConfig(),
 	}
 
 	if err := config.Validate(); err != nil {
@@ -77,22 +152,39 @@ func LoadForTest() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port:         getEnv("PORT", "4000"),
-			Host:         getEnv("HOST", "0.0.0.0"),
-			ReadTimeout:  getDurationEnv("READ_TIMEOUT", 30*time.Second),
-			WriteTimeout: getDurationEnv("WRITE_TIMEOUT", 30*time.Second),
-			IdleTimeout:  getDurationEnv("IDLE_TIMEOUT", 60*time.Second),
+			Port:               getEnv("PORT", "4000"),
+			Host:               getEnv("HOST", "0.0.0.0"),
+			ReadTimeout:        getDurationEnv("READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:       getDurationEnv("WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:        getDurationEnv("IDLE_TIMEOUT", 60*time.Second),
+			HealthCheckTimeout: getDurationEnv("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			ShutdownDrainDelay: getDurationEnv("SHUTDOWN_DRAIN_DELAY", 0),
+			ShutdownTimeout:    getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
 		},
 		Database: DatabaseConfig{
 			Type:             getEnv("DB_TYPE", "memory"),
 			ConnectionString: buildDbConnectionString(),
+			AutoMigrate:      getBoolEnv("DB_AUTO_MIGRATE", true),
 		},
 		App: AppConfig{
-			BaseURL:         getEnv("BASE_URL", "http://localhost:4000"),
-			Environment:     getEnv("ENVIRONMENT", "development"),
-			LogLevel:        getEnv("LOG_LEVEL", "info"),
-			ShortCodeLength: getIntEnv("SHORT_CODE_LENGTH", 6),
+			BaseURL:               getEnv("BASE_URL", "http://localhost:4000"),
+			Environment:           getEnv("ENVIRONMENT", "development"),
+			ShortCodeLength:       getIntEnv("SHORT_CODE_LENGTH", 6),
+			AdminToken:            os.Getenv("ADMIN_TOKEN"),
+			DefaultRedirectType:   getIntEnv("DEFAULT_REDIRECT_TYPE", 302),
+			RedirectCacheMaxAge:   getDurationEnv("REDIRECT_CACHE_MAX_AGE", 24*time.Hour),
+			PasswordMaxAttempts:   getIntEnv("PASSWORD_MAX_ATTEMPTS", 5),
+			PasswordAttemptWindow: getDurationEnv("PASSWORD_ATTEMPT_WINDOW", 15*time.Minute),
+			TrustedProxies:        os.Getenv("TRUSTED_PROXIES"),
+			PendingLinkURL:        os.Getenv("PENDING_LINK_URL"),
+			QuotaActiveLinks:      getIntEnv("QUOTA_ACTIVE_LINKS", 0),
+			QuotaMonthlyRedirects: getIntEnv("QUOTA_MONTHLY_REDIRECTS", 0),
+			MetadataFetch:         getBoolEnv("METADATA_FETCH", false),
+			MetadataFetchTimeout:  getDurationEnv("METADATA_FETCH_TIMEOUT", 5*time.Second),
+			MetadataMaxBytes:      getIntEnv("METADATA_MAX_BYTES", 1<<20),
 		},
+		Logging:   loadLoggingConfig(),
+		Telemetry: loadTelemetryConfig(),
 	}
 
 	if err := config.Validate(); err != nil {
@@ -102,6 +194,26 @@ func LoadForTest() (*Config, error) {
 	return config, nil
 }
 
+func loadLoggingConfig() LoggingConfig {
+	return LoggingConfig{
+		Level:      getEnv("LOG_LEVEL", "info"),
+		Format:     getEnv("LOG_FORMAT", "json"),
+		File:       os.Getenv("LOG_FILE"),
+		MaxSizeMB:  getIntEnv("LOG_MAX_SIZE_MB", 100),
+		MaxBackups: getIntEnv("LOG_MAX_BACKUPS", 5),
+		MaxAgeDays: getIntEnv("LOG_MAX_AGE_DAYS", 28),
+	}
+}
+
+func loadTelemetryConfig() TelemetryConfig {
+	return TelemetryConfig{
+		Exporter:    getEnv("TRACING_EXPORTER", "none"),
+		Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+		ServiceName: getEnv("OTEL_SERVICE_NAME", "go_short"),
+		SampleRatio: getFloatEnv("TRACING_SAMPLE_RATIO", 1.0),
+	}
+}
+
 func buildDbConnectionString() string {
 	// If a full connection string is provided, use it
 	if conn := os.Getenv("DB_CONNECTION_STRING"); conn != "" {
@@ -133,9 +245,108 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("SHORT_CODE_LENGTH must be between 3 and 20")
 	}
 
+	if c.Logging.Level != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+			return fmt.Errorf("LOG_LEVEL must be one of debug, info, warn, error")
+		}
+	}
+
+	switch c.App.DefaultRedirectType {
+	case 0, 301, 302, 307, 308:
+	default:
+		return fmt.Errorf("DEFAULT_REDIRECT_TYPE must be one of 301, 302, 307, 308")
+	}
+
+	if c.App.RedirectCacheMaxAge < 0 {
+		return fmt.Errorf("REDIRECT_CACHE_MAX_AGE must not be negative")
+	}
+
+	if c.App.PasswordMaxAttempts < 0 || c.App.PasswordAttemptWindow < 0 {
+		return fmt.Errorf("PASSWORD_MAX_ATTEMPTS and PASSWORD_ATTEMPT_WINDOW must not be negative")
+	}
+
+	if c.App.PasswordMaxAttempts > 0 && c.App.PasswordAttemptWindow == 0 {
+		return fmt.Errorf("PASSWORD_ATTEMPT_WINDOW is required when PASSWORD_MAX_ATTEMPTS is set")
+	}
+
+	if _, err := c.App.TrustedProxyPrefixes(); err != nil {
+		return fmt.Errorf("TRUSTED_PROXIES must be a comma-separated list of IP addresses or CIDR ranges: %w", err)
+	}
+
+	if c.App.QuotaActiveLinks < 0 || c.App.QuotaMonthlyRedirects < 0 {
+		return fmt.Errorf("QUOTA_ACTIVE_LINKS and QUOTA_MONTHLY_REDIRECTS must not be negative")
+	}
+
+	if c.App.MetadataFetch && (c.App.MetadataFetchTimeout <= 0 || c.App.MetadataMaxBytes <= 0) {
+		return fmt.Errorf("METADATA_FETCH_TIMEOUT and METADATA_MAX_BYTES must be positive when METADATA_FETCH is enabled")
+	}
+
+	if c.App.PendingLinkURL != "" {
+		u, err := url.Parse(c.App.PendingLinkURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("PENDING_LINK_URL must be an absolute http(s) URL")
+		}
+	}
+
+	if c.Server.ShutdownTimeout < 0 || c.Server.ShutdownDrainDelay < 0 {
+		return fmt.Errorf("SHUTDOWN_TIMEOUT and SHUTDOWN_DRAIN_DELAY must not be negative")
+	}
+
+	if c.Server.ShutdownTimeout > 0 && c.Server.ShutdownDrainDelay >= c.Server.ShutdownTimeout {
+		return fmt.Errorf("SHUTDOWN_DRAIN_DELAY must be shorter than SHUTDOWN_TIMEOUT")
+	}
+
+	switch c.Logging.Format {
+	case "", "json", "text":
+	default:
+		return fmt.Errorf("LOG_FORMAT must be one of json, text")
+	}
+
+	if c.Logging.File != "" && c.Logging.MaxSizeMB <= 0 {
+		return fmt.Errorf("LOG_MAX_SIZE_MB must be positive")
+	}
+
+	switch c.Telemetry.Exporter {
+	case "", "none", "stdout", "otlp":
+	default:
+		return fmt.Errorf("TRACING_EXPORTER must be one of none, stdout, otlp")
+	}
+
+	if c.Telemetry.SampleRatio < 0 || c.Telemetry.SampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
 	return nil
 }
 
+// TrustedProxyPrefixes parses TrustedProxies. Single addresses become
+// prefixes that only contain them.
+func (a AppConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(a.TrustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
 func (c *Config) GetServerAddress() string {
 	return fmt.Sprintf("%s:%s", c.Server.Host, c.Server.Port)
 }
@@ -181,6 +392,16 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+		log.Printf("Invalid float value for %s, using default: %v", key, defaultValue)
+	}
+	return defaultValue
+}
+
 func getBoolEnv(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
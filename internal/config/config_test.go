@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net/netip"
 	"os"
 	"testing"
 	"time"
@@ -15,12 +16,31 @@ func TestLoad_DefaultValues(t *testing.T) {
 	os.Unsetenv("HOST")
 	os.Unsetenv("ENVIRONMENT")
 	os.Unsetenv("LOG_LEVEL")
+	os.Unsetenv("LOG_FORMAT")
+	os.Unsetenv("LOG_FILE")
 	os.Unsetenv("SHORT_CODE_LENGTH")
 	os.Unsetenv("READ_TIMEOUT")
 	os.Unsetenv("WRITE_TIMEOUT")
 	os.Unsetenv("IDLE_TIMEOUT")
+	os.Unsetenv("HEALTH_CHECK_TIMEOUT")
+	os.Unsetenv("SHUTDOWN_DRAIN_DELAY")
+	os.Unsetenv("SHUTDOWN_TIMEOUT")
+	os.Unsetenv("DEFAULT_REDIRECT_TYPE")
+	os.Unsetenv("REDIRECT_CACHE_MAX_AGE")
+	os.Unsetenv("PASSWORD_MAX_ATTEMPTS")
+	os.Unsetenv("PASSWORD_ATTEMPT_WINDOW")
+	os.Unsetenv("PENDING_LINK_URL")
+	os.Unsetenv("QUOTA_ACTIVE_LINKS")
+	os.Unsetenv("QUOTA_MONTHLY_REDIRECTS")
+	os.Unsetenv("METADATA_FETCH")
+	os.Unsetenv("METADATA_FETCH_TIMEOUT")
+	os.Unsetenv("METADATA_MAX_BYTES")
 	os.Unsetenv("DB_TYPE")
 	os.Unsetenv("DB_CONNECTION_STRING")
+	os.Unsetenv("DB_AUTO_MIGRATE")
+	os.Unsetenv("TRACING_EXPORTER")
+	os.Unsetenv("TRACING_SAMPLE_RATIO")
+	os.Unsetenv("OTEL_SERVICE_NAME")
 
 	// Set required environment variable
 	os.Setenv("BASE_URL", "https://short.url")
@@ -36,11 +56,27 @@ func TestLoad_DefaultValues(t *testing.T) {
 	assert.Equal(t, 30*time.Second, cfg.Server.ReadTimeout)
 	assert.Equal(t, 30*time.Second, cfg.Server.WriteTimeout)
 	assert.Equal(t, 60*time.Second, cfg.Server.IdleTimeout)
+	assert.Equal(t, 2*time.Second, cfg.Server.HealthCheckTimeout)
+	assert.Equal(t, time.Duration(0), cfg.Server.ShutdownDrainDelay)
+	assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout)
 	assert.Equal(t, "memory", cfg.Database.Type)
+	assert.True(t, cfg.Database.AutoMigrate)
 	assert.Equal(t, "https://short.url", cfg.App.BaseURL)
 	assert.Equal(t, "development", cfg.App.Environment)
-	assert.Equal(t, "info", cfg.App.LogLevel)
 	assert.Equal(t, 6, cfg.App.ShortCodeLength)
+	assert.Equal(t, 302, cfg.App.DefaultRedirectType)
+	assert.Equal(t, 24*time.Hour, cfg.App.RedirectCacheMaxAge)
+	assert.Equal(t, 5, cfg.App.PasswordMaxAttempts)
+	assert.Equal(t, 15*time.Minute, cfg.App.PasswordAttemptWindow)
+	assert.False(t, cfg.App.MetadataFetch)
+	assert.Equal(t, 5*time.Second, cfg.App.MetadataFetchTimeout)
+	assert.Equal(t, 1<<20, cfg.App.MetadataMaxBytes)
+	assert.Equal(t, "info", cfg.Logging.Level)
+	assert.Equal(t, "json", cfg.Logging.Format)
+	assert.Equal(t, "", cfg.Logging.File)
+	assert.Equal(t, "none", cfg.Telemetry.Exporter)
+	assert.Equal(t, "go_short", cfg.Telemetry.ServiceName)
+	assert.Equal(t, 1.0, cfg.Telemetry.SampleRatio)
 }
 
 func TestLoad_CustomValues(t *testing.T) {
@@ -50,12 +86,16 @@ func TestLoad_CustomValues(t *testing.T) {
 	os.Setenv("HOST", "localhost")
 	os.Setenv("ENVIRONMENT", "production")
 	os.Setenv("LOG_LEVEL", "debug")
+	os.Setenv("LOG_FORMAT", "text")
+	os.Setenv("DEFAULT_REDIRECT_TYPE", "301")
+	os.Setenv("LOG_FILE", "/var/log/go_short.log")
 	os.Setenv("SHORT_CODE_LENGTH", "8")
 	os.Setenv("READ_TIMEOUT", "60s")
 	os.Setenv("WRITE_TIMEOUT", "60s")
 	os.Setenv("IDLE_TIMEOUT", "120s")
 	os.Setenv("DB_TYPE", "postgres")
 	os.Setenv("DB_CONNECTION_STRING", "postgres://user:password@db:5432/shorten")
+	os.Setenv("DB_AUTO_MIGRATE", "false")
 
 	cfg, err := LoadForTest()
 
@@ -70,10 +110,14 @@ func TestLoad_CustomValues(t *testing.T) {
 	assert.Equal(t, 120*time.Second, cfg.Server.IdleTimeout)
 	assert.Equal(t, "postgres", cfg.Database.Type)
 	assert.Equal(t, "postgres://user:password@db:5432/shorten", cfg.Database.ConnectionString)
+	assert.False(t, cfg.Database.AutoMigrate)
 	assert.Equal(t, "https://custom.url", cfg.App.BaseURL)
 	assert.Equal(t, "production", cfg.App.Environment)
-	assert.Equal(t, "debug", cfg.App.LogLevel)
 	assert.Equal(t, 8, cfg.App.ShortCodeLength)
+	assert.Equal(t, "debug", cfg.Logging.Level)
+	assert.Equal(t, "text", cfg.Logging.Format)
+	assert.Equal(t, 301, cfg.App.DefaultRedirectType)
+	assert.Equal(t, "/var/log/go_short.log", cfg.Logging.File)
 }
 
 func TestLoad_InvalidInteger(t *testing.T) {
@@ -181,6 +225,154 @@ func TestValidate_InvalidShortCodeLength(t *testing.T) {
 	}
 }
 
+func TestValidate_DefaultRedirectType(t *testing.T) {
+	for status, valid := range map[int]bool{301: true, 302: true, 307: true, 308: true, 303: false, 200: false} {
+		cfg := &Config{
+			Server: ServerConfig{Port: "4000"},
+			App: AppConfig{
+				BaseURL:             "https://short.url",
+				ShortCodeLength:     6,
+				DefaultRedirectType: status,
+			},
+		}
+
+		err := cfg.Validate()
+		if valid {
+			assert.NoError(t, err, status)
+		} else {
+			assert.ErrorContains(t, err, "DEFAULT_REDIRECT_TYPE", status)
+		}
+	}
+}
+
+func TestValidate_PasswordAttempts(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		window      time.Duration
+		wantErr     string
+	}{
+		{"limited", 5, 15 * time.Minute, ""},
+		{"disabled", 0, 0, ""},
+		{"negative attempts", -1, time.Minute, "PASSWORD_MAX_ATTEMPTS"},
+		{"missing window", 5, 0, "PASSWORD_ATTEMPT_WINDOW is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: "4000"},
+				App: AppConfig{
+					BaseURL:               "https://short.url",
+					ShortCodeLength:       6,
+					PasswordMaxAttempts:   tt.maxAttempts,
+					PasswordAttemptWindow: tt.window,
+				},
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_TrustedProxies(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: "4000"},
+		App:    AppConfig{BaseURL: "https://short.url", ShortCodeLength: 6, TrustedProxies: "10.0.0.0/8, 192.168.1.10,::1"},
+	}
+	assert.NoError(t, cfg.Validate())
+	prefixes, err := cfg.App.TrustedProxyPrefixes()
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.10/32"),
+		netip.MustParsePrefix("::1/128"),
+	}, prefixes)
+
+	cfg.App.TrustedProxies = "10.0.0.0/8,ingress"
+	assert.ErrorContains(t, cfg.Validate(), "TRUSTED_PROXIES")
+}
+
+func TestValidate_Logging(t *testing.T) {
+	tests := []struct {
+		name    string
+		logging LoggingConfig
+		wantErr string
+	}{
+		{"defaults", LoggingConfig{}, ""},
+		{"text debug", LoggingConfig{Level: "debug", Format: "text"}, ""},
+		{"file", LoggingConfig{Level: "WARN", Format: "json", File: "app.log", MaxSizeMB: 10}, ""},
+		{"unknown level", LoggingConfig{Level: "verbose"}, "LOG_LEVEL"},
+		{"unknown format", LoggingConfig{Format: "xml"}, "LOG_FORMAT"},
+		{"file without size", LoggingConfig{File: "app.log"}, "LOG_MAX_SIZE_MB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: "4000"},
+				App: AppConfig{
+					BaseURL:         "https://short.url",
+					ShortCodeLength: 6,
+				},
+				Logging: tt.logging,
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_Telemetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		ratio    float64
+		wantErr  string
+	}{
+		{"disabled", "none", 1, ""},
+		{"stdout", "stdout", 0.5, ""},
+		{"otlp", "otlp", 0, ""},
+		{"unknown exporter", "jaeger", 1, "TRACING_EXPORTER"},
+		{"ratio too high", "otlp", 1.5, "TRACING_SAMPLE_RATIO"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Port: "4000"},
+				App: AppConfig{
+					BaseURL:         "https://short.url",
+					ShortCodeLength: 6,
+				},
+				Telemetry: TelemetryConfig{
+					Exporter:    tt.exporter,
+					SampleRatio: tt.ratio,
+				},
+			}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestGetServerAddress(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{
@@ -221,3 +413,66 @@ func TestEnvironmentHelpers(t *testing.T) {
 		})
 	}
 }
+
+func TestValidate_PendingLinkURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"", true},
+		{"https://example.com/coming-soon", true},
+		{"/coming-soon", false},
+		{"ftp://example.com", false},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{
+			Server: ServerConfig{Port: "4000"},
+			App:    AppConfig{BaseURL: "https://short.url", ShortCodeLength: 6, PendingLinkURL: tt.url},
+		}
+
+		err := cfg.Validate()
+		if tt.valid {
+			assert.NoError(t, err, tt.url)
+		} else {
+			assert.ErrorContains(t, err, "PENDING_LINK_URL", tt.url)
+		}
+	}
+}
+
+func TestValidate_Quotas(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: "4000"},
+		App: AppConfig{
+			BaseURL:               "https://short.url",
+			ShortCodeLength:       6,
+			QuotaActiveLinks:      100,
+			QuotaMonthlyRedirects: 10000,
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.App.QuotaMonthlyRedirects = -1
+	assert.ErrorContains(t, cfg.Validate(), "QUOTA_MONTHLY_REDIRECTS")
+}
+
+func TestValidate_MetadataFetch(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Port: "4000"},
+		App: AppConfig{
+			BaseURL:              "https://short.url",
+			ShortCodeLength:      6,
+			MetadataFetch:        true,
+			MetadataFetchTimeout: 5 * time.Second,
+			MetadataMaxBytes:     1 << 20,
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.App.MetadataFetchTimeout = 0
+	assert.ErrorContains(t, cfg.Validate(), "METADATA_FETCH_TIMEOUT")
+
+	// The limits do not matter while fetching is off
+	cfg.App.MetadataFetch = false
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,137 @@
+// Package health runs the dependency checks behind the liveness and readiness
+// probes.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp           = "up"
+	StatusDown         = "down"
+	StatusShuttingDown = "shutting_down"
+
+	// DefaultTimeout bounds a check that does not set its own timeout
+	DefaultTimeout = 2 * time.Second
+)
+
+// Check is a named dependency check
+type Check struct {
+	Name    string
+	Timeout time.Duration
+	Func    func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status     string `json:"status" example:"up"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of all checks, Status is up only if every check is
+type Report struct {
+	Status string                 `json:"status" example:"up"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Up reports whether the report is healthy
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+// Checker runs the readiness checks and tracks whether the process is
+// shutting down, in which case it is never ready
+type Checker struct {
+	checks       []Check
+	shuttingDown atomic.Bool
+}
+
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{checks: checks}
+}
+
+// SetShuttingDown makes every later readiness report fail so load balancers
+// stop sending traffic before the server stops accepting it
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether SetShuttingDown was called
+func (c *Checker) ShuttingDown() bool {
+	return c.shuttingDown.Load()
+}
+
+// Live reports that the process is able to serve requests. It does not look
+// at dependencies so a database outage does not get the pod restarted.
+func (c *Checker) Live() Report {
+	return Report{Status: StatusUp}
+}
+
+// Ready runs every check concurrently, each bounded by its timeout
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.ShuttingDown() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func run(ctx context.Context, check Check) CheckResult {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- check.Func(ctx)
+	}()
+
+	// Don't trust the check to honor the context, a hung dependency must not
+	// hang the probe
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusUp, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out after " + timeout.String()
+		}
+	}
+	return result
+}
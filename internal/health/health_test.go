@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_ReadyAllUp(t *testing.T) {
+	checker := NewChecker(
+		Check{Name: "storage", Func: func(context.Context) error { return nil }},
+		Check{Name: "cache", Func: func(context.Context) error { return nil }},
+	)
+
+	report := checker.Ready(context.Background())
+
+	assert.True(t, report.Up())
+	assert.Len(t, report.Checks, 2)
+	assert.Equal(t, StatusUp, report.Checks["storage"].Status)
+	assert.Empty(t, report.Checks["storage"].Error)
+}
+
+func TestChecker_ReadyFailingDependency(t *testing.T) {
+	checker := NewChecker(
+		Check{Name: "storage", Func: func(context.Context) error { return errors.New("connection refused") }},
+		Check{Name: "cache", Func: func(context.Context) error { return nil }},
+	)
+
+	report := checker.Ready(context.Background())
+
+	assert.False(t, report.Up())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusDown, report.Checks["storage"].Status)
+	assert.Equal(t, "connection refused", report.Checks["storage"].Error)
+	assert.Equal(t, StatusUp, report.Checks["cache"].Status)
+}
+
+func TestChecker_ReadyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	// The check ignores its context, the checker must still give up on it
+	checker := NewChecker(Check{
+		Name:    "storage",
+		Timeout: 20 * time.Millisecond,
+		Func: func(context.Context) error {
+			<-release
+			return nil
+		},
+	})
+
+	start := time.Now()
+	report := checker.Ready(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, report.Up())
+	assert.Equal(t, "timed out after 20ms", report.Checks["storage"].Error)
+}
+
+func TestChecker_ShuttingDown(t *testing.T) {
+	called := false
+	checker := NewChecker(Check{Name: "storage", Func: func(context.Context) error {
+		called = true
+		return nil
+	}})
+
+	assert.True(t, checker.Ready(context.Background()).Up())
+	called = false
+
+	checker.SetShuttingDown()
+
+	report := checker.Ready(context.Background())
+	assert.Equal(t, StatusShuttingDown, report.Status)
+	assert.False(t, called)
+	assert.True(t, checker.ShuttingDown())
+
+	// Liveness is unaffected, the process is still healthy while draining
+	assert.True(t, checker.Live().Up())
+}
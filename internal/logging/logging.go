@@ -0,0 +1,137 @@
+// Package logging builds the structured loggers shared by the HTTP, service
+// and storage layers, correlating every line with the request that caused it.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/wiredmatt/go_short/internal/config"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+type ctxKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// ContextHandler adds the request ID and trace IDs found in the record's
+// context to every record before passing it to the wrapped handler
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: next}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// New returns a JSON logger writing to w. Only the *Context logging methods
+// pick up the request ID, so callers with a context should use them.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(NewContextHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+	})))
+}
+
+// Logger is a logger built from configuration whose level can be changed
+// while the process runs
+type Logger struct {
+	*slog.Logger
+	Level *slog.LevelVar
+
+	closer io.Closer
+}
+
+// Open builds the process logger from cfg. Logs go to stdout unless a file is
+// configured, in which case the file is rotated by size and age.
+func Open(cfg config.LoggingConfig) (*Logger, error) {
+	level := new(slog.LevelVar)
+	if cfg.Level != "" {
+		parsed, err := ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, err
+		}
+		level.Set(parsed)
+	}
+
+	var w io.Writer = os.Stdout
+	var closer io.Closer
+	if cfg.File != "" {
+		file := &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+		}
+		w, closer = file, file
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch cfg.Format {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+
+	return &Logger{
+		Logger: slog.New(NewContextHandler(handler)),
+		Level:  level,
+		closer: closer,
+	}, nil
+}
+
+// Close closes the log file, if any
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// ParseLevel parses a level name such as "debug" or "WARN"
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return 0, fmt.Errorf("unknown log level: %s", name)
+	}
+	return level, nil
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	return line
+}
+
+func TestRequestID_RoundTrip(t *testing.T) {
+	assert.Equal(t, "", RequestID(context.Background()))
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	assert.Equal(t, "req-1", RequestID(ctx))
+}
+
+func TestContextHandler_AddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.With(slog.String("component", "test")).InfoContext(ctx, "hello")
+
+	line := decodeLine(t, &buf)
+	assert.Equal(t, "hello", line["msg"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "test", line["component"])
+}
+
+func TestContextHandler_AddsTraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	logger.InfoContext(ctx, "hello")
+
+	line := decodeLine(t, &buf)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", line["span_id"])
+	assert.NotContains(t, line, "request_id")
+}
+
+func TestContextHandler_RespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelWarn)
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "hidden")
+	assert.Empty(t, buf.String())
+}
+
+func TestOpen_FileSinkAndRuntimeLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go_short.log")
+
+	logger, err := Open(config.LoggingConfig{
+		Level:     "info",
+		Format:    "text",
+		File:      path,
+		MaxSizeMB: 1,
+	})
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.DebugContext(ctx, "hidden")
+	logger.Level.Set(slog.LevelDebug)
+	logger.DebugContext(ctx, "shown")
+	require.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "level=DEBUG")
+	assert.Contains(t, lines[0], "msg=shown")
+	assert.Contains(t, lines[0], "request_id=req-1")
+}
+
+func TestOpen_InvalidConfig(t *testing.T) {
+	_, err := Open(config.LoggingConfig{Level: "verbose"})
+	assert.Error(t, err)
+
+	_, err = Open(config.LoggingConfig{Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		got, err := ParseLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseLevel("loud")
+	assert.Error(t, err)
+}
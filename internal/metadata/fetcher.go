@@ -0,0 +1,270 @@
+// Package metadata reads the title, description and preview image of web
+// pages, for links to show what they point to.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxRedirects is how many redirects a fetch follows before giving up
+	maxRedirects = 5
+	// maxConcurrent bounds how many pages are fetched at once, so a burst of
+	// new links cannot open a connection each
+	maxConcurrent = 4
+
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+	maxImageLength       = 2048
+
+	userAgent = "go_short-metadata/1.0 (+link preview)"
+)
+
+var (
+	// ErrBlockedAddress is returned for pages on loopback, private, link-local
+	// and other addresses that are not on the public internet
+	ErrBlockedAddress = errors.New("address is not public")
+	// ErrNotHTML is returned for responses that are not HTML pages
+	ErrNotHTML = errors.New("response is not an HTML page")
+)
+
+// cgnat is the shared address space carriers use behind NAT, which
+// netip does not count as private
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// Fetcher reads metadata from web pages. It only connects to public
+// addresses, checked after DNS resolution and on every redirect, so links
+// cannot be used to probe the network the server runs in.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+	slots    chan struct{}
+	// blocked reports whether an address may not be connected to
+	blocked func(netip.Addr) bool
+}
+
+// NewFetcher returns a Fetcher that gives up on a page after timeout and
+// reads at most maxBytes of it. Metadata lives in the head of pages, so
+// larger pages are cut off rather than refused.
+func NewFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	f := &Fetcher{
+		maxBytes: maxBytes,
+		slots:    make(chan struct{}, maxConcurrent),
+		blocked:  nonPublic,
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Control runs with the resolved address, so names that resolve to
+		// private addresses are caught too
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if f.blocked(addrPort.Addr().Unmap()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// A proxy would make the connection on our behalf, bypassing the
+			// address check
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          maxConcurrent,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// nonPublic reports whether addr is outside the public internet
+func nonPublic(addr netip.Addr) bool {
+	return !addr.IsGlobalUnicast() || addr.IsPrivate() || cgnat.Contains(addr)
+}
+
+// Fetch reads the metadata of the page at rawURL. OpenGraph tags are
+// preferred over the page title and description meta tag.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*model.Metadata, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+
+	select {
+	case f.slots <- struct{}{}:
+		defer func() { <-f.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+
+	metadata := parse(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	metadata.FetchedAt = time.Now()
+	return metadata, nil
+}
+
+// parse reads metadata from the head of an HTML document. Relative image
+// URLs are resolved against base, the URL the page was served from.
+func parse(r io.Reader, base *url.URL) *model.Metadata {
+	var title, description, ogTitle, ogDescription, image, siteName string
+
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			// The end of the document, or of what was read of it
+			return build(title, description, ogTitle, ogDescription, image, siteName, base)
+		case html.TextToken:
+			if inTitle {
+				title += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return build(title, description, ogTitle, ogDescription, image, siteName, base)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = tokenType == html.StartTagToken && title == ""
+			case "body":
+				return build(title, description, ogTitle, ogDescription, image, siteName, base)
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				var key, content string
+				for {
+					attr, value, more := tokenizer.TagAttr()
+					switch string(attr) {
+					case "property", "name":
+						key = strings.ToLower(string(value))
+					case "content":
+						content = string(value)
+					}
+					if !more {
+						break
+					}
+				}
+				switch key {
+				case "og:title":
+					ogTitle = firstOf(ogTitle, content)
+				case "og:description":
+					ogDescription = firstOf(ogDescription, content)
+				case "description":
+					description = firstOf(description, content)
+				case "og:image", "og:image:url", "og:image:secure_url":
+					image = firstOf(image, content)
+				case "og:site_name":
+					siteName = firstOf(siteName, content)
+				}
+			}
+		}
+	}
+}
+
+// build assembles the metadata read from a page, preferring OpenGraph values
+func build(title, description, ogTitle, ogDescription, image, siteName string, base *url.URL) *model.Metadata {
+	return &model.Metadata{
+		Title:       clean(firstOf(ogTitle, title), maxTitleLength),
+		Description: clean(firstOf(ogDescription, description), maxDescriptionLength),
+		Image:       resolveImage(image, base),
+		SiteName:    clean(siteName, maxTitleLength),
+	}
+}
+
+// firstOf returns current unless it is empty
+func firstOf(current, next string) string {
+	if strings.TrimSpace(current) != "" {
+		return current
+	}
+	return next
+}
+
+// clean collapses whitespace in text and cuts it to max characters
+func clean(text string, max int) string {
+	text = strings.Join(strings.Fields(strings.ToValidUTF8(text, "")), " ")
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max-1]) + "…"
+}
+
+// resolveImage returns the absolute URL of an image reference, or an empty
+// string for references that are not http(s) or too long
+func resolveImage(ref string, base *url.URL) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	image, err := base.Parse(ref)
+	if err != nil || (image.Scheme != "http" && image.Scheme != "https") {
+		return ""
+	}
+	resolved := image.String()
+	if len(resolved) > maxImageLength {
+		return ""
+	}
+	return resolved
+}
@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestFetcher returns a Fetcher that may reach httptest servers on loopback
+func newTestFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	f := NewFetcher(timeout, maxBytes)
+	f.blocked = func(addr netip.Addr) bool { return !addr.IsLoopback() }
+	return f
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/article":
+			w.Write([]byte(`<!doctype html><html><head>
+				<title>  Plain
+				title </title>
+				<meta name="description" content="Plain description">
+				<meta property="og:title" content="Launch &amp; beyond">
+				<meta property="og:image" content="/img/cover.png">
+				<meta property="og:site_name" content="Example Blog">
+				</head><body><meta property="og:description" content="Not in the head"></body></html>`))
+		case "/plain":
+			w.Write([]byte(`<html><head><title>Only a title</title><meta name="description" content="About it"></head></html>`))
+		case "/moved":
+			http.Redirect(w, r, "/plain", http.StatusMovedPermanently)
+		case "/data":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		case "/missing":
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(time.Second, 1<<20)
+	ctx := context.Background()
+
+	metadata, err := f.Fetch(ctx, server.URL+"/article")
+	assert.NoError(t, err)
+	assert.Equal(t, "Launch & beyond", metadata.Title)
+	assert.Equal(t, "Plain description", metadata.Description)
+	assert.Equal(t, server.URL+"/img/cover.png", metadata.Image)
+	assert.Equal(t, "Example Blog", metadata.SiteName)
+	assert.False(t, metadata.FetchedAt.IsZero())
+
+	metadata, err = f.Fetch(ctx, server.URL+"/moved")
+	assert.NoError(t, err)
+	assert.Equal(t, "Only a title", metadata.Title)
+	assert.Equal(t, "About it", metadata.Description)
+	assert.Empty(t, metadata.Image)
+
+	_, err = f.Fetch(ctx, server.URL+"/data")
+	assert.ErrorIs(t, err, ErrNotHTML)
+	_, err = f.Fetch(ctx, server.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+	_, err = f.Fetch(ctx, "ftp://example.com/file")
+	assert.Error(t, err)
+}
+
+func TestFetcher_Limits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/large":
+			w.Write([]byte("<html><head><!--" + strings.Repeat("x", 4096) + "--><title>Too far in</title></head></html>"))
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+			w.Write([]byte("<title>Too late</title>"))
+		case "/long":
+			w.Write([]byte("<title>" + strings.Repeat("a", 300) + "</title>"))
+		}
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(200*time.Millisecond, 1024)
+	ctx := context.Background()
+
+	metadata, err := f.Fetch(ctx, server.URL+"/large")
+	assert.NoError(t, err)
+	assert.Empty(t, metadata.Title, "only maxBytes of the page are read")
+
+	_, err = f.Fetch(ctx, server.URL+"/slow")
+	assert.Error(t, err)
+
+	metadata, err = f.Fetch(ctx, server.URL+"/long")
+	assert.NoError(t, err)
+	assert.Equal(t, maxTitleLength, len([]rune(metadata.Title)))
+}
+
+func TestFetcher_BlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>Internal</title>"))
+	}))
+	defer server.Close()
+
+	_, err := NewFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+
+	// A public page redirecting to an internal one is stopped at the
+	// redirect. Only the first connection counts as public here.
+	f := NewFetcher(time.Second, 1<<20)
+	dials := 0
+	f.blocked = func(netip.Addr) bool {
+		dials++
+		return dials > 1
+	}
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL, http.StatusFound)
+	}))
+	defer redirect.Close()
+	_, err = f.Fetch(context.Background(), redirect.URL)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+}
+
+func TestNonPublic(t *testing.T) {
+	for addr, blocked := range map[string]bool{
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.16.0.1":      true,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"224.0.0.1":       true,
+		"::1":             true,
+		"fd00::1":         true,
+		"fe80::1":         true,
+		"93.184.216.34":   false,
+		"2606:4700::1111": false,
+	} {
+		assert.Equal(t, blocked, nonPublic(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,105 @@
+// Package metrics holds the domain level Prometheus collectors shared by the
+// service and storage layers. They are registered by middleware.PrometheusInit.
+package metrics
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	StoreOperationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "go_short_store_operation_duration_seconds",
+			Help:    "Latency of storage operations by backend and method.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"backend", "method"},
+	)
+
+	LinksCreated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "go_short_links_created_total",
+			Help: "Total number of short links created, including imported ones.",
+		},
+	)
+
+	RedirectsServed = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "go_short_redirects_total",
+			Help: "Total number of redirects served.",
+		},
+	)
+
+	RedirectMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "go_short_redirect_misses_total",
+			Help: "Total number of resolve attempts that did not redirect, by reason (not_found, expired, not_active, exhausted).",
+		},
+		[]string{"reason"},
+	)
+
+	ActiveLinks = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "go_short_active_links",
+			Help: "Number of links that are active and have not expired or used up their max clicks.",
+		},
+		countActiveLinks,
+	)
+
+	activeLinksMu     sync.RWMutex
+	activeLinksSource func(ctx context.Context) (int, error)
+)
+
+const (
+	MissNotFound = "not_found"
+	MissExpired  = "expired"
+	// MissNotActive counts requests for links before their activation time
+	MissNotActive = "not_active"
+	// MissExhausted counts requests for links that used up their max clicks
+	MissExhausted = "exhausted"
+	// MissQuota counts requests refused because the link's account served
+	// its monthly redirects
+	MissQuota = "quota"
+)
+
+// Collectors returns every domain collector so they can be registered together
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		StoreOperationDuration,
+		LinksCreated,
+		RedirectsServed,
+		RedirectMisses,
+		ActiveLinks,
+	}
+}
+
+// SetActiveLinksSource sets the function queried whenever ActiveLinks is scraped
+func SetActiveLinksSource(fn func(ctx context.Context) (int, error)) {
+	activeLinksMu.Lock()
+	defer activeLinksMu.Unlock()
+	activeLinksSource = fn
+}
+
+func countActiveLinks() float64 {
+	activeLinksMu.RLock()
+	source := activeLinksSource
+	activeLinksMu.RUnlock()
+
+	if source == nil {
+		return math.NaN()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := source(ctx)
+	if err != nil {
+		return math.NaN()
+	}
+	return float64(count)
+}
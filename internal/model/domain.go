@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Domain is a branded short domain registered by a user. Links created on it
+// are served on its host and have codes of their own.
+type Domain struct {
+	// Name is the lowercase host name, such as go.example.com
+	Name      string
+	UserID    string
+	CreatedAt time.Time
+}
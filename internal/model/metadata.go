@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Metadata describes the page a link points to, as read from the page itself
+type Metadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Image is the absolute URL of the page's preview image
+	Image     string    `json:"image,omitempty"`
+	SiteName  string    `json:"site_name,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
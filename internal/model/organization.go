@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// Role is what a member may do in an organization
+type Role string
+
+const (
+	// RoleOwner can do everything an admin can, and manage other owners
+	RoleOwner Role = "owner"
+	// RoleAdmin can manage members and the organization's links
+	RoleAdmin Role = "admin"
+	// RoleEditor can create, update and delete the organization's links
+	RoleEditor Role = "editor"
+	// RoleViewer can list the organization's links and see their stats
+	RoleViewer Role = "viewer"
+)
+
+// ValidRole reports whether role is one of the Role constants
+func ValidRole(role Role) bool {
+	switch role {
+	case RoleOwner, RoleAdmin, RoleEditor, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// CanEditLinks reports whether members with the role may create, update
+// and delete links
+func (r Role) CanEditLinks() bool {
+	return r == RoleOwner || r == RoleAdmin || r == RoleEditor
+}
+
+// CanManageMembers reports whether members with the role may add, change
+// and remove members other than owners
+func (r Role) CanManageMembers() bool {
+	return r == RoleOwner || r == RoleAdmin
+}
+
+// Organization is a workspace whose links are shared by its members
+type Organization struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+}
+
+// Membership is the role of a user in an organization
+type Membership struct {
+	OrgID     string
+	UserID    string
+	Role      Role
+	CreatedAt time.Time
+}
@@ -0,0 +1,24 @@
+package model
+
+// Query passthrough policies decide what happens to the query string of a
+// request for a short link. The zero value drops it.
+const (
+	QueryPassthroughNone = ""
+	// QueryPassthroughMerge adds incoming parameters the destination does not set
+	QueryPassthroughMerge = "merge"
+	// QueryPassthroughOverride replaces destination parameters with incoming ones
+	QueryPassthroughOverride = "override"
+	// QueryPassthroughAppend keeps the values of both
+	QueryPassthroughAppend = "append"
+)
+
+// ValidQueryPassthrough reports whether policy is a query passthrough policy a
+// mapping may use
+func ValidQueryPassthrough(policy string) bool {
+	switch policy {
+	case QueryPassthroughNone, QueryPassthroughMerge, QueryPassthroughOverride, QueryPassthroughAppend:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,48 @@
+package model
+
+// Account is what links and redirects are counted against: an organization
+// for its links, or a user for their personal ones
+type Account struct {
+	UserID string
+	OrgID  string
+}
+
+// AccountOf returns the account mapping counts against
+func AccountOf(mapping URLMapping) Account {
+	if mapping.OrgID != "" {
+		return Account{OrgID: mapping.OrgID}
+	}
+	return Account{UserID: mapping.UserID}
+}
+
+// Limits caps what an account may use. Zero means no limit.
+type Limits struct {
+	// ActiveLinks is how many links that have not expired or used up their
+	// clicks the account may have, scheduled ones included
+	ActiveLinks int
+	// MonthlyRedirects is how many redirects the account's links may serve
+	// in a calendar month, in UTC
+	MonthlyRedirects int
+}
+
+// Quota overrides the default limits of an account. Nil limits fall back to
+// the defaults.
+type Quota struct {
+	Account          Account
+	ActiveLinks      *int
+	MonthlyRedirects *int
+}
+
+// Apply returns defaults with the limits q overrides replaced
+func (q *Quota) Apply(defaults Limits) Limits {
+	if q == nil {
+		return defaults
+	}
+	if q.ActiveLinks != nil {
+		defaults.ActiveLinks = *q.ActiveLinks
+	}
+	if q.MonthlyRedirects != nil {
+		defaults.MonthlyRedirects = *q.MonthlyRedirects
+	}
+	return defaults
+}
@@ -0,0 +1,27 @@
+package model
+
+import "net/http"
+
+// Redirect types a mapping may use. The zero value means the mapping follows
+// the server-wide default.
+const (
+	RedirectMovedPermanently  = http.StatusMovedPermanently
+	RedirectFound             = http.StatusFound
+	RedirectTemporary         = http.StatusTemporaryRedirect
+	RedirectPermanentRedirect = http.StatusPermanentRedirect
+)
+
+// ValidRedirectType reports whether status is a redirect type a mapping may use
+func ValidRedirectType(status int) bool {
+	switch status {
+	case RedirectMovedPermanently, RedirectFound, RedirectTemporary, RedirectPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// PermanentRedirect reports whether clients may treat status as permanent
+func PermanentRedirect(status int) bool {
+	return status == RedirectMovedPermanently || status == RedirectPermanentRedirect
+}
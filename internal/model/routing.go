@@ -0,0 +1,58 @@
+package model
+
+// Platforms a routing rule can match, detected from the User-Agent
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+)
+
+// Device classes a routing rule can match, detected from the User-Agent
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// RoutingRule sends requests that match all of its conditions to URL instead
+// of the mapping's Original. Rules are stored as JSON, so the field names
+// are part of the storage format.
+type RoutingRule struct {
+	Platform string `json:"platform,omitempty"`
+	Device   string `json:"device,omitempty"`
+	// Language matches the client's most preferred language, by primary
+	// subtag ("pt") or by full tag ("pt-BR")
+	Language string `json:"language,omitempty"`
+	// Headers match request headers by name. Values are compared ignoring
+	// case, and an empty value only requires the header to be present.
+	Headers map[string]string `json:"headers,omitempty"`
+	URL     string            `json:"url"`
+}
+
+// Unconditional reports whether the rule has no conditions, so it would
+// match every request
+func (r RoutingRule) Unconditional() bool {
+	return r.Platform == "" && r.Device == "" && r.Language == "" && len(r.Headers) == 0
+}
+
+// ValidPlatform reports whether platform is one a rule may match
+func ValidPlatform(platform string) bool {
+	switch platform {
+	case PlatformIOS, PlatformAndroid, PlatformWindows, PlatformMacOS, PlatformLinux:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidDevice reports whether device is a device class a rule may match
+func ValidDevice(device string) bool {
+	switch device {
+	case DeviceMobile, DeviceTablet, DeviceDesktop:
+		return true
+	default:
+		return false
+	}
+}
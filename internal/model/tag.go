@@ -0,0 +1,24 @@
+package model
+
+import "slices"
+
+// TagCount is a tag and how many links of an account carry it
+type TagCount struct {
+	Tag   string
+	Links int
+}
+
+// MappingFilter narrows down listed mappings. Empty fields match every
+// mapping.
+type MappingFilter struct {
+	Tag    string
+	Folder string
+}
+
+// Match reports whether mapping passes the filter
+func (f MappingFilter) Match(mapping URLMapping) bool {
+	if f.Folder != "" && mapping.Folder != f.Folder {
+		return false
+	}
+	return f.Tag == "" || slices.Contains(mapping.Tags, f.Tag)
+}
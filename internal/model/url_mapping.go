@@ -3,10 +3,87 @@ package model
 import "time"
 
 type URLMapping struct {
-	Code      string
-	Original  string
-	UserID    string
+	// Domain is the registered domain the link is served on, empty for the
+	// default one. Codes are unique per domain.
+	Domain   string
+	Code     string
+	Original string
+	// UserID is the user who created the link, and its owner unless it
+	// belongs to an organization
+	UserID string
+	// OrgID is the organization that owns the link, empty for personal links
+	OrgID     string
 	CreatedAt time.Time
 	ExpiresAt *time.Time
-	Clicks    int
+	// ActivatesAt is when the link starts redirecting, nil for links that
+	// are active as soon as they are created
+	ActivatesAt *time.Time
+	Clicks      int
+	// MaxClicks is how many times the link may be followed, 0 for no limit
+	MaxClicks int
+	// RedirectType is the HTTP status used to redirect, 0 uses the server default
+	RedirectType int
+	// QueryPassthrough is how the request's query string is merged into
+	// Original, see the QueryPassthrough constants
+	QueryPassthrough string
+	// PathPassthrough makes this a prefix link: path segments after the code
+	// are appended to Original
+	PathPassthrough bool
+	// UTM holds the campaign parameters composed into Original
+	UTM UTM
+	// Rules route matching requests to other destinations, checked in
+	// order before falling back to Original
+	Rules []RoutingRule
+	// Variants split traffic between destinations by weight, in place of
+	// Original. StickyVariants keeps each visitor on the variant they got
+	// first.
+	Variants       []Variant
+	StickyVariants bool
+	// VariantClicks counts the clicks served by each variant, by name
+	VariantClicks map[string]int
+	// Title is the owner's name for the link, shown on its preview page
+	Title string
+	// Interstitial makes the link show a page with its destination instead
+	// of redirecting right away
+	Interstitial bool
+	// Tags label the link, sorted and without duplicates
+	Tags []string
+	// Folder groups the link with others, empty for links in no folder
+	Folder string
+	// Metadata is what was fetched from Original after the link was
+	// created, nil until then or if the page could not be read
+	Metadata *Metadata
+	// PasswordHash is the bcrypt hash of the password required to follow the
+	// link, empty for public links. It is never serialized.
+	PasswordHash string `json:"-"`
+}
+
+// Protected reports whether following the mapping requires a password
+func (m URLMapping) Protected() bool {
+	return m.PasswordHash != ""
+}
+
+// Exhausted reports whether the mapping has used up its max clicks
+func (m URLMapping) Exhausted() bool {
+	return m.MaxClicks > 0 && m.Clicks >= m.MaxClicks
+}
+
+// RemainingClicks returns how many more times the mapping may be followed,
+// or nil if it has no limit
+func (m URLMapping) RemainingClicks() *int {
+	if m.MaxClicks == 0 {
+		return nil
+	}
+	remaining := max(m.MaxClicks-m.Clicks, 0)
+	return &remaining
+}
+
+// Pending reports whether the mapping has not been activated yet at now
+func (m URLMapping) Pending(now time.Time) bool {
+	return m.ActivatesAt != nil && now.Before(*m.ActivatesAt)
+}
+
+// Expired reports whether the mapping has expired at now
+func (m URLMapping) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && now.After(*m.ExpiresAt)
 }
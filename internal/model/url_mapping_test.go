@@ -114,3 +114,109 @@ func TestURLMapping_IsExpired(t *testing.T) {
 		})
 	}
 }
+
+func TestURLMapping_Expired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	assert.True(t, URLMapping{ExpiresAt: &past}.Expired(now))
+	assert.False(t, URLMapping{ExpiresAt: &future}.Expired(now))
+	assert.False(t, URLMapping{}.Expired(now))
+}
+
+func TestURLMapping_Pending(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	assert.True(t, URLMapping{ActivatesAt: &future}.Pending(now))
+	assert.False(t, URLMapping{ActivatesAt: &past}.Pending(now))
+	assert.False(t, URLMapping{ActivatesAt: &now}.Pending(now))
+	assert.False(t, URLMapping{}.Pending(now))
+}
+
+func TestValidRedirectType(t *testing.T) {
+	for _, status := range []int{301, 302, 307, 308} {
+		assert.True(t, ValidRedirectType(status), status)
+	}
+	for _, status := range []int{0, 200, 303, 404} {
+		assert.False(t, ValidRedirectType(status), status)
+	}
+
+	assert.True(t, PermanentRedirect(301))
+	assert.True(t, PermanentRedirect(308))
+	assert.False(t, PermanentRedirect(302))
+	assert.False(t, PermanentRedirect(307))
+}
+
+func TestURLMapping_MaxClicks(t *testing.T) {
+	unlimited := URLMapping{Clicks: 10}
+	assert.False(t, unlimited.Exhausted())
+	assert.Nil(t, unlimited.RemainingClicks())
+
+	limited := URLMapping{Clicks: 2, MaxClicks: 3}
+	assert.False(t, limited.Exhausted())
+	assert.Equal(t, 1, *limited.RemainingClicks())
+
+	// Clicks imported past the limit never report negative remaining uses
+	exhausted := URLMapping{Clicks: 5, MaxClicks: 3}
+	assert.True(t, exhausted.Exhausted())
+	assert.Equal(t, 0, *exhausted.RemainingClicks())
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []Variant{{Name: "a", Weight: 3}, {Name: "paused", Weight: 0}, {Name: "b", Weight: 1}}
+
+	assert.Equal(t, 4, TotalWeight(variants))
+	assert.Equal(t, "a", PickVariant(variants, 0).Name)
+	assert.Equal(t, "a", PickVariant(variants, 2).Name)
+	assert.Equal(t, "b", PickVariant(variants, 3).Name)
+	assert.Nil(t, PickVariant(variants, 4))
+
+	assert.Equal(t, "paused", FindVariant(variants, "paused").Name)
+	assert.Nil(t, FindVariant(variants, "c"))
+}
+
+func TestRole_Permissions(t *testing.T) {
+	assert.True(t, ValidRole(RoleViewer))
+	assert.False(t, ValidRole("superuser"))
+	assert.False(t, ValidRole(""))
+
+	for _, tt := range []struct {
+		role          Role
+		editLinks     bool
+		manageMembers bool
+	}{
+		{RoleOwner, true, true},
+		{RoleAdmin, true, true},
+		{RoleEditor, true, false},
+		{RoleViewer, false, false},
+	} {
+		assert.Equal(t, tt.editLinks, tt.role.CanEditLinks(), tt.role)
+		assert.Equal(t, tt.manageMembers, tt.role.CanManageMembers(), tt.role)
+	}
+}
+
+func TestQuota_Apply(t *testing.T) {
+	defaults := Limits{ActiveLinks: 100, MonthlyRedirects: 10000}
+	unlimited := 0
+
+	var missing *Quota
+	assert.Equal(t, defaults, missing.Apply(defaults))
+	assert.Equal(t, Limits{ActiveLinks: 0, MonthlyRedirects: 10000}, (&Quota{ActiveLinks: &unlimited}).Apply(defaults))
+
+	assert.Equal(t, Account{OrgID: "org_1"}, AccountOf(URLMapping{UserID: "alice", OrgID: "org_1"}))
+	assert.Equal(t, Account{UserID: "alice"}, AccountOf(URLMapping{UserID: "alice"}))
+}
+
+func TestMappingFilter_Match(t *testing.T) {
+	mapping := URLMapping{Tags: []string{"launch", "q3"}, Folder: "Marketing"}
+
+	assert.True(t, MappingFilter{}.Match(mapping))
+	assert.True(t, MappingFilter{Tag: "q3"}.Match(mapping))
+	assert.True(t, MappingFilter{Tag: "launch", Folder: "Marketing"}.Match(mapping))
+	assert.False(t, MappingFilter{Tag: "q4"}.Match(mapping))
+	assert.False(t, MappingFilter{Tag: "q3", Folder: "Sales"}.Match(mapping))
+	assert.False(t, MappingFilter{Folder: "Marketing"}.Match(URLMapping{}))
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// UTM holds the campaign parameters appended to a link's destination as
+// utm_* query parameters
+type UTM struct {
+	Source   string
+	Medium   string
+	Campaign string
+	Term     string
+	Content  string
+}
+
+// UTMTemplate is a named set of UTM parameters saved by a user
+type UTMTemplate struct {
+	UserID    string
+	Name      string
+	UTM       UTM
+	CreatedAt time.Time
+}
+
+// Empty reports whether no parameter is set
+func (u UTM) Empty() bool {
+	return u == UTM{}
+}
+
+// Merge returns u with every parameter set in override replacing its own
+func (u UTM) Merge(override UTM) UTM {
+	if override.Source != "" {
+		u.Source = override.Source
+	}
+	if override.Medium != "" {
+		u.Medium = override.Medium
+	}
+	if override.Campaign != "" {
+		u.Campaign = override.Campaign
+	}
+	if override.Term != "" {
+		u.Term = override.Term
+	}
+	if override.Content != "" {
+		u.Content = override.Content
+	}
+	return u
+}
+
+// Normalize trims and lowercases every parameter and replaces inner
+// whitespace with '_', so "Spring Sale" and "spring_sale " are reported as the
+// same campaign
+func (u UTM) Normalize() UTM {
+	return UTM{
+		Source:   normalizeUTMValue(u.Source),
+		Medium:   normalizeUTMValue(u.Medium),
+		Campaign: normalizeUTMValue(u.Campaign),
+		Term:     normalizeUTMValue(u.Term),
+		Content:  normalizeUTMValue(u.Content),
+	}
+}
+
+// Params returns the set parameters keyed by their query parameter name
+func (u UTM) Params() map[string]string {
+	params := make(map[string]string, 5)
+	for key, value := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if value != "" {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+func normalizeUTMValue(v string) string {
+	return strings.Join(strings.Fields(strings.ToLower(v)), "_")
+}
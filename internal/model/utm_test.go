@@ -0,0 +1,31 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTM_Merge(t *testing.T) {
+	template := UTM{Source: "newsletter", Medium: "email", Campaign: "spring"}
+
+	merged := template.Merge(UTM{Campaign: "summer", Content: "header"})
+
+	assert.Equal(t, UTM{Source: "newsletter", Medium: "email", Campaign: "summer", Content: "header"}, merged)
+	assert.Equal(t, "spring", template.Campaign)
+}
+
+func TestUTM_Normalize(t *testing.T) {
+	utm := UTM{Source: " Newsletter ", Medium: "EMAIL", Campaign: "Spring  Sale 2026"}
+
+	assert.Equal(t, UTM{Source: "newsletter", Medium: "email", Campaign: "spring_sale_2026"}, utm.Normalize())
+}
+
+func TestUTM_Params(t *testing.T) {
+	assert.True(t, UTM{}.Empty())
+	assert.Empty(t, UTM{}.Params())
+
+	utm := UTM{Source: "newsletter", Term: "shoes"}
+	assert.False(t, utm.Empty())
+	assert.Equal(t, map[string]string{"utm_source": "newsletter", "utm_term": "shoes"}, utm.Params())
+}
@@ -0,0 +1,42 @@
+package model
+
+// Variant is one of the destinations of an A/B split link. Variants are
+// stored as JSON, so the field names are part of the storage format.
+type Variant struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Weight is the variant's share of traffic relative to the others, 0
+	// pauses it
+	Weight int `json:"weight"`
+}
+
+// PickVariant returns the variant a roll in [0, total weight) falls on, or
+// nil if no variant has any weight
+func PickVariant(variants []Variant, roll int) *Variant {
+	for i := range variants {
+		if roll < variants[i].Weight {
+			return &variants[i]
+		}
+		roll -= variants[i].Weight
+	}
+	return nil
+}
+
+// TotalWeight returns the sum of the weights of variants
+func TotalWeight(variants []Variant) int {
+	total := 0
+	for _, v := range variants {
+		total += v.Weight
+	}
+	return total
+}
+
+// FindVariant returns the variant called name, or nil
+func FindVariant(variants []Variant, name string) *Variant {
+	for i := range variants {
+		if variants[i].Name == name {
+			return &variants[i]
+		}
+	}
+	return nil
+}
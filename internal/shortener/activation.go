@@ -0,0 +1,38 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+var (
+	// ErrNotActive is wrapped by NotActiveError
+	ErrNotActive = errors.New("link is not active yet")
+	// ErrInvalidActivation is returned for links that would activate after they expire
+	ErrInvalidActivation = errors.New("activation time must be before the expiry time")
+)
+
+// NotActiveError is returned when resolving a link before its activation
+// time. It matches ErrNotActive.
+type NotActiveError struct {
+	ActivatesAt time.Time
+}
+
+func (e *NotActiveError) Error() string {
+	return fmt.Sprintf("%s, activates at %s", ErrNotActive, e.ActivatesAt.Format(time.RFC3339))
+}
+
+func (e *NotActiveError) Unwrap() error {
+	return ErrNotActive
+}
+
+// validateActivation checks that mapping activates before it expires
+func validateActivation(mapping model.URLMapping) error {
+	if mapping.ActivatesAt != nil && mapping.ExpiresAt != nil && !mapping.ActivatesAt.Before(*mapping.ExpiresAt) {
+		return ErrInvalidActivation
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package shortener
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -14,31 +15,204 @@ type BenchmarkStore struct {
 	mock.Mock
 }
 
-func (m *BenchmarkStore) Save(mapping model.URLMapping) error {
+func (m *BenchmarkStore) Save(_ context.Context, mapping model.URLMapping) error {
 	args := m.Called(mapping)
 	return args.Error(0)
 }
 
-func (m *BenchmarkStore) Get(code string) (*string, error) {
-	args := m.Called(code)
+func (m *BenchmarkStore) SaveWithinLimit(_ context.Context, mapping model.URLMapping, maxActiveLinks int) error {
+	args := m.Called(mapping, maxActiveLinks)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) Get(_ context.Context, domain, code string) (*model.URLMapping, error) {
+	args := m.Called(domain, code)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*string), args.Error(1)
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *BenchmarkStore) GetMapping(_ context.Context, domain, code string) (*model.URLMapping, error) {
+	args := m.Called(domain, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *BenchmarkStore) Update(_ context.Context, mapping model.URLMapping) error {
+	args := m.Called(mapping)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) SaveMetadata(_ context.Context, domain, code, original string, metadata model.Metadata) error {
+	args := m.Called(domain, code, original, metadata)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) IncrementClickCount(_ context.Context, domain, code string) error {
+	args := m.Called(domain, code)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) ConsumeClick(_ context.Context, domain, code string, maxRedirects int) error {
+	args := m.Called(domain, code, maxRedirects)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) IncrementVariantClickCount(_ context.Context, domain, code, variant string) error {
+	args := m.Called(domain, code, variant)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) ListByUser(_ context.Context, userID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	args := m.Called(userID, filter)
+	return args.Get(0).([]model.URLMapping), args.Error(1)
+}
+
+func (m *BenchmarkStore) Delete(_ context.Context, domain, code string) error {
+	args := m.Called(domain, code)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) CountActive(_ context.Context) (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
 }
 
-func (m *BenchmarkStore) IncrementClickCount(code string) error {
-	args := m.Called(code)
+func (m *BenchmarkStore) SaveUTMTemplate(_ context.Context, template model.UTMTemplate) error {
+	args := m.Called(template)
 	return args.Error(0)
 }
 
-func (m *BenchmarkStore) ListByUser(userID string) ([]model.URLMapping, error) {
+func (m *BenchmarkStore) GetUTMTemplate(_ context.Context, userID, name string) (*model.UTMTemplate, error) {
+	args := m.Called(userID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UTMTemplate), args.Error(1)
+}
+
+func (m *BenchmarkStore) ListUTMTemplates(_ context.Context, userID string) ([]model.UTMTemplate, error) {
 	args := m.Called(userID)
+	return args.Get(0).([]model.UTMTemplate), args.Error(1)
+}
+
+func (m *BenchmarkStore) DeleteUTMTemplate(_ context.Context, userID, name string) error {
+	args := m.Called(userID, name)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) SaveDomain(_ context.Context, domain model.Domain) error {
+	args := m.Called(domain)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) GetDomain(_ context.Context, name string) (*model.Domain, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Domain), args.Error(1)
+}
+
+func (m *BenchmarkStore) ListDomains(_ context.Context, userID string) ([]model.Domain, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.Domain), args.Error(1)
+}
+
+func (m *BenchmarkStore) DeleteDomain(_ context.Context, name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) ListByOrg(_ context.Context, orgID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	args := m.Called(orgID, filter)
+	return args.Get(0).([]model.URLMapping), args.Error(1)
+}
+
+func (m *BenchmarkStore) SaveOrganization(_ context.Context, org model.Organization, owner model.Membership) error {
+	args := m.Called(org, owner)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) GetOrganization(_ context.Context, id string) (*model.Organization, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Organization), args.Error(1)
+}
+
+func (m *BenchmarkStore) ListMemberships(_ context.Context, userID string) ([]model.Membership, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *BenchmarkStore) SaveMember(_ context.Context, member model.Membership) error {
+	args := m.Called(member)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) GetMember(_ context.Context, orgID, userID string) (*model.Membership, error) {
+	args := m.Called(orgID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Membership), args.Error(1)
+}
+
+func (m *BenchmarkStore) ListMembers(_ context.Context, orgID string) ([]model.Membership, error) {
+	args := m.Called(orgID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *BenchmarkStore) DeleteMember(_ context.Context, orgID, userID string) error {
+	args := m.Called(orgID, userID)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) CountActiveLinks(_ context.Context, account model.Account) (int, error) {
+	args := m.Called(account)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *BenchmarkStore) MonthlyRedirects(_ context.Context, account model.Account, month time.Time) (int, error) {
+	args := m.Called(account, month)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *BenchmarkStore) SaveQuota(_ context.Context, quota model.Quota) error {
+	args := m.Called(quota)
+	return args.Error(0)
+}
+
+func (m *BenchmarkStore) GetQuota(_ context.Context, account model.Account) (*model.Quota, error) {
+	args := m.Called(account)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Quota), args.Error(1)
+}
+
+func (m *BenchmarkStore) ListTags(_ context.Context, account model.Account) ([]model.TagCount, error) {
+	args := m.Called(account)
+	return args.Get(0).([]model.TagCount), args.Error(1)
+}
+
+func (m *BenchmarkStore) RenameTag(_ context.Context, account model.Account, from, to string) (int, error) {
+	args := m.Called(account, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *BenchmarkStore) SearchMappings(_ context.Context, account model.Account, query string, limit int) ([]model.URLMapping, error) {
+	args := m.Called(account, query, limit)
 	return args.Get(0).([]model.URLMapping), args.Error(1)
 }
 
-func (m *BenchmarkStore) Delete(code string) error {
-	args := m.Called(code)
+func (m *BenchmarkStore) Ping(_ context.Context) error {
+	args := m.Called()
 	return args.Error(0)
 }
 
@@ -58,7 +232,7 @@ func BenchmarkShorten(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		_, err := service.Shorten(userID, originalURL)
+		_, err := service.Shorten(context.Background(), ShortenRequest{UserID: userID, URL: originalURL})
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -74,12 +248,12 @@ func BenchmarkResolve(b *testing.B) {
 	code := "abc123"
 	expectedURL := "https://example.com/very/long/url"
 
-	mockStore.On("Get", code).Return(&expectedURL, nil)
+	mockStore.On("Get", "", code).Return(&model.URLMapping{Code: code, Original: expectedURL}, nil)
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		_, err := service.Resolve(code)
+		_, err := service.Resolve(context.Background(), ResolveRequest{Code: code})
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -121,7 +295,7 @@ func BenchmarkMemoryStore_Save(b *testing.B) {
 			CreatedAt: time.Now(),
 		}
 
-		err := store.Save(mapping)
+		err := store.Save(context.Background(), mapping)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -139,14 +313,14 @@ func BenchmarkMemoryStore_Get(b *testing.B) {
 			UserID:    fmt.Sprintf("user%d", i),
 			CreatedAt: time.Now(),
 		}
-		store.Save(mapping)
+		store.Save(context.Background(), mapping)
 	}
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
 		code := fmt.Sprintf("code%d", i%1000)
-		_, err := store.Get(code)
+		_, err := store.Get(context.Background(), "", code)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -168,12 +342,12 @@ func BenchmarkMemoryStore_ConcurrentAccess(b *testing.B) {
 				CreatedAt: time.Now(),
 			}
 
-			err := store.Save(mapping)
+			err := store.Save(context.Background(), mapping)
 			if err != nil {
 				b.Fatal(err)
 			}
 
-			_, err = store.Get(mapping.Code)
+			_, err = store.Get(context.Background(), "", mapping.Code)
 			if err != nil {
 				b.Fatal(err)
 			}
@@ -207,12 +381,12 @@ func BenchmarkListMappings(b *testing.B) {
 		},
 	}
 
-	mockStore.On("ListByUser", userID).Return(expectedMappings, nil)
+	mockStore.On("ListByUser", userID, model.MappingFilter{}).Return(expectedMappings, nil)
 
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		_, err := service.ListMappings(userID)
+		_, err := service.ListMappings(context.Background(), userID, "", model.MappingFilter{})
 		if err != nil {
 			b.Fatal(err)
 		}
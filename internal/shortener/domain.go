@@ -0,0 +1,187 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrInvalidDomain is returned for domain names that are not host names
+	ErrInvalidDomain = errors.New("domain must be a host name such as go.example.com, other than the default domain")
+	// ErrDomainNotFound is returned when a domain is not registered
+	ErrDomainNotFound = errors.New("domain not found")
+	// ErrDomainExists is returned when registering a domain that is already registered
+	ErrDomainExists = errors.New("domain already registered")
+	// ErrDomainInUse is returned when deleting a domain that still has mappings
+	ErrDomainInUse = errors.New("domain still has mappings")
+)
+
+// domainPattern matches lowercase host names of at least two labels
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// LinkURL returns the short URL of code. Codes on the default domain are
+// under baseURL; those of a registered domain are at its root, with the
+// scheme of baseURL.
+func LinkURL(baseURL, domain, code string) string {
+	domain = normalizeHost(domain)
+	if domain == "" {
+		return baseURL + "/" + code
+	}
+	scheme := "https"
+	if u, err := url.Parse(baseURL); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	return scheme + "://" + domain + "/" + code
+}
+
+// baseHost returns the host of baseURL, in the form of normalizeHost
+func baseHost(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	return normalizeHost(u.Host)
+}
+
+// normalizeHost returns host in the form domains are registered in:
+// lowercase, without port or trailing dot
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// domainOf returns the domain whose links are served on host. Hosts other
+// than registered domains, such as the default domain's or internal ones
+// used by probes, serve the default domain's links.
+func (s *ShortenerService) domainOf(ctx context.Context, host string) (string, error) {
+	host = normalizeHost(host)
+	if host == "" || host == s.baseHost {
+		return "", nil
+	}
+
+	domain, err := s.store.GetDomain(ctx, host)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	if domain == nil {
+		return "", nil
+	}
+	return domain.Name, nil
+}
+
+// ownDomain checks that userID may create links on domain, the empty
+// default domain included
+func (s *ShortenerService) ownDomain(ctx context.Context, userID, domain string) error {
+	if domain == "" {
+		return nil
+	}
+
+	registered, err := s.store.GetDomain(ctx, domain)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	if registered == nil {
+		return ErrDomainNotFound
+	}
+	if registered.UserID != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// RegisterDomain registers name as a domain of userID. Its DNS must point to
+// this server for its links to resolve.
+func (s *ShortenerService) RegisterDomain(ctx context.Context, userID, name string) (*model.Domain, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.RegisterDomain", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("domain", name),
+	))
+	defer span.End()
+
+	name = normalizeHost(name)
+	if len(name) > 253 || !domainPattern.MatchString(name) || name == s.baseHost {
+		return nil, ErrInvalidDomain
+	}
+
+	domain := model.Domain{Name: name, UserID: userID, CreatedAt: time.Now()}
+
+	err := s.store.SaveDomain(ctx, domain)
+	if errors.Is(err, storage.ErrDomainExists) {
+		return nil, ErrDomainExists
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "RegisterDomain failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("domain", name)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+// ListDomains returns the user's domains ordered by name
+func (s *ShortenerService) ListDomains(ctx context.Context, userID string) ([]model.Domain, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ListDomains", trace.WithAttributes(
+		attribute.String("user.id", userID),
+	))
+	defer span.End()
+
+	domains, err := s.store.ListDomains(ctx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "ListDomains failed",
+			slog.String("userID", userID),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return domains, nil
+}
+
+// DeleteDomain removes the domain called name if it is owned by userID and
+// none of its mappings are left
+func (s *ShortenerService) DeleteDomain(ctx context.Context, userID, name string) error {
+	ctx, span := tracer.Start(ctx, "ShortenerService.DeleteDomain", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("domain", name),
+	))
+	defer span.End()
+
+	name = normalizeHost(name)
+	if err := s.ownDomain(ctx, userID, name); err != nil {
+		return err
+	}
+
+	err := s.store.DeleteDomain(ctx, name)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return ErrDomainNotFound
+	case errors.Is(err, storage.ErrDomainInUse):
+		return ErrDomainInUse
+	case err != nil:
+		s.logger.ErrorContext(ctx, "DeleteDomain failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("domain", name)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/metrics"
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxCustomCodeLength = 64
+
+var customCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedCodes would be shadowed by the API's own routes
+var reservedCodes = map[string]bool{
+	"health":        true,
+	"livez":         true,
+	"readyz":        true,
+	"shorten":       true,
+	"mappings":      true,
+	"metrics":       true,
+	"docs":          true,
+	"openapi":       true,
+	"utm-templates": true,
+	"domains":       true,
+	"orgs":          true,
+	"usage":         true,
+	"tags":          true,
+}
+
+// ImportRecord is a single mapping read from an import file. Optional fields
+// left at their zero value are filled in the same way Shorten would.
+type ImportRecord struct {
+	Row          int
+	Code         string
+	Original     string
+	CreatedAt    *time.Time
+	ExpiresAt    *time.Time
+	Clicks       int
+	RedirectType int
+}
+
+// ImportRowError describes why a row of an import file was rejected
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarizes an import, listing every rejected row
+type ImportResult struct {
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ImportMappings validates and saves each record for userID. Invalid rows are
+// reported in the result and do not stop the remaining rows from importing.
+func (s *ShortenerService) ImportMappings(ctx context.Context, userID string, records []ImportRecord) ImportResult {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ImportMappings", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.Int("records.count", len(records)),
+	))
+	defer span.End()
+
+	result := ImportResult{Errors: []ImportRowError{}}
+
+	for _, record := range records {
+		code, err := s.importRecord(ctx, userID, record)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, ImportRowError{
+				Row:   record.Row,
+				Code:  code,
+				Error: err.Error(),
+			})
+			continue
+		}
+		result.Imported++
+	}
+
+	metrics.LinksCreated.Add(float64(result.Imported))
+	span.SetAttributes(
+		attribute.Int("records.imported", result.Imported),
+		attribute.Int("records.failed", result.Failed),
+	)
+
+	s.logger.InfoContext(ctx, "Imported mappings",
+		slog.String("userID", userID),
+		slog.Int("imported", result.Imported),
+		slog.Int("failed", result.Failed),
+	)
+
+	return result
+}
+
+func (s *ShortenerService) importRecord(ctx context.Context, userID string, record ImportRecord) (string, error) {
+	if err := validateURL(record.Original); err != nil {
+		return record.Code, err
+	}
+
+	if record.Clicks < 0 {
+		return record.Code, errors.New("clicks must not be negative")
+	}
+
+	if record.RedirectType != 0 && !model.ValidRedirectType(record.RedirectType) {
+		return record.Code, ErrInvalidRedirectType
+	}
+
+	code := record.Code
+	if code == "" {
+		code = generateCode(s.shortCodeLength)
+	} else if err := validateCustomCode(code); err != nil {
+		return code, err
+	}
+
+	limits, err := s.limits(ctx, model.Account{UserID: userID})
+	if err != nil {
+		return code, err
+	}
+
+	mapping := model.URLMapping{
+		Code:         code,
+		Original:     record.Original,
+		UserID:       userID,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    record.ExpiresAt,
+		Clicks:       record.Clicks,
+		RedirectType: record.RedirectType,
+	}
+	if record.CreatedAt != nil {
+		mapping.CreatedAt = *record.CreatedAt
+	}
+
+	err = s.save(ctx, mapping, limits.ActiveLinks)
+	if errors.Is(err, storage.ErrCodeExists) {
+		return code, errors.New("code already exists")
+	}
+	if errors.Is(err, ErrActiveLinksQuota) {
+		return code, err
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Import row failed",
+			slog.Group("input", slog.String("code", mapping.Code), slog.String("userID", mapping.UserID), slog.String("url", mapping.Original)),
+			slog.String("error", err.Error()),
+		)
+		return code, err
+	}
+
+	return code, nil
+}
+
+// validateURL accepts absolute http(s) URLs only
+func validateURL(raw string) error {
+	if raw == "" {
+		return errors.New("url is required")
+	}
+
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%w: %s", ErrInvalidURL, raw)
+	}
+
+	return nil
+}
+
+// validateCustomCode checks a user supplied code can be served as /{code}
+func validateCustomCode(code string) error {
+	if len(code) < 3 || len(code) > maxCustomCodeLength {
+		return fmt.Errorf("code must be between 3 and %d characters", maxCustomCodeLength)
+	}
+	if !customCodePattern.MatchString(code) {
+		return errors.New("code may only contain letters, digits, '-' and '_'")
+	}
+	if reservedCodes[code] {
+		return fmt.Errorf("code %q is reserved", code)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MetadataFetcher reads the metadata of the page at a URL, such as a
+// metadata.Fetcher
+type MetadataFetcher interface {
+	Fetch(ctx context.Context, url string) (*model.Metadata, error)
+}
+
+// maxPendingFetches bounds how many metadata fetches may be waiting or
+// running at once, so a burst of new links cannot pile up goroutines
+const maxPendingFetches = 64
+
+// WithMetadataFetcher makes the service fetch the destination of each new
+// link in the background and store what it finds on the link. Without it,
+// links have no metadata.
+func WithMetadataFetcher(fetcher MetadataFetcher) Option {
+	return func(s *ShortenerService) {
+		s.fetcher = fetcher
+		s.pendingFetches = make(chan struct{}, maxPendingFetches)
+	}
+}
+
+// enrich fetches the metadata of mapping's destination in the background.
+// Failures are only logged: a link works the same without metadata. The
+// fetch is skipped when maxPendingFetches are already pending. The task
+// takes its slot itself, so tasks the runner drops never hold one. Split
+// links without a URL of their own are not fetched.
+func (s *ShortenerService) enrich(ctx context.Context, mapping model.URLMapping) {
+	if s.fetcher == nil || mapping.Original == "" {
+		return
+	}
+
+	// The fetch keeps the trace but must outlive the request
+	ctx = context.WithoutCancel(ctx)
+	s.runner.Go("fetch_metadata", func() {
+		select {
+		case s.pendingFetches <- struct{}{}:
+			defer func() { <-s.pendingFetches }()
+		default:
+			s.logger.WarnContext(ctx, "Skipped link metadata, too many fetches pending",
+				slog.String("code", mapping.Code),
+			)
+			return
+		}
+
+		ctx, span := tracer.Start(ctx, "ShortenerService.enrich", trace.WithAttributes(
+			attribute.String("code", mapping.Code),
+		))
+		defer span.End()
+
+		metadata, err := s.fetcher.Fetch(ctx, mapping.Original)
+		if err == nil {
+			err = s.store.SaveMetadata(ctx, mapping.Domain, mapping.Code, mapping.Original, *metadata)
+			if errors.Is(err, storage.ErrNotFound) {
+				// The link was deleted while its page was fetched
+				return
+			}
+		}
+		if err != nil {
+			s.logger.WarnContext(ctx, "Failed to fetch link metadata",
+				slog.String("code", mapping.Code),
+				slog.String("url", mapping.Original),
+				slog.String("error", err.Error()),
+			)
+			failSpan(span, err)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	maxOrganizationNameLength = 100
+	// orgIDLength is the length of the random part of organization IDs
+	orgIDLength = 12
+)
+
+var (
+	// ErrInvalidOrganization is returned for organization names that are empty or too long
+	ErrInvalidOrganization = errors.New("organization name must be 1 to 100 characters")
+	// ErrOrganizationNotFound is returned for organizations that do not exist
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrInvalidRole is returned for roles other than the model's Role constants
+	ErrInvalidRole = errors.New("role must be one of owner, admin, editor, viewer")
+	// ErrInsufficientRole is returned when a member's role does not allow an action
+	ErrInsufficientRole = errors.New("role in the organization does not allow this")
+	// ErrMemberNotFound is returned for users who are not members of the organization
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrLastOwner is returned when the last owner of an organization would be removed or demoted
+	ErrLastOwner = errors.New("an organization must keep at least one owner")
+)
+
+// memberRole returns the role of userID in orgID, or ErrForbidden if they
+// are not a member
+func (s *ShortenerService) memberRole(ctx context.Context, userID, orgID string) (model.Role, error) {
+	member, err := s.store.GetMember(ctx, orgID, userID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	if member != nil {
+		return member.Role, nil
+	}
+
+	org, err := s.store.GetOrganization(ctx, orgID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	if org == nil {
+		return "", ErrOrganizationNotFound
+	}
+	return "", ErrForbidden
+}
+
+// accountFor returns the account userID works with: orgID's when set, which
+// userID must be a member of with a role that edits links if edit is set, or
+// else their personal links'
+func (s *ShortenerService) accountFor(ctx context.Context, userID, orgID string, edit bool) (model.Account, error) {
+	if orgID == "" {
+		return model.Account{UserID: userID}, nil
+	}
+
+	role, err := s.memberRole(ctx, userID, orgID)
+	if err != nil {
+		return model.Account{}, err
+	}
+	if edit && !role.CanEditLinks() {
+		return model.Account{}, ErrInsufficientRole
+	}
+	return model.Account{OrgID: orgID}, nil
+}
+
+// authorize checks that userID may see mapping, or change it when edit is
+// set. Personal links are only available to their owner; links of an
+// organization to its members, as their role allows.
+func (s *ShortenerService) authorize(ctx context.Context, userID string, mapping *model.URLMapping, edit bool) error {
+	if mapping.OrgID == "" {
+		if mapping.UserID != userID {
+			return ErrForbidden
+		}
+		return nil
+	}
+
+	role, err := s.memberRole(ctx, userID, mapping.OrgID)
+	if errors.Is(err, ErrOrganizationNotFound) {
+		return ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if edit && !role.CanEditLinks() {
+		return ErrInsufficientRole
+	}
+	return nil
+}
+
+// CreateOrganization creates an organization with userID as its owner
+func (s *ShortenerService) CreateOrganization(ctx context.Context, userID, name string) (*model.Organization, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.CreateOrganization", trace.WithAttributes(
+		attribute.String("user.id", userID),
+	))
+	defer span.End()
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxOrganizationNameLength {
+		return nil, ErrInvalidOrganization
+	}
+
+	now := time.Now()
+	org := model.Organization{ID: "org_" + generateCode(orgIDLength), Name: name, CreatedAt: now}
+	owner := model.Membership{OrgID: org.ID, UserID: userID, Role: model.RoleOwner, CreatedAt: now}
+	span.SetAttributes(attribute.String("org.id", org.ID))
+
+	if err := s.store.SaveOrganization(ctx, org, owner); err != nil {
+		s.logger.ErrorContext(ctx, "CreateOrganization failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("name", name)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// ListOrganizations returns the memberships of userID ordered by organization ID
+func (s *ShortenerService) ListOrganizations(ctx context.Context, userID string) ([]model.Membership, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ListOrganizations", trace.WithAttributes(
+		attribute.String("user.id", userID),
+	))
+	defer span.End()
+
+	memberships, err := s.store.ListMemberships(ctx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "ListOrganizations failed",
+			slog.String("userID", userID),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// ListMembers returns the members of orgID if userID is one of them
+func (s *ShortenerService) ListMembers(ctx context.Context, userID, orgID string) ([]model.Membership, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ListMembers", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+	))
+	defer span.End()
+
+	if _, err := s.memberRole(ctx, userID, orgID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.store.ListMembers(ctx, orgID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "ListMembers failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetMember adds memberID to orgID with role, or changes their role. Admins
+// manage members other than owners; only owners can make or change owners.
+func (s *ShortenerService) SetMember(ctx context.Context, userID, orgID, memberID string, role model.Role) (*model.Membership, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.SetMember", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+		attribute.String("member.id", memberID),
+		attribute.String("member.role", string(role)),
+	))
+	defer span.End()
+
+	if !model.ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	existing, err := s.checkManage(ctx, userID, orgID, memberID, role == model.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	member := model.Membership{OrgID: orgID, UserID: memberID, Role: role, CreatedAt: time.Now()}
+	if existing != nil {
+		member.CreatedAt = existing.CreatedAt
+	}
+
+	err = s.store.SaveMember(ctx, member)
+	if errors.Is(err, storage.ErrLastOwner) {
+		return nil, ErrLastOwner
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "SetMember failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID), slog.String("memberID", memberID)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// RemoveMember removes memberID from orgID. Members may always leave, unless
+// they are its last owner.
+func (s *ShortenerService) RemoveMember(ctx context.Context, userID, orgID, memberID string) error {
+	ctx, span := tracer.Start(ctx, "ShortenerService.RemoveMember", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+		attribute.String("member.id", memberID),
+	))
+	defer span.End()
+
+	var existing *model.Membership
+	var err error
+	if memberID == userID {
+		// memberRole tells why a user who is not a member cannot leave
+		if _, err = s.memberRole(ctx, userID, orgID); err != nil {
+			return err
+		}
+		existing, err = s.store.GetMember(ctx, orgID, memberID)
+	} else {
+		existing, err = s.checkManage(ctx, userID, orgID, memberID, false)
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	if existing == nil {
+		return ErrMemberNotFound
+	}
+
+	err = s.store.DeleteMember(ctx, orgID, memberID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrMemberNotFound
+	}
+	if errors.Is(err, storage.ErrLastOwner) {
+		return ErrLastOwner
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "RemoveMember failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID), slog.String("memberID", memberID)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// checkManage checks that userID may manage memberID in orgID, and returns
+// memberID's current membership, nil if they are not a member yet. Owners
+// can only be made or managed by other owners.
+func (s *ShortenerService) checkManage(ctx context.Context, userID, orgID, memberID string, makeOwner bool) (*model.Membership, error) {
+	role, err := s.memberRole(ctx, userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !role.CanManageMembers() {
+		return nil, ErrInsufficientRole
+	}
+
+	existing, err := s.store.GetMember(ctx, orgID, memberID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	ownerChange := makeOwner || (existing != nil && existing.Role == model.RoleOwner)
+	if ownerChange && role != model.RoleOwner {
+		return nil, ErrInsufficientRole
+	}
+	return existing, nil
+}
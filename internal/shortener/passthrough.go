@@ -0,0 +1,63 @@
+package shortener
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+// destination builds the URL a request for mapping redirects to from target,
+// carrying over the request's query string and path suffix as the mapping
+// allows
+func destination(mapping *model.URLMapping, target string, req ResolveRequest) (string, error) {
+	forwardQuery := req.RawQuery != "" && mapping.QueryPassthrough != model.QueryPassthroughNone
+	forwardPath := req.PathSuffix != "" && mapping.PathPassthrough
+	if !forwardQuery && !forwardPath {
+		return target, nil
+	}
+
+	dest, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+
+	if forwardPath {
+		appendPath(dest, req.PathSuffix)
+	}
+	if forwardQuery {
+		incoming, _ := url.ParseQuery(req.RawQuery)
+		dest.RawQuery = mergeQuery(dest.Query(), incoming, mapping.QueryPassthrough).Encode()
+	}
+
+	return dest.String(), nil
+}
+
+// appendPath joins suffix onto the path of dest. The suffix is cleaned first
+// so dot segments cannot climb out of the destination's path.
+func appendPath(dest *url.URL, suffix string) {
+	suffix = path.Clean("/" + suffix)
+	if suffix == "/" {
+		return
+	}
+	dest.Path = strings.TrimSuffix(dest.Path, "/") + suffix
+	dest.RawPath = ""
+}
+
+// mergeQuery merges incoming into dest, resolving parameters present in both
+// according to policy
+func mergeQuery(dest, incoming url.Values, policy string) url.Values {
+	for key, values := range incoming {
+		_, exists := dest[key]
+		switch {
+		case !exists:
+			dest[key] = values
+		case policy == model.QueryPassthroughOverride:
+			dest[key] = values
+		case policy == model.QueryPassthroughAppend:
+			dest[key] = append(dest[key], values...)
+		}
+	}
+	return dest
+}
@@ -0,0 +1,149 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 4
+	// maxPasswordLength is the most bcrypt will hash
+	maxPasswordLength = 72
+
+	defaultPasswordMaxAttempts   = 5
+	defaultPasswordAttemptWindow = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidPassword is returned for passwords bcrypt cannot hash or that are too short
+	ErrInvalidPassword = fmt.Errorf("password must be between %d and %d bytes", minPasswordLength, maxPasswordLength)
+	// ErrPasswordRequired is returned when resolving a protected link without a password
+	ErrPasswordRequired = errors.New("password required")
+	// ErrIncorrectPassword is returned when resolving a protected link with the wrong password
+	ErrIncorrectPassword = errors.New("incorrect password")
+	// ErrTooManyAttempts is wrapped by TooManyAttemptsError
+	ErrTooManyAttempts = errors.New("too many password attempts")
+)
+
+// TooManyAttemptsError is returned when a client has used up its password
+// attempts for a link. It matches ErrTooManyAttempts.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// hashPassword returns the bcrypt hash of password
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword verifies the password of a request for a protected mapping.
+// Attempts are counted per link and client before the password is compared,
+// so concurrent guesses cannot all get through, and once a client runs out
+// of attempts even the right password is refused until its window ends. The
+// right password clears the count. Links are told apart by domain too, as
+// the same code may be taken on several.
+func (s *ShortenerService) checkPassword(mapping *model.URLMapping, req ResolveRequest) error {
+	if req.Password == "" {
+		return ErrPasswordRequired
+	}
+
+	key := mapping.Domain + "/" + mapping.Code + "|" + req.ClientID
+	if retryAfter, ok := s.attempts.allow(key); !ok {
+		return &TooManyAttemptsError{RetryAfter: retryAfter}
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(mapping.PasswordHash), []byte(req.Password)) != nil {
+		return ErrIncorrectPassword
+	}
+
+	s.attempts.reset(key)
+	return nil
+}
+
+// attemptLimiter counts attempts per key in fixed windows that start with
+// the first attempt. A max of 0 disables the limit.
+type attemptLimiter struct {
+	max    int
+	window time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]attemptWindow
+}
+
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+// pruneThreshold is the number of tracked keys above which ended windows are
+// dropped, bounding memory under attempts spread across many links
+const pruneThreshold = 1024
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		now:      time.Now,
+		attempts: make(map[string]attemptWindow),
+	}
+}
+
+// allow reserves an attempt for key, or reports how long until it may make
+// one. Checking and counting happen under one lock, so concurrent callers
+// cannot get past max between them.
+func (l *attemptLimiter) allow(key string) (time.Duration, bool) {
+	if l.max <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, exists := l.attempts[key]
+	if !exists || now.Sub(w.start) >= l.window {
+		w = attemptWindow{start: now}
+	}
+	if w.count >= l.max {
+		return w.start.Add(l.window).Sub(now), false
+	}
+	w.count++
+	l.attempts[key] = w
+
+	if len(l.attempts) > pruneThreshold {
+		for k, w := range l.attempts {
+			if now.Sub(w.start) >= l.window {
+				delete(l.attempts, k)
+			}
+		}
+	}
+	return 0, true
+}
+
+// reset forgets the attempts of key, after it got the password right
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, key)
+}
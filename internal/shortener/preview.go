@@ -0,0 +1,20 @@
+package shortener
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// maxTitleLength is the longest title a link may have, in characters
+const maxTitleLength = 200
+
+// ErrInvalidTitle is returned for titles longer than maxTitleLength
+var ErrInvalidTitle = errors.New("title must be at most 200 characters")
+
+// validateTitle checks that title fits on the preview page
+func validateTitle(title string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return ErrInvalidTitle
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrActiveLinksQuota is returned when an account already has as many active links as its quota allows
+	ErrActiveLinksQuota = errors.New("active link quota reached")
+	// ErrRedirectQuota is returned when resolving a link whose account has served as many redirects this month as its quota allows
+	ErrRedirectQuota = errors.New("monthly redirect quota reached")
+	// ErrInvalidQuota is returned for negative quota limits
+	ErrInvalidQuota = errors.New("quota limits must not be negative")
+	// ErrInvalidAccount is returned for quotas set on both or neither of a user and an organization
+	ErrInvalidAccount = errors.New("quota must be set for either a user or an organization")
+)
+
+// Usage reports what an account has used against its limits
+type Usage struct {
+	Account model.Account
+	// Month is the start of the calendar month redirects are counted in, in UTC
+	Month            time.Time
+	ActiveLinks      int
+	MonthlyRedirects int
+	Limits           model.Limits
+}
+
+// WithQuotas limits every account to defaults, unless the quota saved for it
+// overrides them. Without it, accounts are not limited.
+func WithQuotas(defaults model.Limits) Option {
+	return func(s *ShortenerService) {
+		s.quotas = &defaults
+	}
+}
+
+// limits returns the limits of account, with its saved quota applied
+func (s *ShortenerService) limits(ctx context.Context, account model.Account) (model.Limits, error) {
+	if s.quotas == nil {
+		return model.Limits{}, nil
+	}
+
+	quota, err := s.store.GetQuota(ctx, account)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return model.Limits{}, err
+	}
+	return quota.Apply(*s.quotas), nil
+}
+
+// save stores a new mapping, or returns ErrActiveLinksQuota if its account
+// already has maxActiveLinks active links. The store counts and inserts in
+// one step, so concurrent creates cannot go past the quota. A maxActiveLinks
+// of 0 means no limit. Once the monthly redirect quota is reached, links stop
+// redirecting; see countClick.
+func (s *ShortenerService) save(ctx context.Context, mapping model.URLMapping, maxActiveLinks int) error {
+	if maxActiveLinks == 0 {
+		return s.store.Save(ctx, mapping)
+	}
+
+	err := s.store.SaveWithinLimit(ctx, mapping, maxActiveLinks)
+	if errors.Is(err, storage.ErrActiveLinksLimitReached) {
+		return fmt.Errorf("%w: limit is %d", ErrActiveLinksQuota, maxActiveLinks)
+	}
+	return err
+}
+
+// Usage returns the usage of userID's personal links, or of orgID's links
+// when set, which userID must be a member of
+func (s *ShortenerService) Usage(ctx context.Context, userID, orgID string) (*Usage, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.Usage", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+	))
+	defer span.End()
+
+	account, err := s.accountFor(ctx, userID, orgID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	usage := &Usage{Account: account, Month: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)}
+
+	if usage.Limits, err = s.limits(ctx, account); err == nil {
+		if usage.ActiveLinks, err = s.store.CountActiveLinks(ctx, account); err == nil {
+			usage.MonthlyRedirects, err = s.store.MonthlyRedirects(ctx, account, usage.Month)
+		}
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Usage failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// SetQuota overrides the default limits of an account and returns the
+// limits it now has. It is meant for operators and does not check who is
+// asking.
+func (s *ShortenerService) SetQuota(ctx context.Context, quota model.Quota) (model.Limits, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.SetQuota", trace.WithAttributes(
+		attribute.String("user.id", quota.Account.UserID),
+		attribute.String("org.id", quota.Account.OrgID),
+	))
+	defer span.End()
+
+	if (quota.Account.UserID == "") == (quota.Account.OrgID == "") {
+		return model.Limits{}, ErrInvalidAccount
+	}
+	if (quota.ActiveLinks != nil && *quota.ActiveLinks < 0) || (quota.MonthlyRedirects != nil && *quota.MonthlyRedirects < 0) {
+		return model.Limits{}, ErrInvalidQuota
+	}
+
+	if quota.Account.OrgID != "" {
+		org, err := s.store.GetOrganization(ctx, quota.Account.OrgID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return model.Limits{}, err
+		}
+		if org == nil {
+			return model.Limits{}, ErrOrganizationNotFound
+		}
+	}
+
+	if err := s.store.SaveQuota(ctx, quota); err != nil {
+		s.logger.ErrorContext(ctx, "SetQuota failed",
+			slog.Group("input", slog.String("userID", quota.Account.UserID), slog.String("orgID", quota.Account.OrgID)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return model.Limits{}, err
+	}
+
+	var defaults model.Limits
+	if s.quotas != nil {
+		defaults = *s.quotas
+	}
+	return quota.Apply(defaults), nil
+}
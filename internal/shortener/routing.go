@@ -0,0 +1,205 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+// maxRoutingRules is how many rules a mapping may have
+const maxRoutingRules = 20
+
+// ErrInvalidRoutingRule is returned for rules with unknown conditions, no
+// conditions or a destination that is not an absolute http(s) URL
+var ErrInvalidRoutingRule = errors.New("invalid routing rule")
+
+var (
+	languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+	headerNamePattern  = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+)
+
+// validateRules checks that every rule can match something and redirects to
+// a valid URL
+func validateRules(rules []model.RoutingRule) error {
+	if len(rules) > maxRoutingRules {
+		return fmt.Errorf("%w: a link may have at most %d rules", ErrInvalidRoutingRule, maxRoutingRules)
+	}
+
+	for i, rule := range rules {
+		var problem string
+		switch {
+		case rule.Unconditional():
+			problem = "it needs at least one condition"
+		case rule.Platform != "" && !model.ValidPlatform(rule.Platform):
+			problem = "platform must be one of ios, android, windows, macos, linux"
+		case rule.Device != "" && !model.ValidDevice(rule.Device):
+			problem = "device must be one of mobile, tablet, desktop"
+		case rule.Language != "" && !languageTagPattern.MatchString(rule.Language):
+			problem = "language must be a language tag such as en or pt-BR"
+		case validateURL(rule.URL) != nil:
+			problem = "url must be an absolute http(s) URL"
+		}
+		for _, name := range slices.Sorted(maps.Keys(rule.Headers)) {
+			if problem == "" && !headerNamePattern.MatchString(name) {
+				problem = fmt.Sprintf("%q is not a valid header name", name)
+			}
+		}
+		if problem != "" {
+			return fmt.Errorf("%w %d: %s", ErrInvalidRoutingRule, i+1, problem)
+		}
+	}
+
+	return nil
+}
+
+// routingClient is what rules match a request on
+type routingClient struct {
+	platform string
+	device   string
+	language string
+	header   http.Header
+}
+
+func newRoutingClient(header http.Header) routingClient {
+	platform, device := detectPlatform(header.Get("User-Agent"))
+	return routingClient{
+		platform: platform,
+		device:   device,
+		language: preferredLanguage(header.Get("Accept-Language")),
+		header:   header,
+	}
+}
+
+// matchRule returns the first of rules that matches a request with header,
+// or nil if none does
+func matchRule(rules []model.RoutingRule, header http.Header) *model.RoutingRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	client := newRoutingClient(header)
+	for i := range rules {
+		if client.matches(rules[i]) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+func (c routingClient) matches(rule model.RoutingRule) bool {
+	if rule.Platform != "" && rule.Platform != c.platform {
+		return false
+	}
+	if rule.Device != "" && rule.Device != c.device {
+		return false
+	}
+	if rule.Language != "" && !languageMatches(rule.Language, c.language) {
+		return false
+	}
+	for name, value := range rule.Headers {
+		values := c.header.Values(name)
+		if len(values) == 0 || (value != "" && !strings.EqualFold(values[0], value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectPlatform guesses the platform and device class of a User-Agent.
+// Either is empty when it cannot be told.
+func detectPlatform(userAgent string) (platform, device string) {
+	ua := strings.ToLower(userAgent)
+	// iOS and Android user agents also mention Mac OS X and Linux, so they
+	// are checked first
+	switch {
+	case strings.Contains(ua, "ipad"):
+		return model.PlatformIOS, model.DeviceTablet
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		return model.PlatformIOS, model.DeviceMobile
+	case strings.Contains(ua, "android"):
+		// Android tablets leave "Mobile" out of their user agent
+		if strings.Contains(ua, "mobile") {
+			return model.PlatformAndroid, model.DeviceMobile
+		}
+		return model.PlatformAndroid, model.DeviceTablet
+	case strings.Contains(ua, "windows"):
+		return model.PlatformWindows, model.DeviceDesktop
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return model.PlatformMacOS, model.DeviceDesktop
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return model.PlatformLinux, model.DeviceDesktop
+	case strings.Contains(ua, "mobile"):
+		return "", model.DeviceMobile
+	}
+	return "", ""
+}
+
+// preferredLanguage returns the language tag with the highest weight in an
+// Accept-Language header, the first one on ties
+func preferredLanguage(acceptLanguage string) string {
+	best, bestWeight := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		if weight > bestWeight {
+			best, bestWeight = tag, weight
+		}
+	}
+	return best
+}
+
+// languageMatches reports whether a rule's language tag matches language,
+// either exactly or as its primary subtag
+func languageMatches(ruleTag, language string) bool {
+	if strings.EqualFold(ruleTag, language) {
+		return true
+	}
+	return len(language) > len(ruleTag) && language[len(ruleTag)] == '-' && strings.EqualFold(language[:len(ruleTag)], ruleTag)
+}
+
+// varyHeaders returns the request headers rules depend on, for the Vary
+// header of redirects
+func varyHeaders(rules []model.RoutingRule) string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		name = http.CanonicalHeaderKey(name)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.Platform != "" || rule.Device != "" {
+			add("User-Agent")
+		}
+		if rule.Language != "" {
+			add("Accept-Language")
+		}
+		for _, name := range slices.Sorted(maps.Keys(rule.Headers)) {
+			add(name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,55 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxSearchLength = 200
+
+// ErrInvalidSearch is returned for search queries without words or longer than maxSearchLength
+var ErrInvalidSearch = errors.New("search query must contain a letter or digit and be at most 200 characters")
+
+// SearchMappings returns up to limit of userID's personal links, or of
+// orgID's links when set, that match every word of query in their code,
+// title, tags or destination. The most relevant come first.
+func (s *ShortenerService) SearchMappings(ctx context.Context, userID, orgID, query string, limit int) ([]model.URLMapping, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.SearchMappings", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+	))
+	defer span.End()
+
+	query = strings.TrimSpace(query)
+	if utf8.RuneCountInString(query) > maxSearchLength || !strings.ContainsFunc(query, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) {
+		return nil, ErrInvalidSearch
+	}
+
+	account, err := s.accountFor(ctx, userID, orgID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings, err := s.store.SearchMappings(ctx, account, query, limit)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "SearchMappings failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID), slog.String("query", query)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("mappings.count", len(mappings)))
+	return mappings, nil
+}
@@ -1,107 +1,829 @@
 package shortener
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"math/rand"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/wiredmatt/go_short/internal/logging"
+	"github.com/wiredmatt/go_short/internal/metrics"
 	"github.com/wiredmatt/go_short/internal/model"
 	"github.com/wiredmatt/go_short/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/wiredmatt/go_short/internal/shortener")
+
 // Shortener defines the interface for URL shortening operations
 type Shortener interface {
 	GetBaseURL() string
-	Shorten(userID, originalURL string) (string, error)
-	Resolve(code string) (string, error)
-	ListMappings(userID string) ([]model.URLMapping, error)
+	Shorten(ctx context.Context, req ShortenRequest) (string, error)
+	Resolve(ctx context.Context, req ResolveRequest) (*Resolution, error)
+	ListMappings(ctx context.Context, userID, orgID string, filter model.MappingFilter) ([]model.URLMapping, error)
+	GetMapping(ctx context.Context, userID, domain, code string) (*model.URLMapping, error)
+	ShortURL(ctx context.Context, host, code string) (string, error)
+	UpdateMapping(ctx context.Context, userID, domain, code string, update MappingUpdate) (*model.URLMapping, error)
+	DeleteMapping(ctx context.Context, userID, domain, code string) error
+	ImportMappings(ctx context.Context, userID string, records []ImportRecord) ImportResult
+	SaveUTMTemplate(ctx context.Context, userID, name string, utm model.UTM) (*model.UTMTemplate, error)
+	ListUTMTemplates(ctx context.Context, userID string) ([]model.UTMTemplate, error)
+	DeleteUTMTemplate(ctx context.Context, userID, name string) error
+	RegisterDomain(ctx context.Context, userID, name string) (*model.Domain, error)
+	ListDomains(ctx context.Context, userID string) ([]model.Domain, error)
+	DeleteDomain(ctx context.Context, userID, name string) error
+	CreateOrganization(ctx context.Context, userID, name string) (*model.Organization, error)
+	ListOrganizations(ctx context.Context, userID string) ([]model.Membership, error)
+	ListMembers(ctx context.Context, userID, orgID string) ([]model.Membership, error)
+	SetMember(ctx context.Context, userID, orgID, memberID string, role model.Role) (*model.Membership, error)
+	RemoveMember(ctx context.Context, userID, orgID, memberID string) error
+	Usage(ctx context.Context, userID, orgID string) (*Usage, error)
+	SetQuota(ctx context.Context, quota model.Quota) (model.Limits, error)
+	ListTags(ctx context.Context, userID, orgID string) ([]model.TagCount, error)
+	RenameTag(ctx context.Context, userID, orgID, from, to string) (*model.TagCount, error)
+	SearchMappings(ctx context.Context, userID, orgID, query string, limit int) ([]model.URLMapping, error)
+}
+
+var (
+	// ErrNotFound is returned when a code does not map to any URL
+	ErrNotFound = errors.New("code not found")
+	// ErrForbidden is returned when a user tries to access a mapping they do not own
+	ErrForbidden = errors.New("mapping belongs to another user")
+	// ErrInvalidRedirectType is returned for redirect types other than 301, 302, 307 and 308
+	ErrInvalidRedirectType = errors.New("redirect type must be one of 301, 302, 307, 308")
+	// ErrClickLimitReached is returned when resolving a link that used up its max clicks
+	ErrClickLimitReached = errors.New("link has reached its click limit")
+	// ErrInvalidMaxClicks is returned for negative click limits
+	ErrInvalidMaxClicks = errors.New("max clicks must not be negative")
+	// ErrInvalidURL is returned for destinations that are not absolute http(s) URLs
+	ErrInvalidURL = errors.New("invalid url")
+	// ErrInvalidQueryPassthrough is returned for unknown query passthrough policies
+	ErrInvalidQueryPassthrough = errors.New("query passthrough must be one of merge, override, append")
+)
+
+// ShortenRequest describes a link to create
+type ShortenRequest struct {
+	UserID string
+	URL    string
+	// Domain is a registered domain of the user to create the link on,
+	// empty for the default one
+	Domain string
+	// OrgID is the organization to create the link for, empty for a
+	// personal link. The user must be allowed to edit its links.
+	OrgID string
+	// RedirectType is the HTTP status used to redirect, 0 uses the server default
+	RedirectType int
+	// QueryPassthrough is how request query strings are merged into URL
+	QueryPassthrough string
+	// PathPassthrough appends path segments after the code to URL
+	PathPassthrough bool
+	// UTM parameters are composed into URL. Parameters left empty are taken
+	// from the user's template named UTMTemplate, if set.
+	UTM         model.UTM
+	UTMTemplate string
+	// Password, when set, must be given to follow the link
+	Password string
+	// MaxClicks is how many times the link may be followed, 0 for no limit
+	MaxClicks int
+	// ActivatesAt is when the link starts redirecting, nil for right away
+	ActivatesAt *time.Time
+	// Rules route matching requests to other destinations, in order
+	Rules []model.RoutingRule
+	// Variants split traffic between destinations by weight, in place of URL.
+	// StickyVariants keeps each visitor on the variant they got first.
+	Variants       []model.Variant
+	StickyVariants bool
+	// Title is shown on the link's preview page
+	Title string
+	// Interstitial shows the preview page instead of redirecting
+	Interstitial bool
+	// Tags are normalized to lowercase, sorted and deduplicated
+	Tags   []string
+	Folder string
+}
+
+// MappingUpdate holds the settings to change on a mapping. Nil fields are
+// left as they are.
+type MappingUpdate struct {
+	// ActivatesAt sets when the link starts redirecting, the zero time clears it
+	ActivatesAt *time.Time
+	// Rules replaces the routing rules, an empty list removes them
+	Rules *[]model.RoutingRule
+	// Variants replaces the variants, an empty list stops splitting traffic
+	Variants       *[]model.Variant
+	StickyVariants *bool
+	Title          *string
+	Interstitial   *bool
+	Tags           *[]string
+	Folder         *string
+}
+
+// ResolveRequest is a request for a short link
+type ResolveRequest struct {
+	// Host is the request's Host, which selects the domain code is looked up on
+	Host string
+	Code string
+	// RawQuery is the request's query string, without the '?'
+	RawQuery string
+	// PathSuffix is the unescaped path after the code, without the leading '/'
+	PathSuffix string
+	// Password is checked against protected links
+	Password string
+	// ClientID identifies the client, such as its IP, to rate limit password attempts
+	ClientID string
+	// Header holds the request headers routing rules match on
+	Header http.Header
+	// Variant is the variant a sticky split link served the visitor before
+	Variant string
+	// Preview asks where the link goes without following it. Previews are
+	// not counted as clicks.
+	Preview bool
+}
+
+// Resolution is where a code redirects to and how
+type Resolution struct {
+	URL string
+	// StatusCode is the mapping's redirect type, or the server default
+	StatusCode int
+	ExpiresAt  *time.Time
+	// NoStore is set for links whose every use must reach the server, such as
+	// password protected, click limited and split ones, so redirects must
+	// not be cached
+	NoStore bool
+	// Vary lists the request headers the link's routing rules depend on
+	Vary string
+	// Variant is the variant of a split link that served the request, and
+	// StickyVariant whether the visitor should keep getting it
+	Variant       string
+	StickyVariant bool
+	// Interstitial is set when a page showing URL, Title and CreatedAt must
+	// be served instead of redirecting
+	Interstitial bool
+	Title        string
+	CreatedAt    time.Time
 }
 
 type ShortenerService struct {
-	store           storage.Store
-	baseURL         string
+	store   storage.Store
+	baseURL string
+	// baseHost is the host of baseURL, which serves the default domain
+	baseHost        string
 	shortCodeLength int
 	logger          *slog.Logger
+	runner          Runner
+	defaultRedirect int
+	attempts        *attemptLimiter
+	// quotas are the default limits of accounts, nil when they are not limited
+	quotas *model.Limits
+	// fetcher reads the metadata of new links' destinations, nil when they
+	// are not fetched
+	fetcher        MetadataFetcher
+	pendingFetches chan struct{}
+	// roll returns a random number in [0, n) to pick variants with
+	roll func(n int) int
+}
+
+// Runner runs work that must not block the caller, such as counting clicks.
+// The app supplies one that tracks the work so shutdown can wait for it.
+type Runner interface {
+	Go(task string, fn func())
+}
+
+// goRunner runs each task on its own untracked goroutine
+type goRunner struct{}
+
+func (goRunner) Go(_ string, fn func()) {
+	go fn()
+}
+
+// Option customizes a ShortenerService
+type Option func(*ShortenerService)
+
+// WithLogger sets the logger used by the service. It should be built on a
+// logging.ContextHandler so log lines carry the request ID.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *ShortenerService) {
+		s.logger = logger
+	}
+}
+
+// WithRunner sets the runner used for background work
+func WithRunner(runner Runner) Option {
+	return func(s *ShortenerService) {
+		s.runner = runner
+	}
 }
 
-func NewService(store storage.Store, baseURL string, shortCodeLength int) *ShortenerService {
-	return &ShortenerService{
+// WithDefaultRedirectType sets the redirect type of mappings that do not set
+// their own. Values other than 301, 302, 307 and 308 keep the default of 302.
+func WithDefaultRedirectType(status int) Option {
+	return func(s *ShortenerService) {
+		if model.ValidRedirectType(status) {
+			s.defaultRedirect = status
+		}
+	}
+}
+
+// WithPasswordAttempts allows each client max failed password attempts per
+// link within window. A max of 0 disables the limit.
+func WithPasswordAttempts(max int, window time.Duration) Option {
+	return func(s *ShortenerService) {
+		s.attempts = newAttemptLimiter(max, window)
+	}
+}
+
+func NewService(store storage.Store, baseURL string, shortCodeLength int, opts ...Option) *ShortenerService {
+	s := &ShortenerService{
 		store:           store,
 		baseURL:         baseURL,
+		baseHost:        baseHost(baseURL),
 		shortCodeLength: shortCodeLength,
-		logger: slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		})),
+		logger:          logging.New(os.Stdout, slog.LevelInfo),
+		runner:          goRunner{},
+		defaultRedirect: model.RedirectFound,
+		attempts:        newAttemptLimiter(defaultPasswordMaxAttempts, defaultPasswordAttemptWindow),
+		roll:            rand.Intn,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *ShortenerService) GetBaseURL() string {
 	return s.baseURL
 }
 
-func (s *ShortenerService) Shorten(userID, originalURL string) (string, error) {
-	s.logger.Info("Shortening new url: ", slog.String("originalURL", originalURL))
+func (s *ShortenerService) Shorten(ctx context.Context, req ShortenRequest) (string, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.Shorten", trace.WithAttributes(
+		attribute.String("user.id", req.UserID),
+	))
+	defer span.End()
+
+	s.logger.InfoContext(ctx, "Shortening new url: ", slog.String("originalURL", req.URL))
+
+	// Variants stand in for the URL, which is then optional
+	if req.URL != "" || len(req.Variants) == 0 {
+		if err := validateURL(req.URL); err != nil {
+			if !errors.Is(err, ErrInvalidURL) {
+				err = fmt.Errorf("%w: %s", ErrInvalidURL, err)
+			}
+			return "", err
+		}
+	}
+
+	if req.RedirectType != 0 && !model.ValidRedirectType(req.RedirectType) {
+		return "", ErrInvalidRedirectType
+	}
+
+	if !model.ValidQueryPassthrough(req.QueryPassthrough) {
+		return "", ErrInvalidQueryPassthrough
+	}
+
+	if req.MaxClicks < 0 {
+		return "", ErrInvalidMaxClicks
+	}
+
+	if err := validateRules(req.Rules); err != nil {
+		return "", err
+	}
+
+	if err := validateVariants(req.Variants); err != nil {
+		return "", err
+	}
+
+	if err := validateTitle(req.Title); err != nil {
+		return "", err
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	folder, err := normalizeFolder(req.Folder)
+	if err != nil {
+		return "", err
+	}
+
+	domain := normalizeHost(req.Domain)
+	if err := s.ownDomain(ctx, req.UserID, domain); err != nil {
+		return "", err
+	}
+
+	if req.OrgID != "" {
+		role, err := s.memberRole(ctx, req.UserID, req.OrgID)
+		if err != nil {
+			return "", err
+		}
+		if !role.CanEditLinks() {
+			return "", ErrInsufficientRole
+		}
+	}
+
+	account := model.Account{UserID: req.UserID}
+	if req.OrgID != "" {
+		account = model.Account{OrgID: req.OrgID}
+	}
+	limits, err := s.limits(ctx, account)
+	if err != nil {
+		return "", err
+	}
+
+	destination, utm, err := s.applyUTM(ctx, req)
+	if err != nil {
+		failSpan(span, err)
+		return "", err
+	}
+
+	var passwordHash string
+	if req.Password != "" {
+		if passwordHash, err = hashPassword(req.Password); err != nil {
+			return "", err
+		}
+	}
 
 	code := generateCode(s.shortCodeLength)
 	mapping := model.URLMapping{
-		Code:      code,
-		Original:  originalURL,
-		UserID:    userID,
-		CreatedAt: time.Now(),
+		Domain:           domain,
+		Code:             code,
+		Original:         destination,
+		UserID:           req.UserID,
+		OrgID:            req.OrgID,
+		CreatedAt:        time.Now(),
+		ActivatesAt:      req.ActivatesAt,
+		MaxClicks:        req.MaxClicks,
+		RedirectType:     req.RedirectType,
+		QueryPassthrough: req.QueryPassthrough,
+		PathPassthrough:  req.PathPassthrough,
+		UTM:              utm,
+		Rules:            req.Rules,
+		Variants:         req.Variants,
+		StickyVariants:   req.StickyVariants,
+		Title:            req.Title,
+		Interstitial:     req.Interstitial,
+		Tags:             tags,
+		Folder:           folder,
+		PasswordHash:     passwordHash,
+	}
+	span.SetAttributes(attribute.String("code", code))
+
+	if err := validateActivation(mapping); err != nil {
+		return "", err
+	}
+
+	err = s.save(ctx, mapping, limits.ActiveLinks)
+	// Random codes rarely collide; draw another one when they do
+	for attempt := 1; errors.Is(err, storage.ErrCodeExists) && attempt < maxCodeAttempts; attempt++ {
+		mapping.Code = generateCode(s.shortCodeLength)
+		span.SetAttributes(attribute.String("code", mapping.Code))
+		err = s.save(ctx, mapping, limits.ActiveLinks)
+	}
+	if errors.Is(err, ErrActiveLinksQuota) {
+		return "", err
 	}
-	err := s.store.Save(mapping)
 	if err != nil {
-		s.logger.Error("Shorten failed",
-			slog.Any("input", mapping),
+		s.logger.ErrorContext(ctx, "Shorten failed",
+			slog.Group("input", slog.String("code", mapping.Code), slog.String("userID", mapping.UserID), slog.String("url", mapping.Original)),
 			slog.String("error", err.Error()),
 		)
+		failSpan(span, err)
 		return "", err
 	}
-	return code, nil
+
+	metrics.LinksCreated.Inc()
+	s.enrich(ctx, mapping)
+	return mapping.Code, nil
+}
+
+func (s *ShortenerService) Resolve(ctx context.Context, req ResolveRequest) (*Resolution, error) {
+	code := req.Code
+	ctx, span := tracer.Start(ctx, "ShortenerService.Resolve", trace.WithAttributes(
+		attribute.String("code", code),
+	))
+	defer span.End()
+
+	domain, err := s.domainOf(ctx, req.Host)
+	if err == nil {
+		var mapping *model.URLMapping
+		mapping, err = s.store.Get(ctx, domain, code)
+		if mapping != nil {
+			return s.resolve(ctx, span, mapping, req)
+		}
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.logger.ErrorContext(ctx, "Resolve failed",
+			slog.Group("input", slog.String("host", req.Host), slog.String("code", code)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return nil, s.miss(ctx, span, domain, code)
 }
 
-func (s *ShortenerService) Resolve(code string) (string, error) {
-	original_url, err := s.store.Get(code)
+// resolve decides where mapping sends req and counts the click
+func (s *ShortenerService) resolve(ctx context.Context, span trace.Span, mapping *model.URLMapping, req ResolveRequest) (*Resolution, error) {
+	code := mapping.Code
+	if mapping.Domain != "" {
+		span.SetAttributes(attribute.String("domain", mapping.Domain))
+	}
+
+	// Only prefix links have anything below the code
+	if req.PathSuffix != "" && !mapping.PathPassthrough {
+		metrics.RedirectMisses.WithLabelValues(metrics.MissNotFound).Inc()
+		span.SetAttributes(attribute.String("miss.reason", metrics.MissNotFound))
+		return nil, ErrNotFound
+	}
+
+	if mapping.Exhausted() {
+		metrics.RedirectMisses.WithLabelValues(metrics.MissExhausted).Inc()
+		span.SetAttributes(attribute.String("miss.reason", metrics.MissExhausted))
+		return nil, ErrClickLimitReached
+	}
+
+	if mapping.Protected() {
+		if err := s.checkPassword(mapping, req); err != nil {
+			span.SetAttributes(attribute.String("password.error", err.Error()))
+			return nil, err
+		}
+	}
+
+	// Routing rules take precedence over variants, which replace Original
+	target := mapping.Original
+	var variant *model.Variant
+	if rule := matchRule(mapping.Rules, req.Header); rule != nil {
+		target = rule.URL
+	} else if variant = s.pickVariant(mapping, req); variant != nil {
+		target = variant.URL
+		span.SetAttributes(attribute.String("variant", variant.Name))
+	}
+
+	location, err := destination(mapping, target, req)
 	if err != nil {
-		s.logger.Error("Resolve failed",
+		s.logger.ErrorContext(ctx, "Resolve failed",
 			slog.Group("input", slog.String("code", code)),
 			slog.String("error", err.Error()),
 		)
-		return "", err
+		failSpan(span, err)
+		return nil, err
+	}
+
+	resolution := &Resolution{
+		URL:          location,
+		StatusCode:   mapping.RedirectType,
+		ExpiresAt:    mapping.ExpiresAt,
+		NoStore:      mapping.Protected() || mapping.MaxClicks > 0 || len(mapping.Variants) > 0,
+		Vary:         varyHeaders(mapping.Rules),
+		Interstitial: req.Preview || mapping.Interstitial,
+		Title:        mapping.Title,
+		CreatedAt:    mapping.CreatedAt,
+	}
+
+	// Previews only show the destination, so they neither count a click nor
+	// keep a visitor on the variant they saw
+	if req.Preview {
+		span.SetAttributes(attribute.Bool("preview", true))
+		return resolution, nil
 	}
 
-	if original_url == nil {
-		return "", errors.New("code not found")
+	if err := s.countClick(ctx, span, mapping, variant); err != nil {
+		return nil, err
+	}
+
+	metrics.RedirectsServed.Inc()
+
+	if variant != nil {
+		resolution.Variant = variant.Name
+		resolution.StickyVariant = mapping.StickyVariants
 	}
+	if resolution.StatusCode == 0 {
+		resolution.StatusCode = s.defaultRedirect
+	}
+	span.SetAttributes(attribute.Int("redirect.status", resolution.StatusCode))
+
+	return resolution, nil
+}
+
+// countClick records a click on mapping and on the variant that served it,
+// if any. Limited links, and links of accounts with a monthly redirect
+// quota, use up a click before redirecting, so concurrent requests for the
+// last one cannot both get through; other counts are updated in the
+// background.
+func (s *ShortenerService) countClick(ctx context.Context, span trace.Span, mapping *model.URLMapping, variant *model.Variant) error {
+	domain, code := mapping.Domain, mapping.Code
 
-	// Increment click count asynchronously to avoid blocking the redirect
-	go func() {
-		if err := s.store.IncrementClickCount(code); err != nil {
-			s.logger.Warn("Failed to increment click count",
-				slog.String("code", code),
-				slog.String("error", err.Error()),
-			)
+	// The contexts of background work keep the trace but must outlive the
+	// request
+	clickCtx := context.WithoutCancel(ctx)
+
+	limits, err := s.limits(ctx, model.AccountOf(*mapping))
+	if err == nil && (mapping.MaxClicks > 0 || limits.MonthlyRedirects > 0) {
+		err = s.store.ConsumeClick(ctx, domain, code, limits.MonthlyRedirects)
+		if errors.Is(err, storage.ErrClickLimitReached) {
+			metrics.RedirectMisses.WithLabelValues(metrics.MissExhausted).Inc()
+			span.SetAttributes(attribute.String("miss.reason", metrics.MissExhausted))
+			return ErrClickLimitReached
+		}
+		if errors.Is(err, storage.ErrRedirectLimitReached) {
+			metrics.RedirectMisses.WithLabelValues(metrics.MissQuota).Inc()
+			span.SetAttributes(attribute.String("miss.reason", metrics.MissQuota))
+			return fmt.Errorf("%w: limit is %d", ErrRedirectQuota, limits.MonthlyRedirects)
 		}
-	}()
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Resolve failed",
+			slog.Group("input", slog.String("code", code)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return err
+	}
+
+	if mapping.MaxClicks == 0 && limits.MonthlyRedirects == 0 {
+		// Increment click count asynchronously to avoid blocking the redirect
+		s.runner.Go("increment_click_count", func() {
+			if err := s.store.IncrementClickCount(clickCtx, domain, code); err != nil {
+				s.logger.WarnContext(clickCtx, "Failed to increment click count",
+					slog.String("code", code),
+					slog.String("error", err.Error()),
+				)
+			}
+		})
+	}
 
-	return *original_url, nil
+	if variant != nil {
+		variantName := variant.Name
+		s.runner.Go("increment_variant_click_count", func() {
+			if err := s.store.IncrementVariantClickCount(clickCtx, domain, code, variantName); err != nil {
+				s.logger.WarnContext(clickCtx, "Failed to increment variant click count",
+					slog.String("code", code),
+					slog.String("variant", variantName),
+					slog.String("error", err.Error()),
+				)
+			}
+		})
+	}
+
+	return nil
 }
 
-func (s *ShortenerService) ListMappings(userID string) ([]model.URLMapping, error) {
-	mappings, err := s.store.ListByUser(userID)
+// miss records why code did not resolve and returns the error for it. Links
+// that are not active yet report when they will be.
+func (s *ShortenerService) miss(ctx context.Context, span trace.Span, domain, code string) error {
+	reason, err := metrics.MissNotFound, error(ErrNotFound)
+
+	mapping, getErr := s.store.GetMapping(ctx, domain, code)
+	if getErr == nil && mapping != nil {
+		now := time.Now()
+		switch {
+		case mapping.Expired(now):
+			reason = metrics.MissExpired
+		case mapping.Pending(now):
+			reason = metrics.MissNotActive
+			err = &NotActiveError{ActivatesAt: *mapping.ActivatesAt}
+		}
+	}
+
+	s.logger.DebugContext(ctx, "Resolve missed", slog.String("code", code), slog.String("reason", reason))
+	metrics.RedirectMisses.WithLabelValues(reason).Inc()
+	span.SetAttributes(attribute.String("miss.reason", reason))
+	return err
+}
+
+// ListMappings returns the personal mappings of userID, or those of the
+// organization orgID if it is set and userID is one of its members, that
+// pass filter
+func (s *ShortenerService) ListMappings(ctx context.Context, userID, orgID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ListMappings", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+	))
+	defer span.End()
+
+	filter.Tag = normalizeTag(filter.Tag)
+	filter.Folder = strings.TrimSpace(filter.Folder)
+
+	var mappings []model.URLMapping
+	var err error
+	if orgID == "" {
+		mappings, err = s.store.ListByUser(ctx, userID, filter)
+	} else {
+		if _, err := s.memberRole(ctx, userID, orgID); err != nil {
+			return nil, err
+		}
+		mappings, err = s.store.ListByOrg(ctx, orgID, filter)
+	}
 	if err != nil {
-		s.logger.Error("ListMappings failed",
-			slog.String("userID", userID),
+		s.logger.ErrorContext(ctx, "ListMappings failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID)),
 			slog.String("error", err.Error()),
 		)
+		failSpan(span, err)
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("mappings.count", len(mappings)))
 	return mappings, nil
 }
 
+// GetMapping returns the mapping for code on domain if userID may see it:
+// personal links to their owner, organization links to any member
+func (s *ShortenerService) GetMapping(ctx context.Context, userID, domain, code string) (*model.URLMapping, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.GetMapping", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("domain", domain),
+		attribute.String("code", code),
+	))
+	defer span.End()
+
+	return s.authorizedMapping(ctx, span, userID, domain, code, false)
+}
+
+// authorizedMapping returns the mapping for code on domain if userID may see
+// it, or change it when edit is set
+func (s *ShortenerService) authorizedMapping(ctx context.Context, span trace.Span, userID, domain, code string, edit bool) (*model.URLMapping, error) {
+	mapping, err := s.store.GetMapping(ctx, normalizeHost(domain), code)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.logger.ErrorContext(ctx, "GetMapping failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("code", code)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	if mapping == nil {
+		return nil, ErrNotFound
+	}
+
+	if err := s.authorize(ctx, userID, mapping, edit); err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+// ShortURL returns the short URL of code on the domain served on host. Links
+// that are expired or not active yet have one too, so it can be printed
+// ahead of a campaign.
+func (s *ShortenerService) ShortURL(ctx context.Context, host, code string) (string, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ShortURL", trace.WithAttributes(
+		attribute.String("code", code),
+	))
+	defer span.End()
+
+	domain, err := s.domainOf(ctx, host)
+	var mapping *model.URLMapping
+	if err == nil {
+		mapping, err = s.store.GetMapping(ctx, domain, code)
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.logger.ErrorContext(ctx, "ShortURL failed",
+			slog.Group("input", slog.String("host", host), slog.String("code", code)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return "", err
+	}
+
+	if mapping == nil {
+		return "", ErrNotFound
+	}
+
+	return LinkURL(s.baseURL, mapping.Domain, mapping.Code), nil
+}
+
+// UpdateMapping changes the settings of the mapping for code if userID may
+// edit it
+func (s *ShortenerService) UpdateMapping(ctx context.Context, userID, domain, code string, update MappingUpdate) (*model.URLMapping, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.UpdateMapping", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("code", code),
+	))
+	defer span.End()
+
+	mapping, err := s.authorizedMapping(ctx, span, userID, domain, code, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.ActivatesAt != nil {
+		mapping.ActivatesAt = nil
+		if !update.ActivatesAt.IsZero() {
+			activatesAt := *update.ActivatesAt
+			mapping.ActivatesAt = &activatesAt
+		}
+	}
+
+	if update.Rules != nil {
+		if err := validateRules(*update.Rules); err != nil {
+			return nil, err
+		}
+		mapping.Rules = *update.Rules
+		if len(mapping.Rules) == 0 {
+			mapping.Rules = nil
+		}
+	}
+
+	if update.Variants != nil {
+		if err := validateVariants(*update.Variants); err != nil {
+			return nil, err
+		}
+		if len(*update.Variants) == 0 && mapping.Original == "" {
+			return nil, fmt.Errorf("%w: the link has no url to fall back to", ErrInvalidVariants)
+		}
+		mapping.Variants = *update.Variants
+		if len(mapping.Variants) == 0 {
+			mapping.Variants = nil
+		}
+	}
+	if update.Title != nil {
+		if err := validateTitle(*update.Title); err != nil {
+			return nil, err
+		}
+		mapping.Title = *update.Title
+	}
+	if update.Interstitial != nil {
+		mapping.Interstitial = *update.Interstitial
+	}
+	if update.Tags != nil {
+		if mapping.Tags, err = normalizeTags(*update.Tags); err != nil {
+			return nil, err
+		}
+	}
+	if update.Folder != nil {
+		if mapping.Folder, err = normalizeFolder(*update.Folder); err != nil {
+			return nil, err
+		}
+	}
+	if update.StickyVariants != nil {
+		mapping.StickyVariants = *update.StickyVariants
+	}
+
+	if err := validateActivation(*mapping); err != nil {
+		return nil, err
+	}
+
+	err = s.store.Update(ctx, *mapping)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "UpdateMapping failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("code", code)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+// DeleteMapping removes the mapping for code on domain if userID may edit it
+func (s *ShortenerService) DeleteMapping(ctx context.Context, userID, domain, code string) error {
+	ctx, span := tracer.Start(ctx, "ShortenerService.DeleteMapping", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("code", code),
+	))
+	defer span.End()
+
+	mapping, err := s.authorizedMapping(ctx, span, userID, domain, code, true)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Delete(ctx, mapping.Domain, code); err != nil {
+		s.logger.ErrorContext(ctx, "DeleteMapping failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("code", code)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// failSpan marks span as failed with err
+func failSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// maxCodeAttempts is how many random codes Shorten tries before giving up
+const maxCodeAttempts = 3
+
 func generateCode(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	seededRand := rand.New(rand.NewSource(time.Now().UnixNano()))
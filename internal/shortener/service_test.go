@@ -1,13 +1,24 @@
 package shortener
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/wiredmatt/go_short/internal/metrics"
 	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/storage"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // MockStore is a mock implementation of the storage.Store interface
@@ -15,31 +26,204 @@ type MockStore struct {
 	mock.Mock
 }
 
-func (m *MockStore) Save(mapping model.URLMapping) error {
+func (m *MockStore) Save(_ context.Context, mapping model.URLMapping) error {
 	args := m.Called(mapping)
 	return args.Error(0)
 }
 
-func (m *MockStore) Get(code string) (*string, error) {
-	args := m.Called(code)
+func (m *MockStore) SaveWithinLimit(_ context.Context, mapping model.URLMapping, maxActiveLinks int) error {
+	args := m.Called(mapping, maxActiveLinks)
+	return args.Error(0)
+}
+
+func (m *MockStore) Get(_ context.Context, domain, code string) (*model.URLMapping, error) {
+	args := m.Called(domain, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *MockStore) GetMapping(_ context.Context, domain, code string) (*model.URLMapping, error) {
+	args := m.Called(domain, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *MockStore) Update(_ context.Context, mapping model.URLMapping) error {
+	args := m.Called(mapping)
+	return args.Error(0)
+}
+
+func (m *MockStore) SaveMetadata(_ context.Context, domain, code, original string, metadata model.Metadata) error {
+	args := m.Called(domain, code, original, metadata)
+	return args.Error(0)
+}
+
+func (m *MockStore) IncrementClickCount(_ context.Context, domain, code string) error {
+	args := m.Called(domain, code)
+	return args.Error(0)
+}
+
+func (m *MockStore) ConsumeClick(_ context.Context, domain, code string, maxRedirects int) error {
+	args := m.Called(domain, code, maxRedirects)
+	return args.Error(0)
+}
+
+func (m *MockStore) IncrementVariantClickCount(_ context.Context, domain, code, variant string) error {
+	args := m.Called(domain, code, variant)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListByUser(_ context.Context, userID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	args := m.Called(userID, filter)
+	return args.Get(0).([]model.URLMapping), args.Error(1)
+}
+
+func (m *MockStore) Delete(_ context.Context, domain, code string) error {
+	args := m.Called(domain, code)
+	return args.Error(0)
+}
+
+func (m *MockStore) CountActive(_ context.Context) (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) SaveUTMTemplate(_ context.Context, template model.UTMTemplate) error {
+	args := m.Called(template)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetUTMTemplate(_ context.Context, userID, name string) (*model.UTMTemplate, error) {
+	args := m.Called(userID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UTMTemplate), args.Error(1)
+}
+
+func (m *MockStore) ListUTMTemplates(_ context.Context, userID string) ([]model.UTMTemplate, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.UTMTemplate), args.Error(1)
+}
+
+func (m *MockStore) DeleteUTMTemplate(_ context.Context, userID, name string) error {
+	args := m.Called(userID, name)
+	return args.Error(0)
+}
+
+func (m *MockStore) SaveDomain(_ context.Context, domain model.Domain) error {
+	args := m.Called(domain)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetDomain(_ context.Context, name string) (*model.Domain, error) {
+	args := m.Called(name)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*string), args.Error(1)
+	return args.Get(0).(*model.Domain), args.Error(1)
+}
+
+func (m *MockStore) ListDomains(_ context.Context, userID string) ([]model.Domain, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.Domain), args.Error(1)
+}
+
+func (m *MockStore) DeleteDomain(_ context.Context, name string) error {
+	args := m.Called(name)
+	return args.Error(0)
 }
 
-func (m *MockStore) IncrementClickCount(code string) error {
-	args := m.Called(code)
+func (m *MockStore) ListByOrg(_ context.Context, orgID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	args := m.Called(orgID, filter)
+	return args.Get(0).([]model.URLMapping), args.Error(1)
+}
+
+func (m *MockStore) SaveOrganization(_ context.Context, org model.Organization, owner model.Membership) error {
+	args := m.Called(org, owner)
 	return args.Error(0)
 }
 
-func (m *MockStore) ListByUser(userID string) ([]model.URLMapping, error) {
+func (m *MockStore) GetOrganization(_ context.Context, id string) (*model.Organization, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Organization), args.Error(1)
+}
+
+func (m *MockStore) ListMemberships(_ context.Context, userID string) ([]model.Membership, error) {
 	args := m.Called(userID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *MockStore) SaveMember(_ context.Context, member model.Membership) error {
+	args := m.Called(member)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetMember(_ context.Context, orgID, userID string) (*model.Membership, error) {
+	args := m.Called(orgID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Membership), args.Error(1)
+}
+
+func (m *MockStore) ListMembers(_ context.Context, orgID string) ([]model.Membership, error) {
+	args := m.Called(orgID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *MockStore) DeleteMember(_ context.Context, orgID, userID string) error {
+	args := m.Called(orgID, userID)
+	return args.Error(0)
+}
+
+func (m *MockStore) CountActiveLinks(_ context.Context, account model.Account) (int, error) {
+	args := m.Called(account)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) MonthlyRedirects(_ context.Context, account model.Account, month time.Time) (int, error) {
+	args := m.Called(account, month)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) SaveQuota(_ context.Context, quota model.Quota) error {
+	args := m.Called(quota)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetQuota(_ context.Context, account model.Account) (*model.Quota, error) {
+	args := m.Called(account)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Quota), args.Error(1)
+}
+
+func (m *MockStore) ListTags(_ context.Context, account model.Account) ([]model.TagCount, error) {
+	args := m.Called(account)
+	return args.Get(0).([]model.TagCount), args.Error(1)
+}
+
+func (m *MockStore) RenameTag(_ context.Context, account model.Account, from, to string) (int, error) {
+	args := m.Called(account, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) SearchMappings(_ context.Context, account model.Account, query string, limit int) ([]model.URLMapping, error) {
+	args := m.Called(account, query, limit)
 	return args.Get(0).([]model.URLMapping), args.Error(1)
 }
 
-func (m *MockStore) Delete(code string) error {
-	args := m.Called(code)
+func (m *MockStore) Ping(_ context.Context) error {
+	args := m.Called()
 	return args.Error(0)
 }
 
@@ -56,37 +240,210 @@ func NewAsyncMockStore() *AsyncMockStore {
 	}
 }
 
-func (m *AsyncMockStore) Save(mapping model.URLMapping) error {
+func (m *AsyncMockStore) Save(_ context.Context, mapping model.URLMapping) error {
 	args := m.Called(mapping)
 	return args.Error(0)
 }
 
-func (m *AsyncMockStore) Get(code string) (*string, error) {
-	args := m.Called(code)
+func (m *AsyncMockStore) SaveWithinLimit(_ context.Context, mapping model.URLMapping, maxActiveLinks int) error {
+	args := m.Called(mapping, maxActiveLinks)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) Get(_ context.Context, domain, code string) (*model.URLMapping, error) {
+	args := m.Called(domain, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *AsyncMockStore) GetMapping(_ context.Context, domain, code string) (*model.URLMapping, error) {
+	args := m.Called(domain, code)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*string), args.Error(1)
+	return args.Get(0).(*model.URLMapping), args.Error(1)
+}
+
+func (m *AsyncMockStore) Update(_ context.Context, mapping model.URLMapping) error {
+	args := m.Called(mapping)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) SaveMetadata(_ context.Context, domain, code, original string, metadata model.Metadata) error {
+	args := m.Called(domain, code, original, metadata)
+	return args.Error(0)
 }
 
-func (m *AsyncMockStore) IncrementClickCount(code string) error {
+func (m *AsyncMockStore) IncrementClickCount(_ context.Context, domain, code string) error {
 	// Signal that this method was called
 	select {
 	case m.clickCountCalls <- code:
 	default:
 	}
 
-	args := m.Called(code)
+	args := m.Called(domain, code)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) ConsumeClick(_ context.Context, domain, code string, maxRedirects int) error {
+	args := m.Called(domain, code, maxRedirects)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) IncrementVariantClickCount(_ context.Context, domain, code, variant string) error {
+	args := m.Called(domain, code, variant)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) ListByUser(_ context.Context, userID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	args := m.Called(userID, filter)
+	return args.Get(0).([]model.URLMapping), args.Error(1)
+}
+
+func (m *AsyncMockStore) Delete(_ context.Context, domain, code string) error {
+	args := m.Called(domain, code)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) CountActive(_ context.Context) (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (m *AsyncMockStore) SaveUTMTemplate(_ context.Context, template model.UTMTemplate) error {
+	args := m.Called(template)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) GetUTMTemplate(_ context.Context, userID, name string) (*model.UTMTemplate, error) {
+	args := m.Called(userID, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UTMTemplate), args.Error(1)
+}
+
+func (m *AsyncMockStore) ListUTMTemplates(_ context.Context, userID string) ([]model.UTMTemplate, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.UTMTemplate), args.Error(1)
+}
+
+func (m *AsyncMockStore) DeleteUTMTemplate(_ context.Context, userID, name string) error {
+	args := m.Called(userID, name)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) SaveDomain(_ context.Context, domain model.Domain) error {
+	args := m.Called(domain)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) GetDomain(_ context.Context, name string) (*model.Domain, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Domain), args.Error(1)
+}
+
+func (m *AsyncMockStore) ListDomains(_ context.Context, userID string) ([]model.Domain, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.Domain), args.Error(1)
+}
+
+func (m *AsyncMockStore) DeleteDomain(_ context.Context, name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) ListByOrg(_ context.Context, orgID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	args := m.Called(orgID, filter)
+	return args.Get(0).([]model.URLMapping), args.Error(1)
+}
+
+func (m *AsyncMockStore) SaveOrganization(_ context.Context, org model.Organization, owner model.Membership) error {
+	args := m.Called(org, owner)
 	return args.Error(0)
 }
 
-func (m *AsyncMockStore) ListByUser(userID string) ([]model.URLMapping, error) {
+func (m *AsyncMockStore) GetOrganization(_ context.Context, id string) (*model.Organization, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Organization), args.Error(1)
+}
+
+func (m *AsyncMockStore) ListMemberships(_ context.Context, userID string) ([]model.Membership, error) {
 	args := m.Called(userID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *AsyncMockStore) SaveMember(_ context.Context, member model.Membership) error {
+	args := m.Called(member)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) GetMember(_ context.Context, orgID, userID string) (*model.Membership, error) {
+	args := m.Called(orgID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Membership), args.Error(1)
+}
+
+func (m *AsyncMockStore) ListMembers(_ context.Context, orgID string) ([]model.Membership, error) {
+	args := m.Called(orgID)
+	return args.Get(0).([]model.Membership), args.Error(1)
+}
+
+func (m *AsyncMockStore) DeleteMember(_ context.Context, orgID, userID string) error {
+	args := m.Called(orgID, userID)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) CountActiveLinks(_ context.Context, account model.Account) (int, error) {
+	args := m.Called(account)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *AsyncMockStore) MonthlyRedirects(_ context.Context, account model.Account, month time.Time) (int, error) {
+	args := m.Called(account, month)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *AsyncMockStore) SaveQuota(_ context.Context, quota model.Quota) error {
+	args := m.Called(quota)
+	return args.Error(0)
+}
+
+func (m *AsyncMockStore) GetQuota(_ context.Context, account model.Account) (*model.Quota, error) {
+	args := m.Called(account)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Quota), args.Error(1)
+}
+
+func (m *AsyncMockStore) ListTags(_ context.Context, account model.Account) ([]model.TagCount, error) {
+	args := m.Called(account)
+	return args.Get(0).([]model.TagCount), args.Error(1)
+}
+
+func (m *AsyncMockStore) RenameTag(_ context.Context, account model.Account, from, to string) (int, error) {
+	args := m.Called(account, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *AsyncMockStore) SearchMappings(_ context.Context, account model.Account, query string, limit int) ([]model.URLMapping, error) {
+	args := m.Called(account, query, limit)
 	return args.Get(0).([]model.URLMapping), args.Error(1)
 }
 
-func (m *AsyncMockStore) Delete(code string) error {
-	args := m.Called(code)
+func (m *AsyncMockStore) Ping(_ context.Context) error {
+	args := m.Called()
 	return args.Error(0)
 }
 
@@ -126,7 +483,7 @@ func TestShorten_Success(t *testing.T) {
 
 	mockStore.On("Save", mock.AnythingOfType("model.URLMapping")).Return(nil)
 
-	code, err := service.Shorten(userID, originalURL)
+	code, err := service.Shorten(context.Background(), ShortenRequest{UserID: userID, URL: originalURL})
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, code)
@@ -147,7 +504,7 @@ func TestShorten_StoreError(t *testing.T) {
 
 	mockStore.On("Save", mock.AnythingOfType("model.URLMapping")).Return(expectedError)
 
-	code, err := service.Shorten(userID, originalURL)
+	code, err := service.Shorten(context.Background(), ShortenRequest{UserID: userID, URL: originalURL})
 
 	assert.Error(t, err)
 	assert.Equal(t, expectedError, err)
@@ -165,14 +522,14 @@ func TestResolve_Success(t *testing.T) {
 	code := "abc123"
 	expectedURL := "https://example.com/very/long/url"
 
-	mockStore.On("Get", code).Return(&expectedURL, nil)
-	mockStore.On("IncrementClickCount", code).Return(nil)
+	mockStore.On("Get", "", code).Return(&model.URLMapping{Code: code, Original: expectedURL}, nil)
+	mockStore.On("IncrementClickCount", "", code).Return(nil)
 
 	// Test that Resolve returns immediately
-	originalURL, err := service.Resolve(code)
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: code})
 
 	assert.NoError(t, err)
-	assert.Equal(t, expectedURL, originalURL)
+	assert.Equal(t, expectedURL, resolution.URL)
 
 	// Wait for the async click counting to happen
 	select {
@@ -194,13 +551,13 @@ func TestResolve_NotFound(t *testing.T) {
 	code := "nonexistent"
 	expectedError := errors.New("code not found")
 
-	mockStore.On("Get", code).Return(nil, expectedError)
+	mockStore.On("Get", "", code).Return(nil, expectedError)
 
-	originalURL, err := service.Resolve(code)
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: code})
 
 	assert.Error(t, err)
 	assert.Equal(t, "code not found", err.Error())
-	assert.Empty(t, originalURL)
+	assert.Nil(t, resolution)
 
 	mockStore.AssertExpectations(t)
 }
@@ -214,13 +571,14 @@ func TestResolve_NilURL(t *testing.T) {
 	code := "abc123"
 
 	// Expect the store to return nil URL
-	mockStore.On("Get", code).Return(nil, nil)
+	mockStore.On("Get", "", code).Return(nil, nil)
+	mockStore.On("GetMapping", "", code).Return(nil, nil)
 
-	originalURL, err := service.Resolve(code)
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: code})
 
 	assert.Error(t, err)
 	assert.Equal(t, "code not found", err.Error())
-	assert.Empty(t, originalURL)
+	assert.Nil(t, resolution)
 
 	mockStore.AssertExpectations(t)
 }
@@ -235,15 +593,15 @@ func TestResolve_ClickCountFailure(t *testing.T) {
 	expectedURL := "https://example.com/very/long/url"
 	expectedError := errors.New("click count error")
 
-	mockStore.On("Get", code).Return(&expectedURL, nil)
-	mockStore.On("IncrementClickCount", code).Return(expectedError)
+	mockStore.On("Get", "", code).Return(&model.URLMapping{Code: code, Original: expectedURL}, nil)
+	mockStore.On("IncrementClickCount", "", code).Return(expectedError)
 
 	// Test that Resolve returns immediately even when click counting will fail
-	originalURL, err := service.Resolve(code)
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: code})
 
 	// Should still succeed even if click counting fails
 	assert.NoError(t, err)
-	assert.Equal(t, expectedURL, originalURL)
+	assert.Equal(t, expectedURL, resolution.URL)
 
 	// Wait for the async click counting to happen (even though it will fail)
 	select {
@@ -286,7 +644,7 @@ func TestShorten_GeneratesUniqueCodes(t *testing.T) {
 
 	codes := make(map[string]bool)
 	for i := 0; i < 100; i++ {
-		code, err := service.Shorten(userID, originalURL)
+		code, err := service.Shorten(context.Background(), ShortenRequest{UserID: userID, URL: originalURL})
 		assert.NoError(t, err)
 		assert.NotEmpty(t, code)
 
@@ -322,9 +680,9 @@ func TestListMappings_Success(t *testing.T) {
 		},
 	}
 
-	mockStore.On("ListByUser", userID).Return(expectedMappings, nil)
+	mockStore.On("ListByUser", userID, model.MappingFilter{}).Return(expectedMappings, nil)
 
-	mappings, err := service.ListMappings(userID)
+	mappings, err := service.ListMappings(context.Background(), userID, "", model.MappingFilter{})
 
 	assert.NoError(t, err)
 	assert.Equal(t, expectedMappings, mappings)
@@ -342,9 +700,9 @@ func TestListMappings_StoreError(t *testing.T) {
 	userID := "user123"
 	expectedError := errors.New("storage error")
 
-	mockStore.On("ListByUser", userID).Return([]model.URLMapping{}, expectedError)
+	mockStore.On("ListByUser", userID, model.MappingFilter{}).Return([]model.URLMapping{}, expectedError)
 
-	mappings, err := service.ListMappings(userID)
+	mappings, err := service.ListMappings(context.Background(), userID, "", model.MappingFilter{})
 
 	assert.Error(t, err)
 	assert.Equal(t, expectedError, err)
@@ -362,14 +720,14 @@ func TestResolve_AsyncClickCounting(t *testing.T) {
 	code := "abc123"
 	expectedURL := "https://example.com/very/long/url"
 
-	mockStore.On("Get", code).Return(&expectedURL, nil)
-	mockStore.On("IncrementClickCount", code).Return(nil)
+	mockStore.On("Get", "", code).Return(&model.URLMapping{Code: code, Original: expectedURL}, nil)
+	mockStore.On("IncrementClickCount", "", code).Return(nil)
 
 	// Test that Resolve returns immediately
-	originalURL, err := service.Resolve(code)
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: code})
 
 	assert.NoError(t, err)
-	assert.Equal(t, expectedURL, originalURL)
+	assert.Equal(t, expectedURL, resolution.URL)
 
 	// Wait for the async click counting to happen
 	select {
@@ -381,3 +739,1587 @@ func TestResolve_AsyncClickCounting(t *testing.T) {
 
 	mockStore.AssertExpectations(t)
 }
+
+func TestShorten_RedirectType(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("Save", mock.MatchedBy(func(m model.URLMapping) bool {
+		return m.RedirectType == 308
+	})).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", RedirectType: 308})
+	assert.NoError(t, err)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", RedirectType: 303})
+	assert.ErrorIs(t, err, ErrInvalidRedirectType)
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestResolve_RedirectType(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		mapping  model.URLMapping
+		expected int
+	}{
+		{"server default", nil, model.URLMapping{Code: "abc123", Original: "https://example.com"}, 302},
+		{"configured default", []Option{WithDefaultRedirectType(307)}, model.URLMapping{Code: "abc123", Original: "https://example.com"}, 307},
+		{"invalid default is ignored", []Option{WithDefaultRedirectType(200)}, model.URLMapping{Code: "abc123", Original: "https://example.com"}, 302},
+		{"per link", []Option{WithDefaultRedirectType(307)}, model.URLMapping{Code: "abc123", Original: "https://example.com", RedirectType: 301, ExpiresAt: &expiresAt}, 301},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			opts := append([]Option{WithRunner(&recordingRunner{})}, tt.opts...)
+			service := NewService(mockStore, "https://short.url", 6, opts...)
+
+			mockStore.On("Get", "", "abc123").Return(&tt.mapping, nil)
+
+			resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: "abc123"})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, resolution.StatusCode)
+			assert.Equal(t, tt.mapping.ExpiresAt, resolution.ExpiresAt)
+		})
+	}
+}
+
+func TestShorten_Passthrough(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("Save", mock.MatchedBy(func(m model.URLMapping) bool {
+		return m.QueryPassthrough == model.QueryPassthroughMerge && m.PathPassthrough
+	})).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{
+		UserID:           "user123",
+		URL:              "https://example.com",
+		QueryPassthrough: model.QueryPassthroughMerge,
+		PathPassthrough:  true,
+	})
+	assert.NoError(t, err)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", QueryPassthrough: "replace"})
+	assert.ErrorIs(t, err, ErrInvalidQueryPassthrough)
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestResolve_Passthrough(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapping  model.URLMapping
+		req      ResolveRequest
+		expected string
+	}{
+		{
+			"query dropped by default",
+			model.URLMapping{Original: "https://example.com/page?ref=a"},
+			ResolveRequest{RawQuery: "utm_source=x"},
+			"https://example.com/page?ref=a",
+		},
+		{
+			"merge keeps destination values",
+			model.URLMapping{Original: "https://example.com/page?ref=a", QueryPassthrough: model.QueryPassthroughMerge},
+			ResolveRequest{RawQuery: "ref=b&utm_source=x"},
+			"https://example.com/page?ref=a&utm_source=x",
+		},
+		{
+			"override replaces destination values",
+			model.URLMapping{Original: "https://example.com/page?ref=a", QueryPassthrough: model.QueryPassthroughOverride},
+			ResolveRequest{RawQuery: "ref=b&utm_source=x"},
+			"https://example.com/page?ref=b&utm_source=x",
+		},
+		{
+			"append keeps both values",
+			model.URLMapping{Original: "https://example.com/page?ref=a", QueryPassthrough: model.QueryPassthroughAppend},
+			ResolveRequest{RawQuery: "ref=b"},
+			"https://example.com/page?ref=a&ref=b",
+		},
+		{
+			"destination untouched without a query",
+			model.URLMapping{Original: "https://example.com/page?b=2&a=1", QueryPassthrough: model.QueryPassthroughMerge},
+			ResolveRequest{},
+			"https://example.com/page?b=2&a=1",
+		},
+		{
+			"prefix appends path",
+			model.URLMapping{Original: "https://docs.example.com/v1/", PathPassthrough: true},
+			ResolveRequest{PathSuffix: "guides/setup page"},
+			"https://docs.example.com/v1/guides/setup%20page",
+		},
+		{
+			"prefix cannot climb out of the destination path",
+			model.URLMapping{Original: "https://docs.example.com/v1", PathPassthrough: true},
+			ResolveRequest{PathSuffix: "../../admin"},
+			"https://docs.example.com/v1/admin",
+		},
+		{
+			"prefix with query",
+			model.URLMapping{Original: "https://docs.example.com/v1?lang=en", PathPassthrough: true, QueryPassthrough: model.QueryPassthroughMerge},
+			ResolveRequest{PathSuffix: "docs", RawQuery: "q=go"},
+			"https://docs.example.com/v1/docs?lang=en&q=go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			service := NewService(mockStore, "https://short.url", 6, WithRunner(&recordingRunner{}))
+
+			tt.mapping.Code = "abc123"
+			tt.req.Code = "abc123"
+			mockStore.On("Get", "", "abc123").Return(&tt.mapping, nil)
+
+			resolution, err := service.Resolve(context.Background(), tt.req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, resolution.URL)
+		})
+	}
+}
+
+func TestResolve_PathSuffixWithoutPrefix(t *testing.T) {
+	mockStore := new(MockStore)
+	runner := &recordingRunner{}
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(runner))
+
+	mockStore.On("Get", "", "abc123").Return(&model.URLMapping{Code: "abc123", Original: "https://example.com"}, nil)
+
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: "abc123", PathSuffix: "docs"})
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, resolution)
+	// Misses are not counted as clicks
+	assert.Empty(t, runner.tasks)
+}
+
+const (
+	iPhoneUserAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+	androidUserAgent = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+	macUserAgent     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		platform  string
+		device    string
+	}{
+		{iPhoneUserAgent, model.PlatformIOS, model.DeviceMobile},
+		{"Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", model.PlatformIOS, model.DeviceTablet},
+		{androidUserAgent, model.PlatformAndroid, model.DeviceMobile},
+		{"Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", model.PlatformAndroid, model.DeviceTablet},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", model.PlatformWindows, model.DeviceDesktop},
+		{macUserAgent, model.PlatformMacOS, model.DeviceDesktop},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", model.PlatformLinux, model.DeviceDesktop},
+		{"curl/8.7.1", "", ""},
+	}
+
+	for _, tt := range tests {
+		platform, device := detectPlatform(tt.userAgent)
+		assert.Equal(t, tt.platform, platform, tt.userAgent)
+		assert.Equal(t, tt.device, device, tt.userAgent)
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	assert.Equal(t, "fr-CH", preferredLanguage("fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5"))
+	assert.Equal(t, "de", preferredLanguage("en;q=0.5, de"))
+	assert.Equal(t, "en", preferredLanguage("*, en;q=0.1"))
+	assert.Equal(t, "", preferredLanguage(""))
+	assert.Equal(t, "", preferredLanguage("en;q=0"))
+}
+
+func TestResolve_RoutingRules(t *testing.T) {
+	mapping := model.URLMapping{
+		Code:             "app",
+		Original:         "https://example.com/app",
+		QueryPassthrough: model.QueryPassthroughMerge,
+		Rules: []model.RoutingRule{
+			{Platform: model.PlatformIOS, URL: "https://apps.apple.com/app/id123"},
+			{Platform: model.PlatformAndroid, URL: "https://play.google.com/store/apps/details?id=com.example"},
+			{Language: "pt", URL: "https://example.com/pt/app"},
+			{Headers: map[string]string{"X-Beta": "yes"}, URL: "https://beta.example.com/app"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		rawQuery string
+		expected string
+	}{
+		{"ios", http.Header{"User-Agent": {iPhoneUserAgent}}, "", "https://apps.apple.com/app/id123"},
+		{"android with query", http.Header{"User-Agent": {androidUserAgent}}, "ref=ad", "https://play.google.com/store/apps/details?id=com.example&ref=ad"},
+		{"first match wins", http.Header{"User-Agent": {iPhoneUserAgent}, "Accept-Language": {"pt-BR"}}, "", "https://apps.apple.com/app/id123"},
+		{"language by primary subtag", http.Header{"User-Agent": {macUserAgent}, "Accept-Language": {"pt-BR,en;q=0.8"}}, "", "https://example.com/pt/app"},
+		{"secondary language ignored", http.Header{"Accept-Language": {"en,pt;q=0.8"}}, "", "https://example.com/app"},
+		{"header ignoring case", http.Header{"X-Beta": {"YES"}}, "", "https://beta.example.com/app"},
+		{"fallback", http.Header{"User-Agent": {macUserAgent}}, "", "https://example.com/app"},
+		{"no headers", nil, "", "https://example.com/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			service := NewService(mockStore, "https://short.url", 6, WithRunner(&recordingRunner{}))
+			mockStore.On("Get", "", "app").Return(&mapping, nil)
+
+			resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: "app", RawQuery: tt.rawQuery, Header: tt.header})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, resolution.URL)
+			assert.Equal(t, "User-Agent, Accept-Language, X-Beta", resolution.Vary)
+		})
+	}
+}
+
+func TestShorten_RoutingRules(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	rules := []model.RoutingRule{{Platform: model.PlatformIOS, Device: model.DeviceTablet, URL: "https://apps.apple.com/app/id123"}}
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Rules: rules})
+	assert.NoError(t, err)
+	assert.Equal(t, rules, saved.Rules)
+
+	invalid := []model.RoutingRule{
+		{URL: "https://example.com/everyone"},
+		{Platform: "blackberry", URL: "https://example.com"},
+		{Device: "watch", URL: "https://example.com"},
+		{Language: "english!", URL: "https://example.com"},
+		{Headers: map[string]string{"Bad Header": ""}, URL: "https://example.com"},
+		{Platform: model.PlatformIOS, URL: "itms-apps://apps.apple.com/app/id123"},
+	}
+	for _, rule := range invalid {
+		_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Rules: []model.RoutingRule{rule}})
+		assert.ErrorIs(t, err, ErrInvalidRoutingRule, "%+v", rule)
+	}
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestResolve_Variants(t *testing.T) {
+	mapping := model.URLMapping{
+		Code:     "split",
+		Original: "https://example.com",
+		Variants: []model.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 70},
+			{Name: "paused", URL: "https://example.com/paused", Weight: 0},
+			{Name: "b", URL: "https://example.com/b", Weight: 30},
+		},
+		Rules: []model.RoutingRule{{Platform: model.PlatformIOS, URL: "https://apps.apple.com/app/id123"}},
+	}
+
+	tests := []struct {
+		name     string
+		sticky   bool
+		req      ResolveRequest
+		roll     int
+		expected string
+		variant  string
+	}{
+		{"low roll", false, ResolveRequest{}, 0, "https://example.com/a", "a"},
+		{"high roll", false, ResolveRequest{}, 70, "https://example.com/b", "b"},
+		{"cookie ignored unless sticky", false, ResolveRequest{Variant: "b"}, 0, "https://example.com/a", "a"},
+		{"sticky cookie", true, ResolveRequest{Variant: "b"}, 0, "https://example.com/b", "b"},
+		{"paused variant is not kept", true, ResolveRequest{Variant: "paused"}, 0, "https://example.com/a", "a"},
+		{"unknown variant is not kept", true, ResolveRequest{Variant: "c"}, 70, "https://example.com/b", "b"},
+		{"routing rules first", false, ResolveRequest{Header: http.Header{"User-Agent": {iPhoneUserAgent}}}, 0, "https://apps.apple.com/app/id123", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			runner := &recordingRunner{}
+			service := NewService(mockStore, "https://short.url", 6, WithRunner(runner))
+			service.roll = func(n int) int {
+				assert.Equal(t, 100, n)
+				return tt.roll
+			}
+
+			m := mapping
+			m.StickyVariants = tt.sticky
+			mockStore.On("Get", "", "split").Return(&m, nil)
+
+			tt.req.Code = "split"
+			resolution, err := service.Resolve(context.Background(), tt.req)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, resolution.URL)
+			assert.Equal(t, tt.variant, resolution.Variant)
+			assert.Equal(t, tt.sticky && tt.variant != "", resolution.StickyVariant)
+			assert.True(t, resolution.NoStore)
+
+			if tt.variant == "" {
+				assert.Equal(t, []string{"increment_click_count"}, runner.tasks)
+				return
+			}
+			assert.Equal(t, []string{"increment_click_count", "increment_variant_click_count"}, runner.tasks)
+			mockStore.On("IncrementVariantClickCount", "", "split", tt.variant).Return(nil)
+			runner.fns[1]()
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestShorten_Variants(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	variants := []model.Variant{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "b", URL: "https://example.com/b", Weight: 1}}
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Variants: variants, StickyVariants: true})
+	assert.NoError(t, err)
+	assert.Equal(t, variants, saved.Variants)
+	assert.True(t, saved.StickyVariants)
+
+	invalid := [][]model.Variant{
+		{{Name: "a", URL: "https://example.com/a", Weight: 1}},
+		{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "a", URL: "https://example.com/b", Weight: 1}},
+		{{Name: "a b", URL: "https://example.com/a", Weight: 1}, {Name: "b", URL: "https://example.com/b", Weight: 1}},
+		{{Name: "a", URL: "https://example.com/a", Weight: -1}, {Name: "b", URL: "https://example.com/b", Weight: 1}},
+		{{Name: "a", URL: "https://example.com/a", Weight: 0}, {Name: "b", URL: "https://example.com/b", Weight: 0}},
+		{{Name: "a", URL: "example.com/a", Weight: 1}, {Name: "b", URL: "https://example.com/b", Weight: 1}},
+	}
+	for _, variants := range invalid {
+		_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Variants: variants})
+		assert.ErrorIs(t, err, ErrInvalidVariants, "%+v", variants)
+	}
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestShorten_InvalidURL(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	for _, url := range []string{"", "example.com", "ftp://example.com", "https://", "javascript:alert(1)"} {
+		_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: url})
+		assert.ErrorIs(t, err, ErrInvalidURL, url)
+	}
+
+	mockStore.AssertNotCalled(t, "Save", mock.Anything)
+}
+
+func TestShorten_VariantsWithoutURL(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	variants := []model.Variant{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "b", URL: "https://example.com/b", Weight: 1}}
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", Variants: variants})
+	assert.NoError(t, err)
+	assert.Empty(t, saved.Original)
+	assert.Equal(t, variants, saved.Variants)
+
+	// A URL given next to variants must still be valid
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "example.com", Variants: variants})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestUpdateMapping_VariantsWithoutURL(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	variants := []model.Variant{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "b", URL: "https://example.com/b", Weight: 1}}
+	mockStore.On("GetMapping", "", "abc123").Return(&model.URLMapping{Code: "abc123", UserID: "user123", Variants: variants}, nil)
+
+	// Without variants the link would have nowhere to redirect to
+	_, err := service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{Variants: &[]model.Variant{}})
+	assert.ErrorIs(t, err, ErrInvalidVariants)
+
+	mockStore.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestShorten_UTM(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{
+		UserID: "user123",
+		URL:    "HTTPS://Example.com/landing?ref=a&utm_source=old#top",
+		UTM:    model.UTM{Source: "Newsletter", Medium: "email", Campaign: "Spring Sale"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/landing?ref=a&utm_campaign=spring_sale&utm_medium=email&utm_source=newsletter#top", saved.Original)
+	assert.Equal(t, model.UTM{Source: "newsletter", Medium: "email", Campaign: "spring_sale"}, saved.UTM)
+}
+
+func TestShorten_UTMErrors(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", UTM: model.UTM{Source: "newsletter"}})
+	assert.ErrorIs(t, err, ErrInvalidUTM)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{
+		UserID: "user123",
+		URL:    "not a url",
+		UTM:    model.UTM{Source: "newsletter", Medium: "email", Campaign: "spring"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+
+	mockStore.AssertNotCalled(t, "Save", mock.Anything)
+}
+
+func TestShorten_UTMTemplate(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	template := &model.UTMTemplate{UserID: "user123", Name: "email", UTM: model.UTM{Source: "newsletter", Medium: "email", Campaign: "weekly"}}
+	mockStore.On("GetUTMTemplate", "user123", "email").Return(template, nil)
+	mockStore.On("GetUTMTemplate", "user123", "missing").Return(nil, nil)
+	mockStore.On("Save", mock.MatchedBy(func(m model.URLMapping) bool {
+		// Parameters set on the request win over the template's
+		return m.UTM == model.UTM{Source: "newsletter", Medium: "email", Campaign: "launch", Content: "header"}
+	})).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{
+		UserID:      "user123",
+		URL:         "https://example.com",
+		UTMTemplate: "email",
+		UTM:         model.UTM{Campaign: "launch", Content: "header"},
+	})
+	assert.NoError(t, err)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", UTMTemplate: "missing"})
+	assert.ErrorIs(t, err, ErrUTMTemplateNotFound)
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestSaveUTMTemplate(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("SaveUTMTemplate", mock.MatchedBy(func(tmpl model.UTMTemplate) bool {
+		return tmpl.UserID == "user123" && tmpl.Name == "social" && tmpl.UTM == model.UTM{Source: "twitter", Medium: "social"}
+	})).Return(nil)
+
+	template, err := service.SaveUTMTemplate(context.Background(), "user123", "social", model.UTM{Source: " Twitter", Medium: "SOCIAL"})
+	assert.NoError(t, err)
+	assert.Equal(t, "twitter", template.UTM.Source)
+
+	_, err = service.SaveUTMTemplate(context.Background(), "user123", "bad name", model.UTM{Source: "twitter"})
+	assert.ErrorIs(t, err, ErrInvalidUTMTemplate)
+
+	_, err = service.SaveUTMTemplate(context.Background(), "user123", "empty", model.UTM{Source: "  "})
+	assert.ErrorIs(t, err, ErrInvalidUTMTemplate)
+
+	mockStore.AssertNumberOfCalls(t, "SaveUTMTemplate", 1)
+}
+
+func TestDeleteUTMTemplate_NotFound(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("DeleteUTMTemplate", "user123", "missing").Return(storage.ErrNotFound)
+
+	err := service.DeleteUTMTemplate(context.Background(), "user123", "missing")
+
+	assert.ErrorIs(t, err, ErrUTMTemplateNotFound)
+}
+
+func TestShorten_Password(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Password: "s3cret"})
+
+	assert.NoError(t, err)
+	assert.True(t, saved.Protected())
+	assert.NotEqual(t, "s3cret", saved.PasswordHash)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(saved.PasswordHash), []byte("s3cret")))
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Password: "abc"})
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestShorten_PasswordNotLogged(t *testing.T) {
+	var logs bytes.Buffer
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(errors.New("storage error"))
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Password: "s3cret"})
+
+	assert.Error(t, err)
+	assert.Contains(t, logs.String(), "Shorten failed")
+	assert.Contains(t, logs.String(), saved.Code)
+	assert.NotContains(t, logs.String(), saved.PasswordHash)
+}
+
+func TestResolve_Protected(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	mockStore := new(MockStore)
+	runner := &recordingRunner{}
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(runner), WithPasswordAttempts(2, time.Minute))
+
+	mockStore.On("Get", "", "secret").Return(&model.URLMapping{Code: "secret", Original: "https://example.com/doc", PasswordHash: string(hash)}, nil)
+
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "secret", ClientID: "10.0.0.1"})
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "secret", ClientID: "10.0.0.1", Password: "wrong"})
+	assert.ErrorIs(t, err, ErrIncorrectPassword)
+
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: "secret", ClientID: "10.0.0.1", Password: "s3cret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/doc", resolution.URL)
+	assert.True(t, resolution.NoStore)
+
+	// Only the unlocked request counts as a click
+	assert.Len(t, runner.tasks, 1)
+}
+
+func TestResolve_PasswordAttemptsLimited(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(&recordingRunner{}), WithPasswordAttempts(2, time.Minute))
+
+	now := time.Now()
+	service.attempts.now = func() time.Time { return now }
+
+	mockStore.On("Get", "", "secret").Return(&model.URLMapping{Code: "secret", Original: "https://example.com/doc", PasswordHash: string(hash)}, nil)
+
+	for range 2 {
+		_, err = service.Resolve(context.Background(), ResolveRequest{Code: "secret", ClientID: "10.0.0.1", Password: "wrong"})
+		assert.ErrorIs(t, err, ErrIncorrectPassword)
+	}
+
+	// The right password is refused once the attempts are used up
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "secret", ClientID: "10.0.0.1", Password: "s3cret"})
+	var attemptsErr *TooManyAttemptsError
+	assert.ErrorAs(t, err, &attemptsErr)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	assert.Equal(t, time.Minute, attemptsErr.RetryAfter)
+
+	// Other clients are not affected
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "secret", ClientID: "10.0.0.2", Password: "s3cret"})
+	assert.NoError(t, err)
+
+	// The window ends a minute after the first failure
+	now = now.Add(time.Minute)
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "secret", ClientID: "10.0.0.1", Password: "s3cret"})
+	assert.NoError(t, err)
+}
+
+func TestCheckPassword_AttemptsPerDomain(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	service := NewService(new(MockStore), "https://short.url", 6, WithPasswordAttempts(1, time.Minute))
+	primary := &model.URLMapping{Code: "secret", PasswordHash: string(hash)}
+	custom := &model.URLMapping{Domain: "go.acme.com", Code: "secret", PasswordHash: string(hash)}
+
+	assert.ErrorIs(t, service.checkPassword(primary, ResolveRequest{ClientID: "10.0.0.1", Password: "wrong"}), ErrIncorrectPassword)
+	assert.ErrorIs(t, service.checkPassword(primary, ResolveRequest{ClientID: "10.0.0.1", Password: "s3cret"}), ErrTooManyAttempts)
+
+	// The same code on another domain is another link
+	assert.NoError(t, service.checkPassword(custom, ResolveRequest{ClientID: "10.0.0.1", Password: "s3cret"}))
+}
+
+func TestCheckPassword_ConcurrentGuesses(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	service := NewService(new(MockStore), "https://short.url", 6, WithPasswordAttempts(3, time.Minute))
+	mapping := &model.URLMapping{Code: "secret", PasswordHash: string(hash)}
+
+	// Only guesses that reach bcrypt are told the password is wrong
+	var compared, refused atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := service.checkPassword(mapping, ResolveRequest{ClientID: "10.0.0.1", Password: "wrong"})
+			switch {
+			case errors.Is(err, ErrIncorrectPassword):
+				compared.Add(1)
+			case errors.Is(err, ErrTooManyAttempts):
+				refused.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), compared.Load())
+	assert.Equal(t, int32(17), refused.Load())
+}
+
+func TestAttemptLimiter_Disabled(t *testing.T) {
+	limiter := newAttemptLimiter(0, time.Minute)
+
+	for range 10 {
+		_, ok := limiter.allow("key")
+		assert.True(t, ok)
+	}
+}
+
+// recordingRunner queues tasks so the test decides when they run
+type recordingRunner struct {
+	tasks []string
+	fns   []func()
+}
+
+func (r *recordingRunner) Go(task string, fn func()) {
+	r.tasks = append(r.tasks, task)
+	r.fns = append(r.fns, fn)
+}
+
+func TestShorten_MaxClicks(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", MaxClicks: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, saved.MaxClicks)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", MaxClicks: -1})
+	assert.ErrorIs(t, err, ErrInvalidMaxClicks)
+
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestResolve_MaxClicks(t *testing.T) {
+	mockStore := new(MockStore)
+	runner := &recordingRunner{}
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(runner))
+
+	mockStore.On("Get", "", "limited").Return(&model.URLMapping{Code: "limited", Original: "https://example.com", MaxClicks: 2, Clicks: 1}, nil)
+	mockStore.On("ConsumeClick", "", "limited", 0).Return(nil).Once()
+
+	// Limited links consume their click before redirecting and are never cached
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: "limited"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", resolution.URL)
+	assert.True(t, resolution.NoStore)
+	assert.Empty(t, runner.tasks)
+
+	// A concurrent request took the last click between Get and ConsumeClick
+	mockStore.On("ConsumeClick", "", "limited", 0).Return(storage.ErrClickLimitReached).Once()
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "limited"})
+	assert.ErrorIs(t, err, ErrClickLimitReached)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestResolve_Exhausted(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(&recordingRunner{}))
+
+	mockStore.On("Get", "", "used").Return(&model.URLMapping{Code: "used", Original: "https://example.com", MaxClicks: 1, Clicks: 1}, nil)
+
+	_, err := service.Resolve(context.Background(), ResolveRequest{Code: "used"})
+
+	assert.ErrorIs(t, err, ErrClickLimitReached)
+	mockStore.AssertNotCalled(t, "ConsumeClick", "", "used", 0)
+}
+
+func TestResolve_UsesRunner(t *testing.T) {
+	mockStore := new(MockStore)
+	runner := &recordingRunner{}
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(runner))
+
+	code := "abc123"
+	expectedURL := "https://example.com"
+	mockStore.On("Get", "", code).Return(&model.URLMapping{Code: code, Original: expectedURL}, nil)
+
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: code})
+
+	assert.NoError(t, err)
+	assert.Equal(t, expectedURL, resolution.URL)
+	assert.Equal(t, []string{"increment_click_count"}, runner.tasks)
+
+	// The click is only counted once the runner runs the task
+	mockStore.AssertNotCalled(t, "IncrementClickCount", "", code)
+	mockStore.On("IncrementClickCount", "", code).Return(nil)
+	runner.fns[0]()
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetMapping_Success(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mapping := &model.URLMapping{
+		Code:      "abc123",
+		Original:  "https://example.com/url1",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+		Clicks:    3,
+	}
+
+	mockStore.On("GetMapping", "", "abc123").Return(mapping, nil)
+
+	result, err := service.GetMapping(context.Background(), "user123", "", "abc123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, mapping, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetMapping_NotFound(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("GetMapping", "", "missing").Return(nil, nil)
+
+	result, err := service.GetMapping(context.Background(), "user123", "", "missing")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetMapping_OtherUser(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mapping := &model.URLMapping{
+		Code:     "abc123",
+		Original: "https://example.com/url1",
+		UserID:   "owner",
+	}
+
+	mockStore.On("GetMapping", "", "abc123").Return(mapping, nil)
+
+	result, err := service.GetMapping(context.Background(), "intruder", "", "abc123")
+
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.Nil(t, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestShortURL(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	activatesAt := time.Now().Add(time.Hour)
+	mockStore.On("GetMapping", "", "soon").Return(&model.URLMapping{
+		Code:        "soon",
+		Original:    "https://example.com",
+		ActivatesAt: &activatesAt,
+	}, nil)
+	mockStore.On("GetMapping", "", "missing").Return(nil, nil)
+
+	shortURL, err := service.ShortURL(context.Background(), "", "soon")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://short.url/soon", shortURL)
+
+	_, err = service.ShortURL(context.Background(), "", "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDeleteMapping_Success(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mapping := &model.URLMapping{
+		Code:     "abc123",
+		Original: "https://example.com/url1",
+		UserID:   "user123",
+	}
+
+	mockStore.On("GetMapping", "", "abc123").Return(mapping, nil)
+	mockStore.On("Delete", "", "abc123").Return(nil)
+
+	err := service.DeleteMapping(context.Background(), "user123", "", "abc123")
+
+	assert.NoError(t, err)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDeleteMapping_OtherUser(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mapping := &model.URLMapping{
+		Code:     "abc123",
+		Original: "https://example.com/url1",
+		UserID:   "owner",
+	}
+
+	mockStore.On("GetMapping", "", "abc123").Return(mapping, nil)
+
+	err := service.DeleteMapping(context.Background(), "intruder", "", "abc123")
+
+	assert.ErrorIs(t, err, ErrForbidden)
+	mockStore.AssertNotCalled(t, "Delete", "", "abc123")
+}
+
+func TestImportMappings(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * time.Hour)
+
+	records := []ImportRecord{
+		{Row: 1, Code: "custom1", Original: "https://example.com/a", CreatedAt: &createdAt, ExpiresAt: &expiresAt, Clicks: 9},
+		{Row: 2, Original: "https://example.com/b"},
+		{Row: 3, Code: "taken1", Original: "https://example.com/c"},
+		{Row: 4, Code: "bad code!", Original: "https://example.com/d"},
+		{Row: 5, Code: "mappings", Original: "https://example.com/e"},
+		{Row: 6, Original: "ftp://example.com/f"},
+		{Row: 7, Original: "https://example.com/g", Clicks: -1},
+	}
+
+	mockStore.On("Save", mock.MatchedBy(func(m model.URLMapping) bool {
+		return m.Code == "taken1"
+	})).Return(storage.ErrCodeExists).Once()
+	mockStore.On("Save", mock.MatchedBy(func(m model.URLMapping) bool {
+		return m.Code == "custom1" && m.UserID == "user123" && m.CreatedAt.Equal(createdAt) &&
+			m.ExpiresAt != nil && m.ExpiresAt.Equal(expiresAt) && m.Clicks == 9
+	})).Return(nil).Once()
+	mockStore.On("Save", mock.MatchedBy(func(m model.URLMapping) bool {
+		return m.Original == "https://example.com/b" && len(m.Code) == 6 && !m.CreatedAt.IsZero()
+	})).Return(nil).Once()
+
+	result := service.ImportMappings(context.Background(), "user123", records)
+
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 5, result.Failed)
+
+	rows := make(map[int]string)
+	for _, e := range result.Errors {
+		rows[e.Row] = e.Error
+	}
+	assert.Equal(t, "code already exists", rows[3])
+	assert.Contains(t, rows[4], "may only contain")
+	assert.Contains(t, rows[5], "reserved")
+	assert.Contains(t, rows[6], "invalid url")
+	assert.Contains(t, rows[7], "negative")
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestResolve_Metrics(t *testing.T) {
+	mockStore := NewAsyncMockStore()
+	service := NewService(mockStore, "https://short.url", 6)
+
+	expectedURL := "https://example.com/very/long/url"
+	expiredAt := time.Now().Add(-time.Hour)
+
+	mockStore.On("Get", "", "abc123").Return(&model.URLMapping{Code: "abc123", Original: expectedURL}, nil)
+	mockStore.On("IncrementClickCount", "", "abc123").Return(nil)
+	mockStore.On("Get", "", "expired").Return(nil, nil)
+	mockStore.On("GetMapping", "", "expired").Return(&model.URLMapping{Code: "expired", ExpiresAt: &expiredAt}, nil)
+	mockStore.On("Get", "", "missing").Return(nil, nil)
+	mockStore.On("GetMapping", "", "missing").Return(nil, nil)
+
+	served := testutil.ToFloat64(metrics.RedirectsServed)
+	expired := testutil.ToFloat64(metrics.RedirectMisses.WithLabelValues(metrics.MissExpired))
+	notFound := testutil.ToFloat64(metrics.RedirectMisses.WithLabelValues(metrics.MissNotFound))
+
+	_, err := service.Resolve(context.Background(), ResolveRequest{Code: "abc123"})
+	assert.NoError(t, err)
+	<-mockStore.clickCountCalls
+
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "expired"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = service.Resolve(context.Background(), ResolveRequest{Code: "missing"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.Equal(t, served+1, testutil.ToFloat64(metrics.RedirectsServed))
+	assert.Equal(t, expired+1, testutil.ToFloat64(metrics.RedirectMisses.WithLabelValues(metrics.MissExpired)))
+	assert.Equal(t, notFound+1, testutil.ToFloat64(metrics.RedirectMisses.WithLabelValues(metrics.MissNotFound)))
+}
+
+func TestShorten_ActivatesAt(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	launch := time.Now().Add(time.Hour)
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com/launch", ActivatesAt: &launch})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &launch, saved.ActivatesAt)
+	assert.True(t, saved.Pending(time.Now()))
+}
+
+func TestResolve_NotActive(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	launch := time.Now().Add(time.Hour)
+	mockStore.On("Get", "", "launch").Return(nil, storage.ErrNotFound)
+	mockStore.On("GetMapping", "", "launch").Return(&model.URLMapping{Code: "launch", Original: "https://example.com", ActivatesAt: &launch}, nil)
+
+	notActive := testutil.ToFloat64(metrics.RedirectMisses.WithLabelValues(metrics.MissNotActive))
+
+	_, err := service.Resolve(context.Background(), ResolveRequest{Code: "launch"})
+
+	var notActiveErr *NotActiveError
+	assert.ErrorAs(t, err, &notActiveErr)
+	assert.ErrorIs(t, err, ErrNotActive)
+	assert.Equal(t, launch, notActiveErr.ActivatesAt)
+	assert.Equal(t, notActive+1, testutil.ToFloat64(metrics.RedirectMisses.WithLabelValues(metrics.MissNotActive)))
+}
+
+func TestUpdateMapping_ActivatesAt(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	launch := time.Now().Add(time.Hour)
+	expiresAt := launch.Add(24 * time.Hour)
+	mockStore.On("GetMapping", "", "abc123").Return(&model.URLMapping{Code: "abc123", UserID: "user123", ExpiresAt: &expiresAt}, nil)
+	mockStore.On("Update", mock.Anything).Return(nil)
+
+	mapping, err := service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{ActivatesAt: &launch})
+	assert.NoError(t, err)
+	assert.Equal(t, &launch, mapping.ActivatesAt)
+	mockStore.AssertCalled(t, "Update", *mapping)
+
+	// The zero time clears the activation time
+	mapping, err = service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{ActivatesAt: &time.Time{}})
+	assert.NoError(t, err)
+	assert.Nil(t, mapping.ActivatesAt)
+
+	// Links cannot activate after they expire
+	late := expiresAt.Add(time.Minute)
+	_, err = service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{ActivatesAt: &late})
+	assert.ErrorIs(t, err, ErrInvalidActivation)
+
+	_, err = service.UpdateMapping(context.Background(), "other", "", "abc123", MappingUpdate{ActivatesAt: &launch})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	mockStore.AssertNumberOfCalls(t, "Update", 2)
+}
+
+func TestUpdateMapping_Rules(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	rules := []model.RoutingRule{{Platform: model.PlatformAndroid, URL: "https://play.google.com/store/apps/details?id=com.example"}}
+	mockStore.On("GetMapping", "", "abc123").Return(&model.URLMapping{Code: "abc123", UserID: "user123", Rules: rules}, nil)
+	mockStore.On("Update", mock.Anything).Return(nil)
+
+	// Other updates leave the rules alone
+	launch := time.Now().Add(time.Hour)
+	mapping, err := service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{ActivatesAt: &launch})
+	assert.NoError(t, err)
+	assert.Equal(t, rules, mapping.Rules)
+
+	mapping, err = service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{Rules: &[]model.RoutingRule{}})
+	assert.NoError(t, err)
+	assert.Nil(t, mapping.Rules)
+
+	_, err = service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{Rules: &[]model.RoutingRule{{URL: "https://example.com"}}})
+	assert.ErrorIs(t, err, ErrInvalidRoutingRule)
+
+	mockStore.AssertNumberOfCalls(t, "Update", 2)
+}
+
+func TestShorten_Title(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	var saved model.URLMapping
+	mockStore.On("Save", mock.Anything).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(model.URLMapping)
+	}).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Title: "Quarterly report", Interstitial: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "Quarterly report", saved.Title)
+	assert.True(t, saved.Interstitial)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Title: strings.Repeat("é", 201)})
+	assert.ErrorIs(t, err, ErrInvalidTitle)
+	mockStore.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestResolve_Preview(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mapping := &model.URLMapping{Code: "abc123", Original: "https://example.com", Title: "Example", CreatedAt: createdAt, MaxClicks: 1}
+
+	mockStore := new(MockStore)
+	runner := &recordingRunner{}
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(runner))
+	mockStore.On("Get", "", "abc123").Return(mapping, nil)
+
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: "abc123", Preview: true})
+
+	assert.NoError(t, err)
+	assert.True(t, resolution.Interstitial)
+	assert.Equal(t, "https://example.com", resolution.URL)
+	assert.Equal(t, "Example", resolution.Title)
+	assert.Equal(t, createdAt, resolution.CreatedAt)
+	// Previews use up no clicks
+	mockStore.AssertNotCalled(t, "ConsumeClick", "", mock.Anything, mock.Anything)
+	assert.Empty(t, runner.tasks)
+}
+
+func TestResolve_Interstitial(t *testing.T) {
+	mockStore := new(MockStore)
+	runner := &recordingRunner{}
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(runner))
+	mockStore.On("Get", "", "abc123").Return(&model.URLMapping{Code: "abc123", Original: "https://example.com", Interstitial: true}, nil)
+
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Code: "abc123"})
+
+	assert.NoError(t, err)
+	assert.True(t, resolution.Interstitial)
+	// Serving the page of an interstitial link is its click
+	assert.Equal(t, []string{"increment_click_count"}, runner.tasks)
+}
+
+func TestUpdateMapping_Title(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("GetMapping", "", "abc123").Return(&model.URLMapping{Code: "abc123", UserID: "user123", Title: "Old"}, nil)
+	mockStore.On("Update", mock.Anything).Return(nil)
+
+	title, interstitial := "New", true
+	mapping, err := service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{Title: &title, Interstitial: &interstitial})
+	assert.NoError(t, err)
+	assert.Equal(t, "New", mapping.Title)
+	assert.True(t, mapping.Interstitial)
+
+	long := strings.Repeat("a", 201)
+	_, err = service.UpdateMapping(context.Background(), "user123", "", "abc123", MappingUpdate{Title: &long})
+	assert.ErrorIs(t, err, ErrInvalidTitle)
+
+	mockStore.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func TestShorten_CountsLinksCreated(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("Save", mock.AnythingOfType("model.URLMapping")).Return(nil)
+
+	before := testutil.ToFloat64(metrics.LinksCreated)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.LinksCreated))
+}
+
+func TestRegisterDomain(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("SaveDomain", mock.MatchedBy(func(d model.Domain) bool {
+		return d.Name == "go.example.com" && d.UserID == "user123"
+	})).Return(nil).Once()
+	mockStore.On("SaveDomain", mock.MatchedBy(func(d model.Domain) bool {
+		return d.Name == "taken.example.com"
+	})).Return(storage.ErrDomainExists)
+
+	domain, err := service.RegisterDomain(context.Background(), "user123", "Go.Example.com.")
+	assert.NoError(t, err)
+	assert.Equal(t, "go.example.com", domain.Name)
+
+	_, err = service.RegisterDomain(context.Background(), "user123", "taken.example.com")
+	assert.ErrorIs(t, err, ErrDomainExists)
+
+	for _, name := range []string{"", "localhost", "bad_host.com", "short.url", "-a.example.com"} {
+		_, err = service.RegisterDomain(context.Background(), "user123", name)
+		assert.ErrorIs(t, err, ErrInvalidDomain, name)
+	}
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestDeleteDomain(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("GetDomain", "go.example.com").Return(&model.Domain{Name: "go.example.com", UserID: "owner"}, nil)
+	mockStore.On("GetDomain", "missing.example.com").Return(nil, storage.ErrNotFound)
+	mockStore.On("DeleteDomain", "go.example.com").Return(storage.ErrDomainInUse).Once()
+	mockStore.On("DeleteDomain", "go.example.com").Return(nil).Once()
+
+	assert.ErrorIs(t, service.DeleteDomain(context.Background(), "intruder", "go.example.com"), ErrForbidden)
+	assert.ErrorIs(t, service.DeleteDomain(context.Background(), "owner", "missing.example.com"), ErrDomainNotFound)
+	assert.ErrorIs(t, service.DeleteDomain(context.Background(), "owner", "go.example.com"), ErrDomainInUse)
+	assert.NoError(t, service.DeleteDomain(context.Background(), "owner", "go.example.com"))
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestShorten_Domain(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("GetDomain", "go.example.com").Return(&model.Domain{Name: "go.example.com", UserID: "user123"}, nil)
+	mockStore.On("GetDomain", "missing.example.com").Return(nil, storage.ErrNotFound)
+	mockStore.On("Save", mock.MatchedBy(func(m model.URLMapping) bool {
+		return m.Domain == "go.example.com"
+	})).Return(nil)
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Domain: "Go.Example.com"})
+	assert.NoError(t, err)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "intruder", URL: "https://example.com", Domain: "go.example.com"})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com", Domain: "missing.example.com"})
+	assert.ErrorIs(t, err, ErrDomainNotFound)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestResolve_Domain(t *testing.T) {
+	mockStore := &MockStore{}
+	service := NewService(mockStore, "https://short.url", 6, WithRunner(&recordingRunner{}))
+
+	mockStore.On("GetDomain", "go.example.com").Return(&model.Domain{Name: "go.example.com", UserID: "user123"}, nil)
+	mockStore.On("GetDomain", "10.0.0.7").Return(nil, storage.ErrNotFound)
+	mockStore.On("Get", "go.example.com", "launch").Return(&model.URLMapping{Domain: "go.example.com", Code: "launch", Original: "https://example.com/custom"}, nil)
+	mockStore.On("Get", "", "launch").Return(&model.URLMapping{Code: "launch", Original: "https://example.com/default"}, nil)
+	mockStore.On("IncrementClickCount", "go.example.com", "launch").Return(nil)
+	mockStore.On("IncrementClickCount", "", "launch").Return(nil)
+	mockStore.On("GetMapping", "go.example.com", "launch").Return(&model.URLMapping{Domain: "go.example.com", Code: "launch"}, nil)
+
+	resolution, err := service.Resolve(context.Background(), ResolveRequest{Host: "GO.example.com:443", Code: "launch"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/custom", resolution.URL)
+
+	// The default domain is served on its own host and on unregistered ones
+	for _, host := range []string{"short.url", "10.0.0.7:8080", ""} {
+		resolution, err = service.Resolve(context.Background(), ResolveRequest{Host: host, Code: "launch"})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/default", resolution.URL)
+	}
+
+	shortURL, err := service.ShortURL(context.Background(), "go.example.com", "launch")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://go.example.com/launch", shortURL)
+}
+
+func TestLinkURL(t *testing.T) {
+	assert.Equal(t, "https://short.url/abc123", LinkURL("https://short.url", "", "abc123"))
+	assert.Equal(t, "https://go.example.com/abc123", LinkURL("https://short.url", "go.example.com", "abc123"))
+	assert.Equal(t, "http://go.example.com/abc123", LinkURL("http://localhost:8080", "go.example.com", "abc123"))
+}
+
+func TestOrganizations_Members(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(storage.NewMemoryStore(), "https://short.url", 6)
+
+	_, err := service.CreateOrganization(ctx, "alice", "  ")
+	assert.ErrorIs(t, err, ErrInvalidOrganization)
+
+	org, err := service.CreateOrganization(ctx, "alice", "Acme")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(org.ID, "org_"))
+
+	memberships, err := service.ListOrganizations(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Membership{{OrgID: org.ID, UserID: "alice", Role: model.RoleOwner, CreatedAt: org.CreatedAt}}, memberships)
+
+	_, err = service.SetMember(ctx, "alice", org.ID, "bob", "superuser")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	_, err = service.SetMember(ctx, "alice", org.ID, "bob", model.RoleAdmin)
+	assert.NoError(t, err)
+	_, err = service.SetMember(ctx, "bob", org.ID, "carol", model.RoleViewer)
+	assert.NoError(t, err)
+
+	// Admins manage members other than owners
+	_, err = service.SetMember(ctx, "bob", org.ID, "carol", model.RoleOwner)
+	assert.ErrorIs(t, err, ErrInsufficientRole)
+	_, err = service.SetMember(ctx, "bob", org.ID, "alice", model.RoleViewer)
+	assert.ErrorIs(t, err, ErrInsufficientRole)
+	_, err = service.SetMember(ctx, "carol", org.ID, "dave", model.RoleViewer)
+	assert.ErrorIs(t, err, ErrInsufficientRole)
+	_, err = service.SetMember(ctx, "mallory", org.ID, "mallory", model.RoleOwner)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = service.ListMembers(ctx, "alice", "org_missing")
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+
+	// The last owner can neither leave nor be demoted
+	assert.ErrorIs(t, service.RemoveMember(ctx, "alice", org.ID, "alice"), ErrLastOwner)
+	_, err = service.SetMember(ctx, "alice", org.ID, "alice", model.RoleAdmin)
+	assert.ErrorIs(t, err, ErrLastOwner)
+	_, err = service.SetMember(ctx, "alice", org.ID, "bob", model.RoleOwner)
+	assert.NoError(t, err)
+	assert.NoError(t, service.RemoveMember(ctx, "bob", org.ID, "alice"))
+
+	assert.NoError(t, service.RemoveMember(ctx, "carol", org.ID, "carol"))
+	assert.ErrorIs(t, service.RemoveMember(ctx, "bob", org.ID, "carol"), ErrMemberNotFound)
+
+	members, err := service.ListMembers(ctx, "bob", org.ID)
+	assert.NoError(t, err)
+	assert.Len(t, members, 1)
+	assert.Equal(t, model.RoleOwner, members[0].Role)
+}
+
+func TestOrganizations_Links(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(storage.NewMemoryStore(), "https://short.url", 6)
+
+	org, err := service.CreateOrganization(ctx, "alice", "Acme")
+	assert.NoError(t, err)
+	for member, role := range map[string]model.Role{"ed": model.RoleEditor, "vic": model.RoleViewer} {
+		_, err = service.SetMember(ctx, "alice", org.ID, member, role)
+		assert.NoError(t, err)
+	}
+
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "vic", URL: "https://example.com", OrgID: org.ID})
+	assert.ErrorIs(t, err, ErrInsufficientRole)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "mallory", URL: "https://example.com", OrgID: org.ID})
+	assert.ErrorIs(t, err, ErrForbidden)
+	code, err := service.Shorten(ctx, ShortenRequest{UserID: "ed", URL: "https://example.com", OrgID: org.ID})
+	assert.NoError(t, err)
+
+	// Links of the organization are listed apart from personal ones
+	personal, err := service.ListMappings(ctx, "ed", "", model.MappingFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, personal)
+	shared, err := service.ListMappings(ctx, "vic", org.ID, model.MappingFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, shared, 1)
+	_, err = service.ListMappings(ctx, "mallory", org.ID, model.MappingFilter{})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	// Viewers see stats, editors change links
+	mapping, err := service.GetMapping(ctx, "vic", "", code)
+	assert.NoError(t, err)
+	assert.Equal(t, org.ID, mapping.OrgID)
+	_, err = service.GetMapping(ctx, "mallory", "", code)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	title := "Team link"
+	_, err = service.UpdateMapping(ctx, "vic", "", code, MappingUpdate{Title: &title})
+	assert.ErrorIs(t, err, ErrInsufficientRole)
+	mapping, err = service.UpdateMapping(ctx, "alice", "", code, MappingUpdate{Title: &title})
+	assert.NoError(t, err)
+	assert.Equal(t, "ed", mapping.UserID)
+
+	assert.ErrorIs(t, service.DeleteMapping(ctx, "vic", "", code), ErrInsufficientRole)
+	assert.NoError(t, service.DeleteMapping(ctx, "ed", "", code))
+}
+
+func TestQuotas(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	service := NewService(store, "https://short.url", 6, WithQuotas(model.Limits{ActiveLinks: 2, MonthlyRedirects: 3}))
+
+	first, err := service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.NoError(t, err)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.NoError(t, err)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.ErrorIs(t, err, ErrActiveLinksQuota)
+
+	// Links of an organization count against it, not against their creator
+	org, err := service.CreateOrganization(ctx, "alice", "Acme")
+	assert.NoError(t, err)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com", OrgID: org.ID})
+	assert.NoError(t, err)
+
+	unlimited := 0
+	limits, err := service.SetQuota(ctx, model.Quota{Account: model.Account{UserID: "alice"}, ActiveLinks: &unlimited})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Limits{ActiveLinks: 0, MonthlyRedirects: 3}, limits)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.NoError(t, err)
+
+	for range 3 {
+		_, err = service.Resolve(ctx, ResolveRequest{Code: first})
+		assert.NoError(t, err)
+	}
+	// Redirects past the quota are refused and not counted, creating links is not
+	_, err = service.Resolve(ctx, ResolveRequest{Code: first})
+	assert.ErrorIs(t, err, ErrRedirectQuota)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.NoError(t, err)
+
+	usage, err := service.Usage(ctx, "alice", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, usage.ActiveLinks)
+	assert.Equal(t, 3, usage.MonthlyRedirects)
+	assert.Equal(t, model.Limits{ActiveLinks: 0, MonthlyRedirects: 3}, usage.Limits)
+	assert.Equal(t, 1, usage.Month.Day())
+
+	usage, err = service.Usage(ctx, "alice", org.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.Account{OrgID: org.ID}, usage.Account)
+	assert.Equal(t, 1, usage.ActiveLinks)
+	_, err = service.Usage(ctx, "mallory", org.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	negative := -1
+	_, err = service.SetQuota(ctx, model.Quota{ActiveLinks: &unlimited})
+	assert.ErrorIs(t, err, ErrInvalidAccount)
+	_, err = service.SetQuota(ctx, model.Quota{Account: model.Account{UserID: "alice"}, MonthlyRedirects: &negative})
+	assert.ErrorIs(t, err, ErrInvalidQuota)
+	_, err = service.SetQuota(ctx, model.Quota{Account: model.Account{OrgID: "org_missing"}})
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+}
+
+func TestTags(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(storage.NewMemoryStore(), "https://short.url", 6)
+
+	deck, err := service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com/deck", Tags: []string{"Q3", " slides", "q3"}, Folder: " Sales "})
+	assert.NoError(t, err)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com/memo", Tags: []string{"q3"}})
+	assert.NoError(t, err)
+
+	mapping, err := service.GetMapping(ctx, "alice", "", deck)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"q3", "slides"}, mapping.Tags)
+	assert.Equal(t, "Sales", mapping.Folder)
+
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com", Tags: []string{"no,commas"}})
+	assert.ErrorIs(t, err, ErrInvalidTags)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com", Folder: strings.Repeat("f", 101)})
+	assert.ErrorIs(t, err, ErrInvalidFolder)
+
+	listed, err := service.ListMappings(ctx, "alice", "", model.MappingFilter{Tag: "Q3"})
+	assert.NoError(t, err)
+	assert.Len(t, listed, 2)
+	listed, err = service.ListMappings(ctx, "alice", "", model.MappingFilter{Folder: "Sales"})
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+
+	tags := []string{"launch"}
+	folder := ""
+	mapping, err = service.UpdateMapping(ctx, "alice", "", deck, MappingUpdate{Tags: &tags, Folder: &folder})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"launch"}, mapping.Tags)
+	assert.Empty(t, mapping.Folder)
+
+	counts, err := service.ListTags(ctx, "alice", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TagCount{{Tag: "launch", Links: 1}, {Tag: "q3", Links: 1}}, counts)
+
+	renamed, err := service.RenameTag(ctx, "alice", "", "Q3", "Launch")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.TagCount{Tag: "launch", Links: 1}, renamed)
+	_, err = service.RenameTag(ctx, "alice", "", "q3", "q4")
+	assert.ErrorIs(t, err, ErrTagNotFound)
+	_, err = service.RenameTag(ctx, "alice", "", "launch", "")
+	assert.ErrorIs(t, err, ErrInvalidTags)
+
+	// Viewers of an organization see its tags but cannot rename them
+	org, err := service.CreateOrganization(ctx, "alice", "Acme")
+	assert.NoError(t, err)
+	_, err = service.SetMember(ctx, "alice", org.ID, "vic", model.RoleViewer)
+	assert.NoError(t, err)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com", OrgID: org.ID, Tags: []string{"team"}})
+	assert.NoError(t, err)
+	counts, err = service.ListTags(ctx, "vic", org.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TagCount{{Tag: "team", Links: 1}}, counts)
+	_, err = service.RenameTag(ctx, "vic", org.ID, "team", "crew")
+	assert.ErrorIs(t, err, ErrInsufficientRole)
+	_, err = service.ListTags(ctx, "mallory", org.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestSearchMappings(t *testing.T) {
+	ctx := context.Background()
+	service := NewService(storage.NewMemoryStore(), "https://short.url", 6)
+
+	memo, err := service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com/memo", Title: "Launch memo"})
+	assert.NoError(t, err)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com/launch"})
+	assert.NoError(t, err)
+	_, err = service.Shorten(ctx, ShortenRequest{UserID: "bob", URL: "https://example.com/launch", Title: "Launch"})
+	assert.NoError(t, err)
+
+	mappings, err := service.SearchMappings(ctx, "alice", "", "  launch ", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, mappings, 2) {
+		assert.Equal(t, memo, mappings[0].Code)
+	}
+
+	_, err = service.SearchMappings(ctx, "alice", "", "--", 10)
+	assert.ErrorIs(t, err, ErrInvalidSearch)
+	_, err = service.SearchMappings(ctx, "alice", "", strings.Repeat("a", 201), 10)
+	assert.ErrorIs(t, err, ErrInvalidSearch)
+
+	org, err := service.CreateOrganization(ctx, "alice", "Acme")
+	assert.NoError(t, err)
+	_, err = service.SearchMappings(ctx, "mallory", org.ID, "launch", 10)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+// stubFetcher returns the metadata or error it holds for every page
+type stubFetcher struct {
+	metadata *model.Metadata
+	err      error
+	urls     []string
+}
+
+func (f *stubFetcher) Fetch(_ context.Context, url string) (*model.Metadata, error) {
+	f.urls = append(f.urls, url)
+	return f.metadata, f.err
+}
+
+func TestShorten_FetchesMetadata(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	runner := &recordingRunner{}
+	fetcher := &stubFetcher{metadata: &model.Metadata{Title: "Launch", Image: "https://example.com/cover.png"}}
+	service := NewService(store, "https://short.url", 6, WithRunner(runner), WithMetadataFetcher(fetcher))
+
+	code, err := service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com/launch"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fetch_metadata"}, runner.tasks)
+
+	// Shorten returns before the page is fetched
+	mapping, err := service.GetMapping(ctx, "alice", "", code)
+	assert.NoError(t, err)
+	assert.Nil(t, mapping.Metadata)
+
+	runner.fns[0]()
+	assert.Equal(t, []string{"https://example.com/launch"}, fetcher.urls)
+	mapping, err = service.GetMapping(ctx, "alice", "", code)
+	assert.NoError(t, err)
+	assert.Equal(t, fetcher.metadata, mapping.Metadata)
+
+	// Updates keep the metadata
+	title := "Renamed"
+	mapping, err = service.UpdateMapping(ctx, "alice", "", code, MappingUpdate{Title: &title})
+	assert.NoError(t, err)
+	assert.Equal(t, "Launch", mapping.Metadata.Title)
+
+	// Links deleted before their page is fetched are skipped
+	code, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com/gone"})
+	assert.NoError(t, err)
+	assert.NoError(t, service.DeleteMapping(ctx, "alice", "", code))
+	runner.fns[1]()
+
+	// Pages that cannot be fetched leave the link without metadata
+	fetcher.metadata, fetcher.err = nil, errors.New("connection refused")
+	code, err = service.Shorten(ctx, ShortenRequest{UserID: "alice", URL: "https://example.com/down"})
+	assert.NoError(t, err)
+	runner.fns[2]()
+	mapping, err = service.GetMapping(ctx, "alice", "", code)
+	assert.NoError(t, err)
+	assert.Nil(t, mapping.Metadata)
+}
+
+func TestShorten_SkipsMetadataWhenBusy(t *testing.T) {
+	runner := &recordingRunner{}
+	fetcher := &stubFetcher{metadata: &model.Metadata{Title: "Example"}}
+	service := NewService(storage.NewMemoryStore(), "https://short.url", 6, WithRunner(runner), WithMetadataFetcher(fetcher))
+
+	// Every slot is held by a fetch still in flight
+	for range maxPendingFetches {
+		service.pendingFetches <- struct{}{}
+	}
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.NoError(t, err)
+	runner.fns[0]()
+	assert.Empty(t, fetcher.urls)
+
+	// A finished fetch makes room for the next one
+	<-service.pendingFetches
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.NoError(t, err)
+	runner.fns[1]()
+	assert.Equal(t, []string{"https://example.com"}, fetcher.urls)
+	assert.Len(t, service.pendingFetches, maxPendingFetches-1)
+}
+
+// droppingRunner rejects every task, as a draining Lifecycle does
+type droppingRunner struct{}
+
+func (droppingRunner) Go(string, func()) {}
+
+func TestShorten_DroppedFetchesHoldNoSlot(t *testing.T) {
+	service := NewService(storage.NewMemoryStore(), "https://short.url", 6, WithRunner(droppingRunner{}), WithMetadataFetcher(&stubFetcher{}))
+
+	for range maxPendingFetches + 1 {
+		_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "alice", URL: "https://example.com"})
+		assert.NoError(t, err)
+	}
+	assert.Empty(t, service.pendingFetches)
+}
+
+func TestShorten_WithoutMetadataFetcher(t *testing.T) {
+	runner := &recordingRunner{}
+	service := NewService(storage.NewMemoryStore(), "https://short.url", 6, WithRunner(runner))
+
+	_, err := service.Shorten(context.Background(), ShortenRequest{UserID: "alice", URL: "https://example.com"})
+	assert.NoError(t, err)
+	assert.Empty(t, runner.tasks)
+}
+
+func TestShorten_RetriesTakenCodes(t *testing.T) {
+	mockStore := new(MockStore)
+	service := NewService(mockStore, "https://short.url", 6)
+
+	mockStore.On("Save", mock.Anything).Return(storage.ErrCodeExists).Twice()
+	mockStore.On("Save", mock.Anything).Return(nil).Once()
+
+	code, err := service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com"})
+	assert.NoError(t, err)
+	assert.Len(t, code, 6)
+	mockStore.AssertNumberOfCalls(t, "Save", 3)
+
+	mockStore.On("Save", mock.Anything).Return(storage.ErrCodeExists)
+	_, err = service.Shorten(context.Background(), ShortenRequest{UserID: "user123", URL: "https://example.com"})
+	assert.ErrorIs(t, err, storage.ErrCodeExists)
+}
@@ -0,0 +1,145 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	maxTagLength    = 50
+	maxTagsPerLink  = 20
+	maxFolderLength = 100
+)
+
+var (
+	// ErrInvalidTags is returned for tags that are empty, too long or use other characters, or too many of them
+	ErrInvalidTags = errors.New("tags must be 1 to 50 letters, digits, spaces, '-', '_' or '.', at most 20 per link")
+	// ErrInvalidFolder is returned for folder names that are too long or contain control characters
+	ErrInvalidFolder = errors.New("folder must be at most 100 characters, without control characters")
+	// ErrTagNotFound is returned when renaming a tag none of the account's links carry
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// normalizeTag trims and lowercases tag, so tags differing only in case are
+// the same tag
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// validTag reports whether a normalized tag is one a link may carry
+func validTag(tag string) bool {
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -_.", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTags returns tags normalized, sorted and without duplicates, or
+// ErrInvalidTags
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if !validTag(tag) {
+			return nil, ErrInvalidTags
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+
+	if len(normalized) > maxTagsPerLink {
+		return nil, ErrInvalidTags
+	}
+	return normalized, nil
+}
+
+// normalizeFolder trims folder and checks it fits, or returns ErrInvalidFolder
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if utf8.RuneCountInString(folder) > maxFolderLength || strings.ContainsFunc(folder, unicode.IsControl) {
+		return "", ErrInvalidFolder
+	}
+	return folder, nil
+}
+
+// ListTags returns the tags on userID's personal links, or on orgID's links
+// when set, ordered by tag
+func (s *ShortenerService) ListTags(ctx context.Context, userID, orgID string) ([]model.TagCount, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ListTags", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+	))
+	defer span.End()
+
+	account, err := s.accountFor(ctx, userID, orgID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := s.store.ListTags(ctx, account)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "ListTags failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// RenameTag renames the tag from to to on userID's personal links, or on
+// orgID's links when set, and returns the new tag with how many links it
+// changed. Links that already carry to keep it once.
+func (s *ShortenerService) RenameTag(ctx context.Context, userID, orgID, from, to string) (*model.TagCount, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.RenameTag", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("org.id", orgID),
+	))
+	defer span.End()
+
+	from, to = normalizeTag(from), normalizeTag(to)
+	if !validTag(to) {
+		return nil, ErrInvalidTags
+	}
+
+	account, err := s.accountFor(ctx, userID, orgID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed, err := s.store.RenameTag(ctx, account, from, to)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "RenameTag failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("orgID", orgID), slog.String("tag", from)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	if renamed == 0 {
+		return nil, ErrTagNotFound
+	}
+	return &model.TagCount{Tag: to, Links: renamed}, nil
+}
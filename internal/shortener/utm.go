@@ -0,0 +1,162 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/model"
+	"github.com/wiredmatt/go_short/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var utmTemplateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+var (
+	// ErrInvalidUTM is returned for UTM parameters that cannot be composed into a link
+	ErrInvalidUTM = errors.New("invalid utm parameters")
+	// ErrInvalidUTMTemplate is returned for templates that cannot be saved
+	ErrInvalidUTMTemplate = errors.New("invalid utm template")
+	// ErrUTMTemplateNotFound is returned when a user has no template of the given name
+	ErrUTMTemplateNotFound = errors.New("utm template not found")
+)
+
+// SaveUTMTemplate normalizes utm and saves it as the user's template called
+// name, replacing any template of the same name
+func (s *ShortenerService) SaveUTMTemplate(ctx context.Context, userID, name string, utm model.UTM) (*model.UTMTemplate, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.SaveUTMTemplate", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("utm.template", name),
+	))
+	defer span.End()
+
+	if !utmTemplateNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be 1 to 64 letters, digits, '-' or '_'", ErrInvalidUTMTemplate)
+	}
+
+	utm = utm.Normalize()
+	if utm.Empty() {
+		return nil, fmt.Errorf("%w: at least one parameter is required", ErrInvalidUTMTemplate)
+	}
+
+	template := model.UTMTemplate{
+		UserID:    userID,
+		Name:      name,
+		UTM:       utm,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.store.SaveUTMTemplate(ctx, template); err != nil {
+		s.logger.ErrorContext(ctx, "SaveUTMTemplate failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("name", name)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// ListUTMTemplates returns the user's templates ordered by name
+func (s *ShortenerService) ListUTMTemplates(ctx context.Context, userID string) ([]model.UTMTemplate, error) {
+	ctx, span := tracer.Start(ctx, "ShortenerService.ListUTMTemplates", trace.WithAttributes(
+		attribute.String("user.id", userID),
+	))
+	defer span.End()
+
+	templates, err := s.store.ListUTMTemplates(ctx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "ListUTMTemplates failed",
+			slog.String("userID", userID),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// DeleteUTMTemplate removes the user's template called name. Links created
+// from it keep their parameters.
+func (s *ShortenerService) DeleteUTMTemplate(ctx context.Context, userID, name string) error {
+	ctx, span := tracer.Start(ctx, "ShortenerService.DeleteUTMTemplate", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.String("utm.template", name),
+	))
+	defer span.End()
+
+	err := s.store.DeleteUTMTemplate(ctx, userID, name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ErrUTMTemplateNotFound
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "DeleteUTMTemplate failed",
+			slog.Group("input", slog.String("userID", userID), slog.String("name", name)),
+			slog.String("error", err.Error()),
+		)
+		failSpan(span, err)
+		return err
+	}
+
+	return nil
+}
+
+// applyUTM resolves the campaign parameters of req, starting from its template
+// if it names one, and composes them into its URL. Requests without parameters
+// keep their URL as is.
+func (s *ShortenerService) applyUTM(ctx context.Context, req ShortenRequest) (string, model.UTM, error) {
+	utm := req.UTM
+	if req.UTMTemplate != "" {
+		template, err := s.store.GetUTMTemplate(ctx, req.UserID, req.UTMTemplate)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return "", model.UTM{}, err
+		}
+		if template == nil {
+			return "", model.UTM{}, ErrUTMTemplateNotFound
+		}
+		utm = template.UTM.Merge(req.UTM)
+	}
+
+	if utm.Empty() {
+		return req.URL, utm, nil
+	}
+
+	utm = utm.Normalize()
+	if utm.Source == "" || utm.Medium == "" || utm.Campaign == "" {
+		return "", model.UTM{}, fmt.Errorf("%w: source, medium and campaign are required", ErrInvalidUTM)
+	}
+
+	destination, err := composeUTM(req.URL, utm)
+	return destination, utm, err
+}
+
+// composeUTM sets the utm_* parameters of raw, replacing any it already has,
+// and lowercases its scheme and host
+func composeUTM(raw string, utm model.UTM) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidURL, raw)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidURL, raw)
+	}
+
+	query := u.Query()
+	for key, value := range utm.Params() {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,72 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+const (
+	minVariants = 2
+	maxVariants = 10
+	// maxVariantWeight keeps weights readable as percentages or per mille
+	maxVariantWeight = 1000
+)
+
+// ErrInvalidVariants is returned for split links with too few or too many
+// variants, duplicate or malformed names, bad weights or invalid URLs
+var ErrInvalidVariants = errors.New("invalid variants")
+
+var variantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// validateVariants checks the variants of a split link. No variants at all
+// is valid and means the link is not split.
+func validateVariants(variants []model.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < minVariants || len(variants) > maxVariants {
+		return fmt.Errorf("%w: a split link needs between %d and %d variants", ErrInvalidVariants, minVariants, maxVariants)
+	}
+
+	seen := make(map[string]bool, len(variants))
+	for _, variant := range variants {
+		switch {
+		case !variantNamePattern.MatchString(variant.Name):
+			return fmt.Errorf("%w: name %q must be 1 to 32 letters, digits, '-' or '_'", ErrInvalidVariants, variant.Name)
+		case seen[variant.Name]:
+			return fmt.Errorf("%w: name %q is used twice", ErrInvalidVariants, variant.Name)
+		case variant.Weight < 0 || variant.Weight > maxVariantWeight:
+			return fmt.Errorf("%w: weight of %q must be between 0 and %d", ErrInvalidVariants, variant.Name, maxVariantWeight)
+		case validateURL(variant.URL) != nil:
+			return fmt.Errorf("%w: url of %q must be an absolute http(s) URL", ErrInvalidVariants, variant.Name)
+		}
+		seen[variant.Name] = true
+	}
+
+	if model.TotalWeight(variants) == 0 {
+		return fmt.Errorf("%w: at least one variant needs a weight", ErrInvalidVariants)
+	}
+
+	return nil
+}
+
+// pickVariant chooses the variant of a split link that serves req: the one
+// the visitor got before if the link is sticky and it still gets traffic,
+// otherwise one picked at random by weight. It returns nil for links that
+// are not split.
+func (s *ShortenerService) pickVariant(mapping *model.URLMapping, req ResolveRequest) *model.Variant {
+	if mapping.StickyVariants && req.Variant != "" {
+		if variant := model.FindVariant(mapping.Variants, req.Variant); variant != nil && variant.Weight > 0 {
+			return variant
+		}
+	}
+
+	total := model.TotalWeight(mapping.Variants)
+	if total == 0 {
+		return nil
+	}
+	return model.PickVariant(mapping.Variants, s.roll(total))
+}
@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/wiredmatt/go_short/internal/api"
 	"github.com/wiredmatt/go_short/internal/config"
+	"github.com/wiredmatt/go_short/internal/logging"
 	"github.com/wiredmatt/go_short/internal/shortener"
 	"github.com/wiredmatt/go_short/internal/storage"
 )
@@ -26,7 +29,7 @@ func TestShortenerIntegration(t *testing.T) {
 	ctx := context.Background()
 
 	storage.ResetStore(ctx, cfg.Database)
-	store, err := storage.NewStore(ctx, cfg.Database)
+	store, err := storage.NewStore(ctx, cfg.Database, logging.New(os.Stdout, slog.LevelInfo))
 	assert.NoError(t, err)
 
 	service := shortener.NewService(store, cfg.App.BaseURL, cfg.App.ShortCodeLength)
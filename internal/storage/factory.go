@@ -3,16 +3,29 @@ package storage
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/wiredmatt/go_short/internal/config"
 )
 
-func NewStore(ctx context.Context, cfg config.DatabaseConfig) (Store, error) {
+// NewStore opens the configured backend, instrumented with latency metrics and
+// logging through logger
+func NewStore(ctx context.Context, cfg config.DatabaseConfig, logger *slog.Logger) (Store, error) {
 	switch cfg.Type {
 	case "memory":
-		return NewMemoryStore(), nil
+		return NewInstrumentedStore(NewMemoryStore(), cfg.Type, logger), nil
 	case "postgres":
-		return NewPostgresStore(ctx, cfg.ConnectionString)
+		var store *PostgresStore
+		var err error
+		if cfg.AutoMigrate {
+			store, err = NewPostgresStore(ctx, cfg.ConnectionString)
+		} else {
+			store, err = OpenPostgresStore(ctx, cfg.ConnectionString)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewInstrumentedStore(store, cfg.Type, logger), nil
 	case "redis":
 		return nil, fmt.Errorf("redis storage not yet implemented")
 	default:
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/wiredmatt/go_short/internal/metrics"
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+// InstrumentedStore records the latency of every operation of the wrapped
+// store and logs it with the caller's context, so store activity is
+// correlated with the request that triggered it
+type InstrumentedStore struct {
+	next    Store
+	backend string
+	logger  *slog.Logger
+}
+
+func NewInstrumentedStore(next Store, backend string, logger *slog.Logger) *InstrumentedStore {
+	return &InstrumentedStore{next: next, backend: backend, logger: logger}
+}
+
+// Unwrap returns the underlying store
+func (s *InstrumentedStore) Unwrap() Store {
+	return s.next
+}
+
+func (s *InstrumentedStore) observe(ctx context.Context, method string, start time.Time, err *error) {
+	duration := time.Since(start)
+	metrics.StoreOperationDuration.WithLabelValues(s.backend, method).Observe(duration.Seconds())
+
+	attrs := []slog.Attr{
+		slog.String("backend", s.backend),
+		slog.String("method", method),
+		slog.Int64("duration_ms", duration.Milliseconds()),
+	}
+	if *err != nil && !errors.Is(*err, ErrNotFound) {
+		attrs = append(attrs, slog.String("error", (*err).Error()))
+		s.logger.LogAttrs(ctx, slog.LevelWarn, "store operation failed", attrs...)
+		return
+	}
+	s.logger.LogAttrs(ctx, slog.LevelDebug, "store operation", attrs...)
+}
+
+func (s *InstrumentedStore) Save(ctx context.Context, mapping model.URLMapping) (err error) {
+	defer s.observe(ctx, "Save", time.Now(), &err)
+	return s.next.Save(ctx, mapping)
+}
+
+func (s *InstrumentedStore) SaveWithinLimit(ctx context.Context, mapping model.URLMapping, maxActiveLinks int) (err error) {
+	defer s.observe(ctx, "SaveWithinLimit", time.Now(), &err)
+	return s.next.SaveWithinLimit(ctx, mapping, maxActiveLinks)
+}
+
+func (s *InstrumentedStore) Get(ctx context.Context, domain, code string) (_ *model.URLMapping, err error) {
+	defer s.observe(ctx, "Get", time.Now(), &err)
+	return s.next.Get(ctx, domain, code)
+}
+
+func (s *InstrumentedStore) GetMapping(ctx context.Context, domain, code string) (_ *model.URLMapping, err error) {
+	defer s.observe(ctx, "GetMapping", time.Now(), &err)
+	return s.next.GetMapping(ctx, domain, code)
+}
+
+func (s *InstrumentedStore) Update(ctx context.Context, mapping model.URLMapping) (err error) {
+	defer s.observe(ctx, "Update", time.Now(), &err)
+	return s.next.Update(ctx, mapping)
+}
+
+func (s *InstrumentedStore) SaveMetadata(ctx context.Context, domain, code, original string, metadata model.Metadata) (err error) {
+	defer s.observe(ctx, "SaveMetadata", time.Now(), &err)
+	return s.next.SaveMetadata(ctx, domain, code, original, metadata)
+}
+
+func (s *InstrumentedStore) IncrementClickCount(ctx context.Context, domain, code string) (err error) {
+	defer s.observe(ctx, "IncrementClickCount", time.Now(), &err)
+	return s.next.IncrementClickCount(ctx, domain, code)
+}
+
+func (s *InstrumentedStore) ConsumeClick(ctx context.Context, domain, code string, maxRedirects int) (err error) {
+	defer s.observe(ctx, "ConsumeClick", time.Now(), &err)
+	return s.next.ConsumeClick(ctx, domain, code, maxRedirects)
+}
+
+func (s *InstrumentedStore) IncrementVariantClickCount(ctx context.Context, domain, code, variant string) (err error) {
+	defer s.observe(ctx, "IncrementVariantClickCount", time.Now(), &err)
+	return s.next.IncrementVariantClickCount(ctx, domain, code, variant)
+}
+
+func (s *InstrumentedStore) ListByUser(ctx context.Context, userID string, filter model.MappingFilter) (_ []model.URLMapping, err error) {
+	defer s.observe(ctx, "ListByUser", time.Now(), &err)
+	return s.next.ListByUser(ctx, userID, filter)
+}
+
+func (s *InstrumentedStore) ListByOrg(ctx context.Context, orgID string, filter model.MappingFilter) (_ []model.URLMapping, err error) {
+	defer s.observe(ctx, "ListByOrg", time.Now(), &err)
+	return s.next.ListByOrg(ctx, orgID, filter)
+}
+
+func (s *InstrumentedStore) Delete(ctx context.Context, domain, code string) (err error) {
+	defer s.observe(ctx, "Delete", time.Now(), &err)
+	return s.next.Delete(ctx, domain, code)
+}
+
+func (s *InstrumentedStore) CountActive(ctx context.Context) (_ int, err error) {
+	defer s.observe(ctx, "CountActive", time.Now(), &err)
+	return s.next.CountActive(ctx)
+}
+
+func (s *InstrumentedStore) SaveUTMTemplate(ctx context.Context, template model.UTMTemplate) (err error) {
+	defer s.observe(ctx, "SaveUTMTemplate", time.Now(), &err)
+	return s.next.SaveUTMTemplate(ctx, template)
+}
+
+func (s *InstrumentedStore) GetUTMTemplate(ctx context.Context, userID, name string) (_ *model.UTMTemplate, err error) {
+	defer s.observe(ctx, "GetUTMTemplate", time.Now(), &err)
+	return s.next.GetUTMTemplate(ctx, userID, name)
+}
+
+func (s *InstrumentedStore) ListUTMTemplates(ctx context.Context, userID string) (_ []model.UTMTemplate, err error) {
+	defer s.observe(ctx, "ListUTMTemplates", time.Now(), &err)
+	return s.next.ListUTMTemplates(ctx, userID)
+}
+
+func (s *InstrumentedStore) DeleteUTMTemplate(ctx context.Context, userID, name string) (err error) {
+	defer s.observe(ctx, "DeleteUTMTemplate", time.Now(), &err)
+	return s.next.DeleteUTMTemplate(ctx, userID, name)
+}
+
+func (s *InstrumentedStore) SaveDomain(ctx context.Context, domain model.Domain) (err error) {
+	defer s.observe(ctx, "SaveDomain", time.Now(), &err)
+	return s.next.SaveDomain(ctx, domain)
+}
+
+func (s *InstrumentedStore) GetDomain(ctx context.Context, name string) (_ *model.Domain, err error) {
+	defer s.observe(ctx, "GetDomain", time.Now(), &err)
+	return s.next.GetDomain(ctx, name)
+}
+
+func (s *InstrumentedStore) ListDomains(ctx context.Context, userID string) (_ []model.Domain, err error) {
+	defer s.observe(ctx, "ListDomains", time.Now(), &err)
+	return s.next.ListDomains(ctx, userID)
+}
+
+func (s *InstrumentedStore) DeleteDomain(ctx context.Context, name string) (err error) {
+	defer s.observe(ctx, "DeleteDomain", time.Now(), &err)
+	return s.next.DeleteDomain(ctx, name)
+}
+
+func (s *InstrumentedStore) SaveOrganization(ctx context.Context, org model.Organization, owner model.Membership) (err error) {
+	defer s.observe(ctx, "SaveOrganization", time.Now(), &err)
+	return s.next.SaveOrganization(ctx, org, owner)
+}
+
+func (s *InstrumentedStore) GetOrganization(ctx context.Context, id string) (_ *model.Organization, err error) {
+	defer s.observe(ctx, "GetOrganization", time.Now(), &err)
+	return s.next.GetOrganization(ctx, id)
+}
+
+func (s *InstrumentedStore) ListMemberships(ctx context.Context, userID string) (_ []model.Membership, err error) {
+	defer s.observe(ctx, "ListMemberships", time.Now(), &err)
+	return s.next.ListMemberships(ctx, userID)
+}
+
+func (s *InstrumentedStore) SaveMember(ctx context.Context, member model.Membership) (err error) {
+	defer s.observe(ctx, "SaveMember", time.Now(), &err)
+	return s.next.SaveMember(ctx, member)
+}
+
+func (s *InstrumentedStore) GetMember(ctx context.Context, orgID, userID string) (_ *model.Membership, err error) {
+	defer s.observe(ctx, "GetMember", time.Now(), &err)
+	return s.next.GetMember(ctx, orgID, userID)
+}
+
+func (s *InstrumentedStore) ListMembers(ctx context.Context, orgID string) (_ []model.Membership, err error) {
+	defer s.observe(ctx, "ListMembers", time.Now(), &err)
+	return s.next.ListMembers(ctx, orgID)
+}
+
+func (s *InstrumentedStore) DeleteMember(ctx context.Context, orgID, userID string) (err error) {
+	defer s.observe(ctx, "DeleteMember", time.Now(), &err)
+	return s.next.DeleteMember(ctx, orgID, userID)
+}
+
+func (s *InstrumentedStore) CountActiveLinks(ctx context.Context, account model.Account) (_ int, err error) {
+	defer s.observe(ctx, "CountActiveLinks", time.Now(), &err)
+	return s.next.CountActiveLinks(ctx, account)
+}
+
+func (s *InstrumentedStore) MonthlyRedirects(ctx context.Context, account model.Account, month time.Time) (_ int, err error) {
+	defer s.observe(ctx, "MonthlyRedirects", time.Now(), &err)
+	return s.next.MonthlyRedirects(ctx, account, month)
+}
+
+func (s *InstrumentedStore) SaveQuota(ctx context.Context, quota model.Quota) (err error) {
+	defer s.observe(ctx, "SaveQuota", time.Now(), &err)
+	return s.next.SaveQuota(ctx, quota)
+}
+
+func (s *InstrumentedStore) GetQuota(ctx context.Context, account model.Account) (_ *model.Quota, err error) {
+	defer s.observe(ctx, "GetQuota", time.Now(), &err)
+	return s.next.GetQuota(ctx, account)
+}
+
+func (s *InstrumentedStore) ListTags(ctx context.Context, account model.Account) (_ []model.TagCount, err error) {
+	defer s.observe(ctx, "ListTags", time.Now(), &err)
+	return s.next.ListTags(ctx, account)
+}
+
+func (s *InstrumentedStore) RenameTag(ctx context.Context, account model.Account, from, to string) (_ int, err error) {
+	defer s.observe(ctx, "RenameTag", time.Now(), &err)
+	return s.next.RenameTag(ctx, account, from, to)
+}
+
+func (s *InstrumentedStore) SearchMappings(ctx context.Context, account model.Account, query string, limit int) (_ []model.URLMapping, err error) {
+	defer s.observe(ctx, "SearchMappings", time.Now(), &err)
+	return s.next.SearchMappings(ctx, account, query, limit)
+}
+
+func (s *InstrumentedStore) Ping(ctx context.Context) (err error) {
+	defer s.observe(ctx, "Ping", time.Now(), &err)
+	return s.next.Ping(ctx)
+}
+
+func (s *InstrumentedStore) Close() {
+	s.next.Close()
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wiredmatt/go_short/internal/logging"
+	"github.com/wiredmatt/go_short/internal/metrics"
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+func TestInstrumentedStore_DelegatesAndObserves(t *testing.T) {
+	inner := NewMemoryStore()
+	store := NewInstrumentedStore(inner, "test", logging.New(io.Discard, slog.LevelInfo))
+
+	mapping := model.URLMapping{
+		Code:      "abc123",
+		Original:  "https://example.com",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+	}
+
+	assert.NoError(t, store.Save(context.Background(), mapping))
+
+	url, err := store.Get(context.Background(), "", "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", url.Original)
+
+	count, err := store.CountActive(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.Equal(t, inner, store.Unwrap())
+
+	// One histogram series per observed method
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.StoreOperationDuration))
+}
+
+func TestInstrumentedStore_LogsWithRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	store := NewInstrumentedStore(NewMemoryStore(), "test", logging.New(&buf, slog.LevelDebug))
+
+	ctx := logging.WithRequestID(context.Background(), "req-42")
+
+	_, err := store.Get(ctx, "", "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, "store operation", line["msg"])
+	assert.Equal(t, "DEBUG", line["level"])
+	assert.Equal(t, "Get", line["method"])
+	assert.Equal(t, "req-42", line["request_id"])
+}
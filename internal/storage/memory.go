@@ -1,71 +1,566 @@
 package storage
 
 import (
-	"errors"
+	"context"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/wiredmatt/go_short/internal/model"
 )
 
+// usageKey identifies the redirects of an account in a month
+type usageKey struct {
+	account model.Account
+	month   string
+}
+
+// monthOf formats the calendar month of t in UTC
+func monthOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// mappingKey identifies a mapping by its domain and code
+type mappingKey struct {
+	domain string
+	code   string
+}
+
 type MemoryStore struct {
-	data map[string]model.URLMapping
-	mu   sync.RWMutex
+	data map[mappingKey]model.URLMapping
+	// utmTemplates is keyed by user ID, then template name
+	utmTemplates map[string]map[string]model.UTMTemplate
+	domains      map[string]model.Domain
+	orgs         map[string]model.Organization
+	// members is keyed by organization ID, then user ID
+	members   map[string]map[string]model.Membership
+	redirects map[usageKey]int
+	quotas    map[model.Account]model.Quota
+	index     searchIndex
+	mu        sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		data: make(map[string]model.URLMapping),
+		data:         make(map[mappingKey]model.URLMapping),
+		utmTemplates: make(map[string]map[string]model.UTMTemplate),
+		domains:      make(map[string]model.Domain),
+		orgs:         make(map[string]model.Organization),
+		members:      make(map[string]map[string]model.Membership),
+		redirects:    make(map[usageKey]int),
+		quotas:       make(map[model.Account]model.Quota),
+		index:        make(searchIndex),
 	}
 }
 
-func (m *MemoryStore) Save(mapping model.URLMapping) error {
+func (m *MemoryStore) Save(_ context.Context, mapping model.URLMapping) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.data[mapping.Code] = mapping
+	return m.insert(mapping)
+}
+
+func (m *MemoryStore) SaveWithinLimit(_ context.Context, mapping model.URLMapping, maxActiveLinks int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.countActiveLinks(model.AccountOf(mapping)) >= maxActiveLinks {
+		return ErrActiveLinksLimitReached
+	}
+	return m.insert(mapping)
+}
+
+// insert adds mapping unless its code is taken on its domain. The caller
+// must hold m.mu.
+func (m *MemoryStore) insert(mapping model.URLMapping) error {
+	key := mappingKey{mapping.Domain, mapping.Code}
+	if _, exists := m.data[key]; exists {
+		return ErrCodeExists
+	}
+	m.data[key] = mapping
+	m.index.add(key, mapping)
 	return nil
 }
 
-func (m *MemoryStore) Get(code string) (*string, error) {
+func (m *MemoryStore) Get(_ context.Context, domain, code string) (*model.URLMapping, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	mapping, exists := m.data[code]
+	mapping, exists := m.data[mappingKey{domain, code}]
+	now := time.Now()
+	if !exists || mapping.Expired(now) || mapping.Pending(now) {
+		return nil, ErrNotFound
+	}
+	return &mapping, nil
+}
+
+func (m *MemoryStore) GetMapping(_ context.Context, domain, code string) (*model.URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mapping, exists := m.data[mappingKey{domain, code}]
 	if !exists {
-		return nil, errors.New("code not found")
+		return nil, ErrNotFound
 	}
-	return &mapping.Original, nil
+	return &mapping, nil
 }
 
-func (m *MemoryStore) IncrementClickCount(code string) error {
+func (m *MemoryStore) Update(_ context.Context, mapping model.URLMapping) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	mapping, exists := m.data[code]
+	key := mappingKey{mapping.Domain, mapping.Code}
+	existing, exists := m.data[key]
 	if !exists {
-		return errors.New("code not found")
+		return ErrNotFound
+	}
+	mapping.UserID = existing.UserID
+	mapping.CreatedAt = existing.CreatedAt
+	mapping.Clicks = existing.Clicks
+	mapping.VariantClicks = existing.VariantClicks
+	mapping.Metadata = existing.Metadata
+	m.data[key] = mapping
+	m.index.remove(key, existing)
+	m.index.add(key, mapping)
+	return nil
+}
+
+func (m *MemoryStore) SaveMetadata(_ context.Context, domain, code, original string, metadata model.Metadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := mappingKey{domain, code}
+	mapping, exists := m.data[key]
+	if !exists || mapping.Original != original {
+		return ErrNotFound
+	}
+	mapping.Metadata = &metadata
+	m.data[key] = mapping
+	return nil
+}
+
+func (m *MemoryStore) IncrementClickCount(_ context.Context, domain, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := mappingKey{domain, code}
+	mapping, exists := m.data[key]
+	if !exists {
+		return ErrNotFound
+	}
+	mapping.Clicks++
+	m.data[key] = mapping
+	m.redirects[usageKey{model.AccountOf(mapping), monthOf(time.Now())}]++
+	return nil
+}
+
+func (m *MemoryStore) ConsumeClick(_ context.Context, domain, code string, maxRedirects int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := mappingKey{domain, code}
+	mapping, exists := m.data[key]
+	if !exists {
+		return ErrNotFound
+	}
+	if mapping.Exhausted() {
+		return ErrClickLimitReached
+	}
+	usage := usageKey{model.AccountOf(mapping), monthOf(time.Now())}
+	if maxRedirects > 0 && m.redirects[usage] >= maxRedirects {
+		return ErrRedirectLimitReached
 	}
 	mapping.Clicks++
-	m.data[code] = mapping
+	m.data[key] = mapping
+	m.redirects[usage]++
+	return nil
+}
+
+func (m *MemoryStore) IncrementVariantClickCount(_ context.Context, domain, code, variant string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := mappingKey{domain, code}
+	mapping, exists := m.data[key]
+	if !exists {
+		return ErrNotFound
+	}
+	// Copies handed out by Get share the map, so it is replaced rather than
+	// written to
+	clicks := maps.Clone(mapping.VariantClicks)
+	if clicks == nil {
+		clicks = make(map[string]int)
+	}
+	clicks[variant]++
+	mapping.VariantClicks = clicks
+	m.data[key] = mapping
 	return nil
 }
 
-func (m *MemoryStore) ListByUser(userID string) ([]model.URLMapping, error) {
+func (m *MemoryStore) ListByUser(_ context.Context, userID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var results []model.URLMapping
+	for _, mapping := range m.data {
+		if mapping.UserID == userID && mapping.OrgID == "" && filter.Match(mapping) {
+			results = append(results, mapping)
+		}
+	}
+	return results, nil
+}
+
+func (m *MemoryStore) ListByOrg(_ context.Context, orgID string, filter model.MappingFilter) ([]model.URLMapping, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	var results []model.URLMapping
 	for _, mapping := range m.data {
-		if mapping.UserID == userID {
+		if mapping.OrgID == orgID && filter.Match(mapping) {
 			results = append(results, mapping)
 		}
 	}
 	return results, nil
 }
 
-func (m *MemoryStore) Delete(code string) error {
+func (m *MemoryStore) Delete(_ context.Context, domain, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := mappingKey{domain, code}
+	existing, exists := m.data[key]
+	if !exists {
+		return ErrNotFound
+	}
+	delete(m.data, key)
+	m.index.remove(key, existing)
+	return nil
+}
+
+func (m *MemoryStore) CountActive(_ context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	count := 0
+	for _, mapping := range m.data {
+		if !mapping.Expired(now) && !mapping.Pending(now) && !mapping.Exhausted() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) SaveUTMTemplate(_ context.Context, template model.UTMTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	templates, exists := m.utmTemplates[template.UserID]
+	if !exists {
+		templates = make(map[string]model.UTMTemplate)
+		m.utmTemplates[template.UserID] = templates
+	}
+	// Replacing a template keeps its creation time
+	if existing, exists := templates[template.Name]; exists {
+		template.CreatedAt = existing.CreatedAt
+	}
+	templates[template.Name] = template
+	return nil
+}
+
+func (m *MemoryStore) GetUTMTemplate(_ context.Context, userID, name string) (*model.UTMTemplate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	template, exists := m.utmTemplates[userID][name]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &template, nil
+}
+
+func (m *MemoryStore) ListUTMTemplates(_ context.Context, userID string) ([]model.UTMTemplate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var results []model.UTMTemplate
+	for _, template := range m.utmTemplates[userID] {
+		results = append(results, template)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+func (m *MemoryStore) DeleteUTMTemplate(_ context.Context, userID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.utmTemplates[userID][name]; !exists {
+		return ErrNotFound
+	}
+	delete(m.utmTemplates[userID], name)
+	return nil
+}
+
+func (m *MemoryStore) SaveDomain(_ context.Context, domain model.Domain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.domains[domain.Name]; exists {
+		return ErrDomainExists
+	}
+	m.domains[domain.Name] = domain
+	return nil
+}
+
+func (m *MemoryStore) GetDomain(_ context.Context, name string) (*model.Domain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	domain, exists := m.domains[name]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &domain, nil
+}
+
+func (m *MemoryStore) ListDomains(_ context.Context, userID string) ([]model.Domain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var results []model.Domain
+	for _, domain := range m.domains {
+		if domain.UserID == userID {
+			results = append(results, domain)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+func (m *MemoryStore) DeleteDomain(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.domains[name]; !exists {
+		return ErrNotFound
+	}
+	for key := range m.data {
+		if key.domain == name {
+			return ErrDomainInUse
+		}
+	}
+	delete(m.domains, name)
+	return nil
+}
+
+func (m *MemoryStore) SaveOrganization(_ context.Context, org model.Organization, owner model.Membership) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orgs[org.ID] = org
+	m.members[org.ID] = map[string]model.Membership{owner.UserID: owner}
+	return nil
+}
+
+func (m *MemoryStore) GetOrganization(_ context.Context, id string) (*model.Organization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	org, exists := m.orgs[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &org, nil
+}
+
+func (m *MemoryStore) ListMemberships(_ context.Context, userID string) ([]model.Membership, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var results []model.Membership
+	for _, members := range m.members {
+		if member, exists := members[userID]; exists {
+			results = append(results, member)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].OrgID < results[j].OrgID
+	})
+	return results, nil
+}
+
+func (m *MemoryStore) SaveMember(_ context.Context, member model.Membership) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members, exists := m.members[member.OrgID]
+	if !exists {
+		members = make(map[string]model.Membership)
+		m.members[member.OrgID] = members
+	}
+	if existing, exists := members[member.UserID]; exists {
+		member.CreatedAt = existing.CreatedAt
+	}
+	if member.Role != model.RoleOwner && m.lastOwner(member.OrgID, member.UserID) {
+		return ErrLastOwner
+	}
+	members[member.UserID] = member
+	return nil
+}
+
+func (m *MemoryStore) GetMember(_ context.Context, orgID, userID string) (*model.Membership, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	member, exists := m.members[orgID][userID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &member, nil
+}
+
+func (m *MemoryStore) ListMembers(_ context.Context, orgID string) ([]model.Membership, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var results []model.Membership
+	for _, member := range m.members[orgID] {
+		results = append(results, member)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].UserID < results[j].UserID
+	})
+	return results, nil
+}
+
+func (m *MemoryStore) DeleteMember(_ context.Context, orgID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.members[orgID][userID]; !exists {
+		return ErrNotFound
+	}
+	if m.lastOwner(orgID, userID) {
+		return ErrLastOwner
+	}
+	delete(m.members[orgID], userID)
+	return nil
+}
+
+// lastOwner reports whether userID is the only owner of orgID. The caller
+// must hold m.mu.
+func (m *MemoryStore) lastOwner(orgID, userID string) bool {
+	if m.members[orgID][userID].Role != model.RoleOwner {
+		return false
+	}
+	for _, member := range m.members[orgID] {
+		if member.Role == model.RoleOwner && member.UserID != userID {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MemoryStore) CountActiveLinks(_ context.Context, account model.Account) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.countActiveLinks(account), nil
+}
+
+// countActiveLinks counts the account's mappings that have not expired or
+// used up their max clicks. The caller must hold m.mu.
+func (m *MemoryStore) countActiveLinks(account model.Account) int {
+	now := time.Now()
+	count := 0
+	for _, mapping := range m.data {
+		if model.AccountOf(mapping) == account && !mapping.Expired(now) && !mapping.Exhausted() {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *MemoryStore) MonthlyRedirects(_ context.Context, account model.Account, month time.Time) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.redirects[usageKey{account, monthOf(month)}], nil
+}
+
+func (m *MemoryStore) SaveQuota(_ context.Context, quota model.Quota) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quotas[quota.Account] = quota
+	return nil
+}
+
+func (m *MemoryStore) GetQuota(_ context.Context, account model.Account) (*model.Quota, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	quota, exists := m.quotas[account]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &quota, nil
+}
+
+func (m *MemoryStore) ListTags(_ context.Context, account model.Account) ([]model.TagCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, mapping := range m.data {
+		if model.AccountOf(mapping) != account {
+			continue
+		}
+		for _, tag := range mapping.Tags {
+			counts[tag]++
+		}
+	}
+	var results []model.TagCount
+	for tag, links := range counts {
+		results = append(results, model.TagCount{Tag: tag, Links: links})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Tag < results[j].Tag
+	})
+	return results, nil
+}
+
+func (m *MemoryStore) RenameTag(_ context.Context, account model.Account, from, to string) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, exists := m.data[code]; !exists {
-		return errors.New("code not found")
+	renamed := 0
+	for key, mapping := range m.data {
+		if model.AccountOf(mapping) != account || !slices.Contains(mapping.Tags, from) {
+			continue
+		}
+		// Copies handed out by Get share the slice, so it is replaced rather
+		// than written to
+		tags := slices.DeleteFunc(slices.Clone(mapping.Tags), func(tag string) bool {
+			return tag == from || tag == to
+		})
+		tags = append(tags, to)
+		slices.Sort(tags)
+		m.index.remove(key, mapping)
+		mapping.Tags = tags
+		m.data[key] = mapping
+		m.index.add(key, mapping)
+		renamed++
+	}
+	return renamed, nil
+}
+
+// SearchMappings ranks the account's mappings with the inverted index kept
+// alongside them
+func (m *MemoryStore) SearchMappings(_ context.Context, account model.Account, query string, limit int) ([]model.URLMapping, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	scores := m.index.search(terms)
+	var results []model.URLMapping
+	for key := range scores {
+		if mapping := m.data[key]; model.AccountOf(mapping) == account {
+			results = append(results, mapping)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		scoreA, scoreB := scores[mappingKey{a.Domain, a.Code}], scores[mappingKey{b.Domain, b.Code}]
+		if scoreA != scoreB {
+			return scoreA > scoreB
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
 	}
-	delete(m.data, code)
+	return results, nil
+}
+
+// Ping always succeeds, the data lives in process
+func (m *MemoryStore) Ping(_ context.Context) error {
 	return nil
 }
 
@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -27,14 +30,14 @@ func TestMemoryStore_Save(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	err := store.Save(mapping)
+	err := store.Save(context.Background(), mapping)
 
 	assert.NoError(t, err)
 	assert.Len(t, store.data, 1)
-	assert.Equal(t, mapping, store.data["abc123"])
+	assert.Equal(t, mapping, store.data[mappingKey{code: "abc123"}])
 }
 
-func TestMemoryStore_Save_Overwrite(t *testing.T) {
+func TestMemoryStore_Save_CodeExists(t *testing.T) {
 	store := NewMemoryStore()
 
 	originalMapping := model.URLMapping{
@@ -52,15 +55,19 @@ func TestMemoryStore_Save_Overwrite(t *testing.T) {
 	}
 
 	// Save original mapping
-	err := store.Save(originalMapping)
+	err := store.Save(context.Background(), originalMapping)
 	assert.NoError(t, err)
 
-	// Overwrite with new mapping
-	err = store.Save(newMapping)
-	assert.NoError(t, err)
+	// Saving the same code again must not replace the mapping
+	err = store.Save(context.Background(), newMapping)
+	assert.ErrorIs(t, err, ErrCodeExists)
 
 	assert.Len(t, store.data, 1)
-	assert.Equal(t, newMapping, store.data["abc123"])
+	assert.Equal(t, originalMapping, store.data[mappingKey{code: "abc123"}])
+
+	// The code is free on other domains
+	newMapping.Domain = "go.example.com"
+	assert.NoError(t, store.Save(context.Background(), newMapping))
 }
 
 func TestMemoryStore_Get_Success(t *testing.T) {
@@ -74,25 +81,106 @@ func TestMemoryStore_Get_Success(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	store.Save(mapping)
+	store.Save(context.Background(), mapping)
 
-	url, err := store.Get("abc123")
+	url, err := store.Get(context.Background(), "", "abc123")
 
 	assert.NoError(t, err)
 	assert.NotNil(t, url)
-	assert.Equal(t, expectedURL, *url)
+	assert.Equal(t, expectedURL, url.Original)
 }
 
 func TestMemoryStore_Get_NotFound(t *testing.T) {
 	store := NewMemoryStore()
 
-	url, err := store.Get("nonexistent")
+	url, err := store.Get(context.Background(), "", "nonexistent")
 
 	assert.Error(t, err)
 	assert.Nil(t, url)
 	assert.Equal(t, "code not found", err.Error())
 }
 
+func TestMemoryStore_Get_Expired(t *testing.T) {
+	store := NewMemoryStore()
+
+	past := time.Now().Add(-time.Hour)
+	store.Save(context.Background(), model.URLMapping{Code: "expired", Original: "https://expired.com", CreatedAt: time.Now(), ExpiresAt: &past})
+
+	url, err := store.Get(context.Background(), "", "expired")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, url)
+
+	// GetMapping still returns expired mappings
+	mapping, err := store.GetMapping(context.Background(), "", "expired")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://expired.com", mapping.Original)
+}
+
+func TestMemoryStore_Get_Pending(t *testing.T) {
+	store := NewMemoryStore()
+
+	launch := time.Now().Add(time.Hour)
+	store.Save(context.Background(), model.URLMapping{Code: "launch", Original: "https://launch.com", CreatedAt: time.Now(), ActivatesAt: &launch})
+
+	url, err := store.Get(context.Background(), "", "launch")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, url)
+
+	mapping, err := store.GetMapping(context.Background(), "", "launch")
+	assert.NoError(t, err)
+	assert.Equal(t, &launch, mapping.ActivatesAt)
+}
+
+func TestMemoryStore_Update(t *testing.T) {
+	store := NewMemoryStore()
+
+	createdAt := time.Now().Add(-time.Hour)
+	store.Save(context.Background(), model.URLMapping{Code: "abc123", Original: "https://example.com", UserID: "user1", CreatedAt: createdAt, Clicks: 3})
+
+	launch := time.Now().Add(time.Hour)
+	err := store.Update(context.Background(), model.URLMapping{Code: "abc123", Original: "https://example.com", ActivatesAt: &launch})
+	assert.NoError(t, err)
+
+	mapping, err := store.GetMapping(context.Background(), "", "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, &launch, mapping.ActivatesAt)
+	assert.Equal(t, "user1", mapping.UserID)
+	assert.Equal(t, createdAt, mapping.CreatedAt)
+	assert.Equal(t, 3, mapping.Clicks)
+
+	assert.ErrorIs(t, store.Update(context.Background(), model.URLMapping{Code: "missing"}), ErrNotFound)
+}
+
+func TestMemoryStore_GetMapping_Success(t *testing.T) {
+	store := NewMemoryStore()
+
+	mapping := model.URLMapping{
+		Code:      "abc123",
+		Original:  "https://example.com/very/long/url",
+		UserID:    "user123",
+		CreatedAt: time.Now(),
+		Clicks:    2,
+	}
+
+	store.Save(context.Background(), mapping)
+
+	result, err := store.GetMapping(context.Background(), "", "abc123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &mapping, result)
+}
+
+func TestMemoryStore_GetMapping_NotFound(t *testing.T) {
+	store := NewMemoryStore()
+
+	result, err := store.GetMapping(context.Background(), "", "nonexistent")
+
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, result)
+}
+
 func TestMemoryStore_IncrementClickCount_Success(t *testing.T) {
 	store := NewMemoryStore()
 
@@ -104,23 +192,73 @@ func TestMemoryStore_IncrementClickCount_Success(t *testing.T) {
 		Clicks:    5,
 	}
 
-	store.Save(mapping)
+	store.Save(context.Background(), mapping)
 
-	err := store.IncrementClickCount("abc123")
+	err := store.IncrementClickCount(context.Background(), "", "abc123")
 
 	assert.NoError(t, err)
-	assert.Equal(t, 6, store.data["abc123"].Clicks)
+	assert.Equal(t, 6, store.data[mappingKey{code: "abc123"}].Clicks)
 }
 
 func TestMemoryStore_IncrementClickCount_NotFound(t *testing.T) {
 	store := NewMemoryStore()
 
-	err := store.IncrementClickCount("nonexistent")
+	err := store.IncrementClickCount(context.Background(), "", "nonexistent")
 
 	assert.Error(t, err)
 	assert.Equal(t, "code not found", err.Error())
 }
 
+func TestMemoryStore_ConsumeClick(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(context.Background(), model.URLMapping{Code: "limited", CreatedAt: time.Now(), MaxClicks: 10})
+
+	// Exactly MaxClicks of many concurrent requests get through
+	var consumed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.ConsumeClick(context.Background(), "", "limited", 0)
+			if err == nil {
+				consumed.Add(1)
+			} else {
+				assert.ErrorIs(t, err, ErrClickLimitReached)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(10), consumed.Load())
+	assert.Equal(t, 10, store.data[mappingKey{code: "limited"}].Clicks)
+
+	assert.ErrorIs(t, store.ConsumeClick(context.Background(), "", "missing", 0), ErrNotFound)
+}
+
+func TestMemoryStore_IncrementVariantClickCount(t *testing.T) {
+	store := NewMemoryStore()
+	store.Save(context.Background(), model.URLMapping{Code: "split", CreatedAt: time.Now()})
+
+	before, _ := store.Get(context.Background(), "", "split")
+
+	assert.NoError(t, store.IncrementVariantClickCount(context.Background(), "", "split", "a"))
+	assert.NoError(t, store.IncrementVariantClickCount(context.Background(), "", "split", "a"))
+	assert.NoError(t, store.IncrementVariantClickCount(context.Background(), "", "split", "b"))
+
+	mapping, _ := store.GetMapping(context.Background(), "", "split")
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, mapping.VariantClicks)
+	// Mappings handed out earlier are not changed
+	assert.Nil(t, before.VariantClicks)
+
+	// Updates keep the counts
+	assert.NoError(t, store.Update(context.Background(), model.URLMapping{Code: "split"}))
+	mapping, _ = store.GetMapping(context.Background(), "", "split")
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, mapping.VariantClicks)
+
+	assert.ErrorIs(t, store.IncrementVariantClickCount(context.Background(), "", "missing", "a"), ErrNotFound)
+}
+
 func TestMemoryStore_ListByUser_Success(t *testing.T) {
 	store := NewMemoryStore()
 
@@ -145,11 +283,11 @@ func TestMemoryStore_ListByUser_Success(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	store.Save(user1Mapping1)
-	store.Save(user1Mapping2)
-	store.Save(user2Mapping)
+	store.Save(context.Background(), user1Mapping1)
+	store.Save(context.Background(), user1Mapping2)
+	store.Save(context.Background(), user2Mapping)
 
-	mappings, err := store.ListByUser("user1")
+	mappings, err := store.ListByUser(context.Background(), "user1", model.MappingFilter{})
 
 	assert.NoError(t, err)
 	assert.Len(t, mappings, 2)
@@ -163,7 +301,7 @@ func TestMemoryStore_ListByUser_Success(t *testing.T) {
 func TestMemoryStore_ListByUser_Empty(t *testing.T) {
 	store := NewMemoryStore()
 
-	mappings, err := store.ListByUser("nonexistent")
+	mappings, err := store.ListByUser(context.Background(), "nonexistent", model.MappingFilter{})
 
 	assert.NoError(t, err)
 	assert.Empty(t, mappings)
@@ -179,10 +317,10 @@ func TestMemoryStore_Delete_Success(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	store.Save(mapping)
+	store.Save(context.Background(), mapping)
 	assert.Len(t, store.data, 1)
 
-	err := store.Delete("abc123")
+	err := store.Delete(context.Background(), "", "abc123")
 
 	assert.NoError(t, err)
 	assert.Empty(t, store.data)
@@ -191,12 +329,374 @@ func TestMemoryStore_Delete_Success(t *testing.T) {
 func TestMemoryStore_Delete_NotFound(t *testing.T) {
 	store := NewMemoryStore()
 
-	err := store.Delete("nonexistent")
+	err := store.Delete(context.Background(), "", "nonexistent")
 
 	assert.Error(t, err)
 	assert.Equal(t, "code not found", err.Error())
 }
 
+func TestMemoryStore_CountActive(t *testing.T) {
+	store := NewMemoryStore()
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	store.Save(context.Background(), model.URLMapping{Code: "active", CreatedAt: time.Now()})
+	store.Save(context.Background(), model.URLMapping{Code: "future", CreatedAt: time.Now(), ExpiresAt: &future})
+	store.Save(context.Background(), model.URLMapping{Code: "expired", CreatedAt: time.Now(), ExpiresAt: &past})
+	store.Save(context.Background(), model.URLMapping{Code: "exhausted", CreatedAt: time.Now(), Clicks: 1, MaxClicks: 1})
+	store.Save(context.Background(), model.URLMapping{Code: "pending", CreatedAt: time.Now(), ActivatesAt: &future})
+
+	count, err := store.CountActive(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestMemoryStore_UTMTemplates(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	created := time.Now().Add(-time.Hour)
+
+	assert.NoError(t, store.SaveUTMTemplate(ctx, model.UTMTemplate{UserID: "user1", Name: "social", UTM: model.UTM{Source: "twitter"}, CreatedAt: created}))
+	assert.NoError(t, store.SaveUTMTemplate(ctx, model.UTMTemplate{UserID: "user1", Name: "email", UTM: model.UTM{Source: "newsletter"}, CreatedAt: created}))
+	assert.NoError(t, store.SaveUTMTemplate(ctx, model.UTMTemplate{UserID: "user2", Name: "email", UTM: model.UTM{Source: "other"}, CreatedAt: created}))
+
+	// Replacing a template keeps its creation time
+	assert.NoError(t, store.SaveUTMTemplate(ctx, model.UTMTemplate{UserID: "user1", Name: "social", UTM: model.UTM{Source: "mastodon"}, CreatedAt: time.Now()}))
+
+	template, err := store.GetUTMTemplate(ctx, "user1", "social")
+	assert.NoError(t, err)
+	assert.Equal(t, "mastodon", template.UTM.Source)
+	assert.Equal(t, created, template.CreatedAt)
+
+	templates, err := store.ListUTMTemplates(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Len(t, templates, 2)
+	assert.Equal(t, "email", templates[0].Name)
+	assert.Equal(t, "social", templates[1].Name)
+
+	assert.NoError(t, store.DeleteUTMTemplate(ctx, "user1", "email"))
+	assert.ErrorIs(t, store.DeleteUTMTemplate(ctx, "user1", "email"), ErrNotFound)
+
+	_, err = store.GetUTMTemplate(ctx, "user1", "email")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Other users' templates are untouched
+	template, err = store.GetUTMTemplate(ctx, "user2", "email")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", template.UTM.Source)
+}
+
+func TestMemoryStore_Domains(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	assert.NoError(t, store.SaveDomain(ctx, model.Domain{Name: "go.example.com", UserID: "user1", CreatedAt: time.Now()}))
+	assert.NoError(t, store.SaveDomain(ctx, model.Domain{Name: "a.example.com", UserID: "user1", CreatedAt: time.Now()}))
+	assert.ErrorIs(t, store.SaveDomain(ctx, model.Domain{Name: "go.example.com", UserID: "user2"}), ErrDomainExists)
+
+	domain, err := store.GetDomain(ctx, "go.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", domain.UserID)
+	_, err = store.GetDomain(ctx, "missing.example.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	domains, err := store.ListDomains(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Len(t, domains, 2)
+	assert.Equal(t, "a.example.com", domains[0].Name)
+
+	// Codes are unique per domain
+	store.Save(ctx, model.URLMapping{Domain: "go.example.com", Code: "abc123", Original: "https://example.com/branded"})
+	store.Save(ctx, model.URLMapping{Code: "abc123", Original: "https://example.com/default"})
+	mapping, err := store.Get(ctx, "go.example.com", "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/branded", mapping.Original)
+	mapping, err = store.Get(ctx, "", "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/default", mapping.Original)
+	_, err = store.Get(ctx, "a.example.com", "abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, store.DeleteDomain(ctx, "go.example.com"), ErrDomainInUse)
+	assert.NoError(t, store.Delete(ctx, "go.example.com", "abc123"))
+	assert.NoError(t, store.DeleteDomain(ctx, "go.example.com"))
+	assert.ErrorIs(t, store.DeleteDomain(ctx, "go.example.com"), ErrNotFound)
+
+	// The default domain's mapping is untouched
+	_, err = store.Get(ctx, "", "abc123")
+	assert.NoError(t, err)
+}
+
+func TestMemoryStore_Organizations(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	joined := time.Now().Add(-time.Hour)
+	assert.NoError(t, store.SaveOrganization(ctx, model.Organization{ID: "org1", Name: "Acme"}, model.Membership{OrgID: "org1", UserID: "alice", Role: model.RoleOwner, CreatedAt: joined}))
+	assert.NoError(t, store.SaveOrganization(ctx, model.Organization{ID: "org0", Name: "Other"}, model.Membership{OrgID: "org0", UserID: "alice", Role: model.RoleOwner}))
+
+	org, err := store.GetOrganization(ctx, "org1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme", org.Name)
+	_, err = store.GetOrganization(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.SaveMember(ctx, model.Membership{OrgID: "org1", UserID: "bob", Role: model.RoleViewer, CreatedAt: joined}))
+	// Changing a role keeps when the member joined
+	assert.NoError(t, store.SaveMember(ctx, model.Membership{OrgID: "org1", UserID: "bob", Role: model.RoleEditor, CreatedAt: time.Now()}))
+	member, err := store.GetMember(ctx, "org1", "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, model.RoleEditor, member.Role)
+	assert.Equal(t, joined, member.CreatedAt)
+
+	members, err := store.ListMembers(ctx, "org1")
+	assert.NoError(t, err)
+	assert.Len(t, members, 2)
+	assert.Equal(t, "alice", members[0].UserID)
+
+	memberships, err := store.ListMemberships(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, memberships, 2)
+	assert.Equal(t, "org0", memberships[0].OrgID)
+
+	assert.NoError(t, store.DeleteMember(ctx, "org1", "bob"))
+	assert.ErrorIs(t, store.DeleteMember(ctx, "org1", "bob"), ErrNotFound)
+
+	// The only owner can neither be demoted nor removed
+	assert.ErrorIs(t, store.SaveMember(ctx, model.Membership{OrgID: "org1", UserID: "alice", Role: model.RoleAdmin}), ErrLastOwner)
+	assert.ErrorIs(t, store.DeleteMember(ctx, "org1", "alice"), ErrLastOwner)
+	assert.NoError(t, store.SaveMember(ctx, model.Membership{OrgID: "org1", UserID: "alice", Role: model.RoleOwner}))
+
+	// Of two owners leaving at once, one stays
+	assert.NoError(t, store.SaveOrganization(ctx, model.Organization{ID: "org2"}, model.Membership{OrgID: "org2", UserID: "alice", Role: model.RoleOwner}))
+	assert.NoError(t, store.SaveMember(ctx, model.Membership{OrgID: "org2", UserID: "carol", Role: model.RoleOwner}))
+	var left atomic.Int32
+	var wg sync.WaitGroup
+	for _, userID := range []string{"alice", "carol"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.DeleteMember(ctx, "org2", userID) == nil {
+				left.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), left.Load())
+	_, err = store.GetMember(ctx, "org1", "bob")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Organization links are listed apart from personal ones
+	store.Save(ctx, model.URLMapping{Code: "team", UserID: "alice", OrgID: "org1"})
+	store.Save(ctx, model.URLMapping{Code: "mine", UserID: "alice"})
+	personal, err := store.ListByUser(ctx, "alice", model.MappingFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, personal, 1)
+	assert.Equal(t, "mine", personal[0].Code)
+	shared, err := store.ListByOrg(ctx, "org1", model.MappingFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, shared, 1)
+	assert.Equal(t, "team", shared[0].Code)
+}
+
+func TestMemoryStore_Quotas(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	alice := model.Account{UserID: "alice"}
+	org := model.Account{OrgID: "org1"}
+
+	_, err := store.GetQuota(ctx, alice)
+	assert.ErrorIs(t, err, ErrNotFound)
+	links := 10
+	assert.NoError(t, store.SaveQuota(ctx, model.Quota{Account: alice, ActiveLinks: &links}))
+	quota, err := store.GetQuota(ctx, alice)
+	assert.NoError(t, err)
+	assert.Equal(t, &links, quota.ActiveLinks)
+
+	expired := time.Now().Add(-time.Hour)
+	scheduled := time.Now().Add(time.Hour)
+	store.Save(ctx, model.URLMapping{Code: "live", UserID: "alice"})
+	store.Save(ctx, model.URLMapping{Code: "later", UserID: "alice", ActivatesAt: &scheduled})
+	store.Save(ctx, model.URLMapping{Code: "gone", UserID: "alice", ExpiresAt: &expired})
+	store.Save(ctx, model.URLMapping{Code: "used", UserID: "alice", MaxClicks: 1, Clicks: 1})
+	store.Save(ctx, model.URLMapping{Code: "team", UserID: "alice", OrgID: "org1"})
+
+	// Scheduled links count, expired and used up ones do not
+	count, err := store.CountActiveLinks(ctx, alice)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = store.CountActiveLinks(ctx, org)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, store.IncrementClickCount(ctx, "", "live"))
+	assert.NoError(t, store.ConsumeClick(ctx, "", "live", 0))
+	assert.NoError(t, store.IncrementClickCount(ctx, "", "team"))
+	assert.ErrorIs(t, store.ConsumeClick(ctx, "", "used", 0), ErrClickLimitReached)
+
+	redirects, err := store.MonthlyRedirects(ctx, alice, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, redirects)
+	redirects, err = store.MonthlyRedirects(ctx, org, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, redirects)
+	redirects, err = store.MonthlyRedirects(ctx, alice, time.Now().AddDate(0, 0, -40))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, redirects)
+
+	// At the redirect limit, clicks are refused and not counted
+	assert.ErrorIs(t, store.ConsumeClick(ctx, "", "live", 2), ErrRedirectLimitReached)
+	assert.NoError(t, store.ConsumeClick(ctx, "", "live", 3))
+	live, err := store.GetMapping(ctx, "", "live")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, live.Clicks)
+}
+
+func TestMemoryStore_SaveWithinLimit(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	// Exactly maxActiveLinks of many concurrent creates get through
+	var saved atomic.Int32
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.SaveWithinLimit(ctx, model.URLMapping{Code: fmt.Sprintf("code%d", i), UserID: "alice"}, 5)
+			if err == nil {
+				saved.Add(1)
+			} else {
+				assert.ErrorIs(t, err, ErrActiveLinksLimitReached)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), saved.Load())
+
+	// The limit is per account, and codes stay unique across accounts
+	assert.NoError(t, store.SaveWithinLimit(ctx, model.URLMapping{Code: "bob", UserID: "bob"}, 5))
+	assert.ErrorIs(t, store.SaveWithinLimit(ctx, model.URLMapping{Code: "bob", UserID: "carol"}, 5), ErrCodeExists)
+}
+
+func TestMemoryStore_Tags(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	alice := model.Account{UserID: "alice"}
+
+	store.Save(ctx, model.URLMapping{Code: "deck", UserID: "alice", Tags: []string{"q3", "slides"}, Folder: "Sales"})
+	store.Save(ctx, model.URLMapping{Code: "memo", UserID: "alice", Tags: []string{"q3"}})
+	store.Save(ctx, model.URLMapping{Code: "team", UserID: "alice", OrgID: "org1", Tags: []string{"q3"}})
+
+	mappings, err := store.ListByUser(ctx, "alice", model.MappingFilter{Tag: "q3"})
+	assert.NoError(t, err)
+	assert.Len(t, mappings, 2)
+	mappings, err = store.ListByUser(ctx, "alice", model.MappingFilter{Tag: "q3", Folder: "Sales"})
+	assert.NoError(t, err)
+	assert.Len(t, mappings, 1)
+	assert.Equal(t, "deck", mappings[0].Code)
+	mappings, err = store.ListByOrg(ctx, "org1", model.MappingFilter{Tag: "slides"})
+	assert.NoError(t, err)
+	assert.Empty(t, mappings)
+
+	tags, err := store.ListTags(ctx, alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TagCount{{Tag: "q3", Links: 2}, {Tag: "slides", Links: 1}}, tags)
+
+	// Renaming onto a tag a mapping already carries merges the two
+	deck, _ := store.GetMapping(ctx, "", "deck")
+	renamed, err := store.RenameTag(ctx, alice, "q3", "slides")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, renamed)
+	tags, err = store.ListTags(ctx, alice)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TagCount{{Tag: "slides", Links: 2}}, tags)
+	assert.Equal(t, []string{"q3", "slides"}, deck.Tags, "copies handed out before must not change")
+
+	// Other accounts keep their tags
+	tags, err = store.ListTags(ctx, model.Account{OrgID: "org1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TagCount{{Tag: "q3", Links: 1}}, tags)
+}
+
+func TestMemoryStore_Search(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	alice := model.Account{UserID: "alice"}
+	now := time.Now()
+
+	store.Save(ctx, model.URLMapping{Code: "deck", Original: "https://example.com/launch/deck.pdf", UserID: "alice", CreatedAt: now})
+	store.Save(ctx, model.URLMapping{Code: "memo", Original: "https://example.com/memo", UserID: "alice", Title: "Launch memo", CreatedAt: now.Add(-time.Hour)})
+	store.Save(ctx, model.URLMapping{Code: "notes", Original: "https://example.com/notes", UserID: "alice", Tags: []string{"launch"}, CreatedAt: now.Add(-2 * time.Hour)})
+	store.Save(ctx, model.URLMapping{Code: "team", Original: "https://example.com/launch", UserID: "alice", OrgID: "org1", CreatedAt: now})
+
+	codes := func(query string, limit int) []string {
+		t.Helper()
+		mappings, err := store.SearchMappings(ctx, alice, query, limit)
+		assert.NoError(t, err)
+		codes := make([]string, len(mappings))
+		for i, mapping := range mappings {
+			codes[i] = mapping.Code
+		}
+		return codes
+	}
+
+	// Titles rank above tags, which rank above destinations
+	assert.Equal(t, []string{"memo", "notes", "deck"}, codes("launch", 0))
+	assert.Equal(t, []string{"memo", "notes"}, codes("LAUNCH", 2))
+	assert.Equal(t, []string{"memo", "notes", "deck"}, codes("laun", 0), "words match as prefixes")
+	assert.Equal(t, []string{"deck"}, codes("launch pdf", 0), "every word must match")
+	assert.Empty(t, codes("missing", 0))
+	assert.Empty(t, codes("...", 0))
+
+	mappings, err := store.SearchMappings(ctx, model.Account{OrgID: "org1"}, "launch", 0)
+	assert.NoError(t, err)
+	assert.Len(t, mappings, 1)
+
+	// The index follows updates, renamed tags and deletes
+	memo, _ := store.GetMapping(ctx, "", "memo")
+	memo.Title = "Quarterly memo"
+	assert.NoError(t, store.Update(ctx, *memo))
+	assert.Equal(t, []string{"memo"}, codes("quarterly", 0))
+	assert.Equal(t, []string{"notes", "deck"}, codes("launch", 0))
+
+	_, err = store.RenameTag(ctx, alice, "launch", "release")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"notes"}, codes("release", 0))
+	assert.Equal(t, []string{"deck"}, codes("launch", 0))
+
+	assert.NoError(t, store.Delete(ctx, "", "deck"))
+	assert.Empty(t, codes("launch", 0))
+}
+
+func TestMemoryStore_SaveMetadata(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	metadata := model.Metadata{Title: "Launch", FetchedAt: time.Now()}
+
+	store.Save(ctx, model.URLMapping{Code: "deck", Original: "https://example.com/deck", UserID: "alice"})
+
+	assert.NoError(t, store.SaveMetadata(ctx, "", "deck", "https://example.com/deck", metadata))
+	mapping, _ := store.GetMapping(ctx, "", "deck")
+	assert.Equal(t, &metadata, mapping.Metadata)
+
+	// Updates keep it, and metadata for another destination is dropped
+	mapping.Title = "Deck"
+	mapping.Metadata = nil
+	assert.NoError(t, store.Update(ctx, *mapping))
+	assert.ErrorIs(t, store.SaveMetadata(ctx, "", "deck", "https://example.com/old", model.Metadata{Title: "Old"}), ErrNotFound)
+	mapping, _ = store.GetMapping(ctx, "", "deck")
+	assert.Equal(t, &metadata, mapping.Metadata)
+
+	assert.ErrorIs(t, store.SaveMetadata(ctx, "", "missing", "https://example.com", metadata), ErrNotFound)
+}
+
+func TestMemoryStore_Ping(t *testing.T) {
+	assert.NoError(t, NewMemoryStore().Ping(context.Background()))
+}
+
 func TestMemoryStore_ConcurrentAccess(t *testing.T) {
 	store := NewMemoryStore()
 
@@ -210,7 +710,7 @@ func TestMemoryStore_ConcurrentAccess(t *testing.T) {
 				UserID:    fmt.Sprintf("user%d", id),
 				CreatedAt: time.Now(),
 			}
-			store.Save(mapping)
+			store.Save(context.Background(), mapping)
 			done <- true
 		}(i)
 	}
@@ -233,7 +733,7 @@ func TestMemoryStore_ConcurrentReadWrite(t *testing.T) {
 		UserID:    "user123",
 		CreatedAt: time.Now(),
 	}
-	store.Save(mapping)
+	store.Save(context.Background(), mapping)
 
 	// Test concurrent read and write operations
 	done := make(chan bool, 20)
@@ -241,7 +741,7 @@ func TestMemoryStore_ConcurrentReadWrite(t *testing.T) {
 	// Start 10 readers
 	for i := 0; i < 10; i++ {
 		go func() {
-			url, err := store.Get("abc123")
+			url, err := store.Get(context.Background(), "", "abc123")
 			assert.NoError(t, err)
 			assert.NotNil(t, url)
 			done <- true
@@ -251,7 +751,7 @@ func TestMemoryStore_ConcurrentReadWrite(t *testing.T) {
 	// Start 10 writers (incrementing click count)
 	for i := 0; i < 10; i++ {
 		go func() {
-			err := store.IncrementClickCount("abc123")
+			err := store.IncrementClickCount(context.Background(), "", "abc123")
 			assert.NoError(t, err)
 			done <- true
 		}()
@@ -263,5 +763,5 @@ func TestMemoryStore_ConcurrentReadWrite(t *testing.T) {
 	}
 
 	// Verify the final state
-	assert.Equal(t, 10, store.data["abc123"].Clicks)
+	assert.Equal(t, 10, store.data[mappingKey{code: "abc123"}].Clicks)
 }
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+const migrationsDir = "migrations"
+
+// withMigrationDB opens a database/sql handle configured for goose and closes it once fn returns
+func withMigrationDB(connString string, fn func(db *sql.DB) error) error {
+	cfg, err := pgx.ParseConfig(connString)
+	if err != nil {
+		return fmt.Errorf("failed to parse connection string: %w", err)
+	}
+	db := stdlib.OpenDB(*cfg)
+	defer db.Close()
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return err
+	}
+	goose.SetBaseFS(migrationsFS)
+
+	return fn(db)
+}
+
+// MigrateUp applies all pending migrations
+func MigrateUp(connString string) error {
+	return withMigrationDB(connString, func(db *sql.DB) error {
+		return goose.Up(db, migrationsDir)
+	})
+}
+
+// MigrateDown rolls back the most recently applied migration
+func MigrateDown(connString string) error {
+	return withMigrationDB(connString, func(db *sql.DB) error {
+		return goose.Down(db, migrationsDir)
+	})
+}
+
+// MigrateTo migrates up or down until the schema is at the given version
+func MigrateTo(connString string, version int64) error {
+	return withMigrationDB(connString, func(db *sql.DB) error {
+		current, err := goose.GetDBVersion(db)
+		if err != nil {
+			return err
+		}
+		if version < current {
+			return goose.DownTo(db, migrationsDir, version)
+		}
+		return goose.UpTo(db, migrationsDir, version)
+	})
+}
+
+// MigrationStatus prints the applied/pending state of every migration through goose's logger
+func MigrationStatus(connString string) error {
+	return withMigrationDB(connString, func(db *sql.DB) error {
+		return goose.Status(db, migrationsDir)
+	})
+}
+
+// ResetPostgresStore rolls back every applied migration, leaving an empty schema
+func ResetPostgresStore(connString string) error {
+	return withMigrationDB(connString, func(db *sql.DB) error {
+		return goose.DownTo(db, migrationsDir, 0)
+	})
+}
@@ -0,0 +1,8 @@
+-- +goose Up
+-- NULL means the link follows the server's default redirect type
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS redirect_type SMALLINT
+    CHECK (redirect_type IN (301, 302, 307, 308));
+
+-- +goose Down
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS redirect_type;
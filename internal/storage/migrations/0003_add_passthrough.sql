@@ -0,0 +1,11 @@
+-- +goose Up
+-- NULL drops the query string of requests for the link
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS query_passthrough VARCHAR(16)
+    CHECK (query_passthrough IN ('merge', 'override', 'append')),
+    ADD COLUMN IF NOT EXISTS path_passthrough BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE url_mappings
+    DROP COLUMN IF EXISTS path_passthrough,
+    DROP COLUMN IF EXISTS query_passthrough;
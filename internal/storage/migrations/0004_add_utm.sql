@@ -0,0 +1,31 @@
+-- +goose Up
+-- Campaign parameters are kept next to the composed original_url so they can
+-- be reported without parsing it
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS utm_source TEXT,
+    ADD COLUMN IF NOT EXISTS utm_medium TEXT,
+    ADD COLUMN IF NOT EXISTS utm_campaign TEXT,
+    ADD COLUMN IF NOT EXISTS utm_term TEXT,
+    ADD COLUMN IF NOT EXISTS utm_content TEXT;
+
+CREATE TABLE IF NOT EXISTS utm_templates (
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    utm_source TEXT,
+    utm_medium TEXT,
+    utm_campaign TEXT,
+    utm_term TEXT,
+    utm_content TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, name)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS utm_templates;
+
+ALTER TABLE url_mappings
+    DROP COLUMN IF EXISTS utm_content,
+    DROP COLUMN IF EXISTS utm_term,
+    DROP COLUMN IF EXISTS utm_campaign,
+    DROP COLUMN IF EXISTS utm_medium,
+    DROP COLUMN IF EXISTS utm_source;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- bcrypt hash of the link's password, NULL for public links
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS password_hash TEXT;
+
+-- +goose Down
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS password_hash;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- NULL means the link may be followed any number of times
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS max_clicks INTEGER
+    CHECK (max_clicks > 0);
+
+-- +goose Down
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS max_clicks;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- NULL means the link is active as soon as it is created
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS activates_at TIMESTAMPTZ,
+    ADD CONSTRAINT url_mappings_activation_window
+    CHECK (activates_at IS NULL OR expires_at IS NULL OR activates_at < expires_at);
+
+-- +goose Down
+ALTER TABLE url_mappings DROP CONSTRAINT IF EXISTS url_mappings_activation_window;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS activates_at;
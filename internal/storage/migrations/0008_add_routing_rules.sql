@@ -0,0 +1,6 @@
+-- +goose Up
+-- NULL means every request goes to original_url
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS routing_rules JSONB;
+
+-- +goose Down
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS routing_rules;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- NULL variants means every request goes to original_url. variant_clicks
+-- maps variant names to the clicks they served.
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS variants JSONB,
+    ADD COLUMN IF NOT EXISTS sticky_variants BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS variant_clicks JSONB NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE url_mappings
+    DROP COLUMN IF EXISTS variants,
+    DROP COLUMN IF EXISTS sticky_variants,
+    DROP COLUMN IF EXISTS variant_clicks;
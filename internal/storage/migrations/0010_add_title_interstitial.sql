@@ -0,0 +1,11 @@
+-- +goose Up
+-- title is shown on the link's preview page. interstitial links serve that
+-- page instead of redirecting.
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE url_mappings
+    DROP COLUMN IF EXISTS title,
+    DROP COLUMN IF EXISTS interstitial;
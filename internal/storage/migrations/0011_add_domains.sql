@@ -0,0 +1,24 @@
+-- +goose Up
+-- Codes become unique per domain. Mappings on the default domain have an
+-- empty domain, so they need no row in domains.
+CREATE TABLE IF NOT EXISTS domains (
+    name VARCHAR(253) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_domains_user_id ON domains(user_id);
+
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE url_mappings DROP CONSTRAINT IF EXISTS url_mappings_pkey;
+ALTER TABLE url_mappings ADD CONSTRAINT url_mappings_pkey PRIMARY KEY (domain, code);
+
+-- +goose Down
+-- Codes of other domains may clash with the default domain's, so their
+-- mappings cannot be kept
+ALTER TABLE url_mappings DROP CONSTRAINT IF EXISTS url_mappings_pkey;
+DELETE FROM url_mappings WHERE domain <> '';
+ALTER TABLE url_mappings ADD CONSTRAINT url_mappings_pkey PRIMARY KEY (code);
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS domain;
+
+DROP INDEX IF EXISTS idx_domains_user_id;
+DROP TABLE IF EXISTS domains;
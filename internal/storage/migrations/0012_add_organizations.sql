@@ -0,0 +1,29 @@
+-- +goose Up
+-- Mappings of an organization keep the user who created them in user_id.
+-- Personal mappings have an empty org_id.
+CREATE TABLE IF NOT EXISTS organizations (
+    id VARCHAR(32) PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS org_members (
+    org_id VARCHAR(32) NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'admin', 'editor', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_org_members_user_id ON org_members(user_id);
+
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS org_id VARCHAR(32) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_url_mappings_org_id ON url_mappings(org_id) WHERE org_id <> '';
+
+-- +goose Down
+-- Links of organizations fall back to the users who created them
+DROP INDEX IF EXISTS idx_url_mappings_org_id;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS org_id;
+
+DROP INDEX IF EXISTS idx_org_members_user_id;
+DROP TABLE IF EXISTS org_members;
+DROP TABLE IF EXISTS organizations;
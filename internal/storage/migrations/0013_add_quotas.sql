@@ -0,0 +1,23 @@
+-- +goose Up
+-- Accounts are either an organization, with an empty user_id, or a user's
+-- personal links, with an empty org_id.
+CREATE TABLE IF NOT EXISTS quotas (
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    org_id VARCHAR(32) NOT NULL DEFAULT '',
+    -- NULL limits fall back to the configured defaults, 0 means no limit
+    active_links INTEGER CHECK (active_links >= 0),
+    monthly_redirects INTEGER CHECK (monthly_redirects >= 0),
+    PRIMARY KEY (user_id, org_id)
+);
+
+CREATE TABLE IF NOT EXISTS monthly_redirects (
+    user_id VARCHAR(255) NOT NULL DEFAULT '',
+    org_id VARCHAR(32) NOT NULL DEFAULT '',
+    month DATE NOT NULL,
+    redirects BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, org_id, month)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS monthly_redirects;
+DROP TABLE IF EXISTS quotas;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS folder VARCHAR(100) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_url_mappings_folder ON url_mappings(folder) WHERE folder <> '';
+
+-- Tags are kept per mapping and go with it when it is deleted
+CREATE TABLE IF NOT EXISTS mapping_tags (
+    domain VARCHAR(253) NOT NULL,
+    code VARCHAR(255) NOT NULL,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (domain, code, tag),
+    FOREIGN KEY (domain, code) REFERENCES url_mappings(domain, code) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_mapping_tags_tag ON mapping_tags(tag);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_mapping_tags_tag;
+DROP TABLE IF EXISTS mapping_tags;
+
+DROP INDEX IF EXISTS idx_url_mappings_folder;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS folder;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- pg_trgm backs substring and similarity matching of titles and destinations
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- search_vector holds the code and title (weight A), tags (B) and
+-- destination (C) of a mapping. The store keeps it up to date, since tags
+-- live in mapping_tags.
+ALTER TABLE url_mappings ADD COLUMN IF NOT EXISTS search_vector tsvector NOT NULL DEFAULT ''::tsvector;
+
+UPDATE url_mappings SET search_vector =
+    setweight(to_tsvector('simple', regexp_replace(url_mappings.code || ' ' || url_mappings.title, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+    setweight(to_tsvector('simple', regexp_replace(COALESCE((
+        SELECT string_agg(t.tag, ' ') FROM mapping_tags t
+        WHERE t.domain = url_mappings.domain AND t.code = url_mappings.code
+    ), ''), '[^[:alnum:]]+', ' ', 'g')), 'B') ||
+    setweight(to_tsvector('simple', regexp_replace(url_mappings.original_url, '[^[:alnum:]]+', ' ', 'g')), 'C');
+
+CREATE INDEX IF NOT EXISTS idx_url_mappings_search ON url_mappings USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_url_mappings_title_trgm ON url_mappings USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_url_mappings_original_url_trgm ON url_mappings USING GIN (original_url gin_trgm_ops);
+
+-- +goose Down
+-- pg_trgm is left installed, other schemas may use it
+DROP INDEX IF EXISTS idx_url_mappings_original_url_trgm;
+DROP INDEX IF EXISTS idx_url_mappings_title_trgm;
+DROP INDEX IF EXISTS idx_url_mappings_search;
+ALTER TABLE url_mappings DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- metadata holds the title, description and image read from the page a link
+-- points to, filled in after the link is created.
+ALTER TABLE url_mappings
+    ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+-- +goose Down
+ALTER TABLE url_mappings
+    DROP COLUMN IF EXISTS metadata;
@@ -3,32 +3,57 @@ package storage
 import (
 	"context"
 	"database/sql"
-	"embed"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
 	"github.com/wiredmatt/go_short/internal/model"
 )
 
-//go:embed migrations/*.sql
-var migrationsFS embed.FS
+// mappingColumns lists the url_mappings columns in the order scanMapping reads them
+const mappingColumns = "domain, code, original_url, user_id, created_at, expires_at, activates_at, clicks, max_clicks, redirect_type, query_passthrough, path_passthrough, " +
+	"utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, routing_rules, " +
+	"variants, sticky_variants, variant_clicks, title, interstitial, org_id, folder, metadata"
+
+// mappingTags selects the tags of the url_mappings row, which scanMapping
+// reads after mappingColumns
+const mappingTags = "ARRAY(SELECT tag FROM mapping_tags t WHERE t.domain = url_mappings.domain AND t.code = url_mappings.code ORDER BY tag)"
+
+// utmTemplateColumns lists the utm_templates columns in the order scanUTMTemplate reads them
+const utmTemplateColumns = "user_id, name, utm_source, utm_medium, utm_campaign, utm_term, utm_content, created_at"
+
+// domainColumns lists the domains columns in the order scanDomain reads them
+const domainColumns = "name, user_id, created_at"
+
+// memberColumns lists the org_members columns in the order scanMember reads them
+const memberColumns = "org_id, user_id, role, created_at"
 
 type PostgresStore struct {
 	pool *pgxpool.Pool
 }
 
+// NewPostgresStore applies pending migrations and opens a connection pool
 func NewPostgresStore(ctx context.Context, connString string) (*PostgresStore, error) {
 	// Apply migrations before initializing the pool
-	if err := runMigrations(connString); err != nil {
+	if err := MigrateUp(connString); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	pool, err := pgxpool.New(ctx, connString)
+	return OpenPostgresStore(ctx, connString)
+}
+
+// OpenPostgresStore opens a connection pool without touching the schema
+func OpenPostgresStore(ctx context.Context, connString string) (*PostgresStore, error) {
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+	poolConfig.ConnConfig.Tracer = newQueryTracer()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
@@ -38,105 +63,399 @@ func NewPostgresStore(ctx context.Context, connString string) (*PostgresStore, e
 	return store, nil
 }
 
-// runMigrations applies database migrations using Goose
-func runMigrations(connString string) error {
-	cfg, err := pgx.ParseConfig(connString)
-	if err != nil {
-		return fmt.Errorf("failed to parse connection string: %w", err)
-	}
-	db := stdlib.OpenDB(*cfg)
-	defer db.Close()
+// Save stores a new URL mapping along with its tags, or returns
+// ErrCodeExists if its code is taken on its domain
+func (p *PostgresStore) Save(ctx context.Context, mapping model.URLMapping) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return err
-	}
-	goose.SetBaseFS(migrationsFS)
-	if err := goose.Up(db, "migrations"); err != nil {
-		return err
-	}
-	return nil
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		return insertMapping(ctx, tx, mapping)
+	})
 }
 
-// Save stores a new URL mapping
-func (p *PostgresStore) Save(mapping model.URLMapping) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// SaveWithinLimit stores a new URL mapping like Save unless its account
+// already has maxActiveLinks active mappings. Saves for the same account
+// take turns on an advisory lock, so they cannot all see room for one more.
+func (p *PostgresStore) SaveWithinLimit(ctx context.Context, mapping model.URLMapping, maxActiveLinks int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
+	account := model.AccountOf(mapping)
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		lock := `SELECT pg_advisory_xact_lock(hashtextextended($1 || '/' || $2, 0))`
+		if _, err := tx.Exec(ctx, lock, account.UserID, account.OrgID); err != nil {
+			return err
+		}
+
+		var active int
+		if err := tx.QueryRow(ctx, countActiveLinks, account.UserID, account.OrgID).Scan(&active); err != nil {
+			return err
+		}
+		if active >= maxActiveLinks {
+			return ErrActiveLinksLimitReached
+		}
+
+		return insertMapping(ctx, tx, mapping)
+	})
+}
+
+// insertMapping inserts mapping and its tags within tx, or returns
+// ErrCodeExists if its code is taken on its domain
+func insertMapping(ctx context.Context, tx pgx.Tx, mapping model.URLMapping) error {
 	query := `
-		INSERT INTO url_mappings (code, original_url, user_id, created_at, expires_at, clicks)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO url_mappings (` + mappingColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
+		ON CONFLICT (domain, code) DO NOTHING
 	`
 
-	_, err := p.pool.Exec(ctx, query,
+	result, err := tx.Exec(ctx, query,
+		mapping.Domain,
 		mapping.Code,
 		mapping.Original,
 		mapping.UserID,
 		mapping.CreatedAt,
 		mapping.ExpiresAt,
+		mapping.ActivatesAt,
 		mapping.Clicks,
+		nullableInt(mapping.MaxClicks),
+		nullableInt(mapping.RedirectType),
+		nullableString(mapping.QueryPassthrough),
+		mapping.PathPassthrough,
+		nullableString(mapping.UTM.Source),
+		nullableString(mapping.UTM.Medium),
+		nullableString(mapping.UTM.Campaign),
+		nullableString(mapping.UTM.Term),
+		nullableString(mapping.UTM.Content),
+		nullableString(mapping.PasswordHash),
+		nullableList(mapping.Rules),
+		nullableList(mapping.Variants),
+		mapping.StickyVariants,
+		variantClicksJSON(mapping.VariantClicks),
+		mapping.Title,
+		mapping.Interstitial,
+		mapping.OrgID,
+		mapping.Folder,
+		nullableMetadata(mapping.Metadata),
 	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrCodeExists
+	}
+
+	return setTags(ctx, tx, mapping.Domain, mapping.Code, mapping.Tags)
+}
+
+// Get retrieves the mapping for a given domain and code if it has not expired and is active
+func (p *PostgresStore) Get(ctx context.Context, domain, code string) (*model.URLMapping, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + mappingColumns + `, ` + mappingTags + ` FROM url_mappings
+		WHERE domain = $1 AND code = $2 AND (expires_at IS NULL OR expires_at > NOW())
+		AND (activates_at IS NULL OR activates_at <= NOW())
+	`
+
+	mapping, err := scanMapping(p.pool.QueryRow(ctx, query, domain, code))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return mapping, err
+}
+
+// GetMapping retrieves the full URL mapping for a given domain and code, including expired ones
+func (p *PostgresStore) GetMapping(ctx context.Context, domain, code string) (*model.URLMapping, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + mappingColumns + `, ` + mappingTags + `
+		FROM url_mappings
+		WHERE domain = $1 AND code = $2
+	`
+
+	mapping, err := scanMapping(p.pool.QueryRow(ctx, query, domain, code))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return mapping, err
+}
+
+// Update replaces the settings and tags of an existing mapping, keeping its
+// owner, creation time and click count
+func (p *PostgresStore) Update(ctx context.Context, mapping model.URLMapping) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE url_mappings SET
+			original_url = $2, expires_at = $3, activates_at = $4, max_clicks = $5,
+			redirect_type = $6, query_passthrough = $7, path_passthrough = $8,
+			utm_source = $9, utm_medium = $10, utm_campaign = $11, utm_term = $12, utm_content = $13,
+			password_hash = $14, routing_rules = $15, variants = $16, sticky_variants = $17,
+			title = $18, interstitial = $19, folder = $21
+		WHERE code = $1 AND domain = $20
+	`
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, query,
+			mapping.Code,
+			mapping.Original,
+			mapping.ExpiresAt,
+			mapping.ActivatesAt,
+			nullableInt(mapping.MaxClicks),
+			nullableInt(mapping.RedirectType),
+			nullableString(mapping.QueryPassthrough),
+			mapping.PathPassthrough,
+			nullableString(mapping.UTM.Source),
+			nullableString(mapping.UTM.Medium),
+			nullableString(mapping.UTM.Campaign),
+			nullableString(mapping.UTM.Term),
+			nullableString(mapping.UTM.Content),
+			nullableString(mapping.PasswordHash),
+			nullableList(mapping.Rules),
+			nullableList(mapping.Variants),
+			mapping.StickyVariants,
+			mapping.Title,
+			mapping.Interstitial,
+			mapping.Domain,
+			mapping.Folder,
+		)
+		if err != nil {
+			return err
+		}
+
+		if result.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		return setTags(ctx, tx, mapping.Domain, mapping.Code, mapping.Tags)
+	})
+}
+
+// searchVector computes the search_vector of a url_mappings row from its
+// code and title (A), tags (B) and destination (C). Text is split into runs
+// of letters and digits first, as searchTerms does. It is kept up to date by
+// the store rather than generated, since tags live in their own table.
+const searchVector = `
+	setweight(to_tsvector('simple', regexp_replace(url_mappings.code || ' ' || url_mappings.title, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+	setweight(to_tsvector('simple', regexp_replace(COALESCE((
+		SELECT string_agg(t.tag, ' ') FROM mapping_tags t
+		WHERE t.domain = url_mappings.domain AND t.code = url_mappings.code
+	), ''), '[^[:alnum:]]+', ' ', 'g')), 'B') ||
+	setweight(to_tsvector('simple', regexp_replace(url_mappings.original_url, '[^[:alnum:]]+', ' ', 'g')), 'C')`
+
+// setTags replaces the tags of the mapping for domain and code, and updates
+// its search vector
+func setTags(ctx context.Context, tx pgx.Tx, domain, code string, tags []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM mapping_tags WHERE domain = $1 AND code = $2`, domain, code); err != nil {
+		return err
+	}
+
+	if len(tags) > 0 {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO mapping_tags (domain, code, tag) SELECT $1, $2, unnest($3::text[])`,
+			domain, code, tags,
+		)
+		if err != nil {
+			return err
+		}
+	}
 
+	_, err := tx.Exec(ctx,
+		`UPDATE url_mappings SET search_vector = `+searchVector+` WHERE domain = $1 AND code = $2`,
+		domain, code,
+	)
 	return err
 }
 
-// Get retrieves the original URL for a given code
-func (p *PostgresStore) Get(code string) (*string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// accountColumns selects the account a mapping counts against: its
+// organization, or the user for personal mappings
+const accountColumns = `CASE WHEN org_id = '' THEN user_id ELSE '' END AS user_id, org_id`
+
+// countRedirect adds the rows of a clicked CTE selected with accountColumns
+// to the monthly usage of their accounts
+const countRedirect = `
+		INSERT INTO monthly_redirects (user_id, org_id, month, redirects)
+		SELECT user_id, org_id, date_trunc('month', NOW() AT TIME ZONE 'UTC')::date, 1 FROM clicked
+		ON CONFLICT (user_id, org_id, month) DO UPDATE SET redirects = monthly_redirects.redirects + 1
+	`
+
+// SaveMetadata sets the metadata of the mapping as long as it still points
+// to original, so metadata fetched for a destination that has since changed
+// is dropped
+func (p *PostgresStore) SaveMetadata(ctx context.Context, domain, code, original string, metadata model.Metadata) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `UPDATE url_mappings SET metadata = $4 WHERE domain = $1 AND code = $2 AND original_url = $3`
+
+	result, err := p.pool.Exec(ctx, query, domain, code, original, nullableMetadata(&metadata))
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IncrementClickCount increases the click count for a given domain and code
+// and counts the redirect in the monthly usage of its account
+func (p *PostgresStore) IncrementClickCount(ctx context.Context, domain, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT original_url FROM url_mappings 
-		WHERE code = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		WITH clicked AS (
+			UPDATE url_mappings
+			SET clicks = clicks + 1
+			WHERE domain = $1 AND code = $2
+			RETURNING ` + accountColumns + `
+		)
+		` + countRedirect + `
 	`
 
-	var originalURL string
-	err := p.pool.QueryRow(ctx, query, code).Scan(&originalURL)
+	result, err := p.pool.Exec(ctx, query, domain, code)
 	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no URL mapping found for code: %s", code)
+	}
+
+	return nil
+}
+
+// ConsumeClick increases the click count for a given domain and code if it
+// has clicks left. The limit is checked in the same statement so concurrent
+// requests cannot go past it. The redirect is counted in the monthly usage
+// of its account, unless that would take it past maxRedirects, in which case
+// the click is rolled back. Missing mappings are reported as ErrNotFound.
+func (p *PostgresStore) ConsumeClick(ctx context.Context, domain, code string, maxRedirects int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	click := `
+		UPDATE url_mappings
+		SET clicks = clicks + 1
+		WHERE domain = $1 AND code = $2 AND (max_clicks IS NULL OR clicks < max_clicks)
+		RETURNING ` + accountColumns
+
+	// ON CONFLICT locks the usage row and checks the limit against its latest
+	// count, so concurrent redirects of the account cannot go past it
+	redirect := `
+		INSERT INTO monthly_redirects (user_id, org_id, month, redirects)
+		VALUES ($1, $2, date_trunc('month', NOW() AT TIME ZONE 'UTC')::date, 1)
+		ON CONFLICT (user_id, org_id, month) DO UPDATE SET redirects = monthly_redirects.redirects + 1
+		WHERE $3 = 0 OR monthly_redirects.redirects < $3
+	`
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var account model.Account
+		err := tx.QueryRow(ctx, click, domain, code).Scan(&account.UserID, &account.OrgID)
 		if err == pgx.ErrNoRows {
-			return nil, nil
+			// Either the link has no clicks left or it was deleted since it was read
+			var exists bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM url_mappings WHERE domain = $1 AND code = $2)`, domain, code).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return ErrNotFound
+			}
+			return ErrClickLimitReached
+		}
+		if err != nil {
+			return err
 		}
-		return nil, err
-	}
 
-	return &originalURL, nil
+		result, err := tx.Exec(ctx, redirect, account.UserID, account.OrgID, maxRedirects)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrRedirectLimitReached
+		}
+		return nil
+	})
 }
 
-// IncrementClickCount increases the click count for a given code
-func (p *PostgresStore) IncrementClickCount(code string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// IncrementVariantClickCount increases the click count of a variant of the
+// mapping for a given domain and code. The row is updated in place, so
+// concurrent clicks on different variants do not overwrite each other.
+func (p *PostgresStore) IncrementVariantClickCount(ctx context.Context, domain, code, variant string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	query := `
-		UPDATE url_mappings 
-		SET clicks = clicks + 1 
-		WHERE code = $1
+		UPDATE url_mappings
+		SET variant_clicks = jsonb_set(variant_clicks, ARRAY[$3::text], to_jsonb(COALESCE((variant_clicks->>$3)::int, 0) + 1))
+		WHERE domain = $1 AND code = $2
 	`
 
-	result, err := p.pool.Exec(ctx, query, code)
+	result, err := p.pool.Exec(ctx, query, domain, code, variant)
 	if err != nil {
 		return err
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("no URL mapping found for code: %s", code)
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-// ListByUser retrieves all URL mappings for a specific user
-func (p *PostgresStore) ListByUser(userID string) ([]model.URLMapping, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// ListByUser retrieves the personal URL mappings of a specific user that
+// pass filter
+func (p *PostgresStore) ListByUser(ctx context.Context, userID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	return p.listMappings(ctx, filter, "user_id = $1 AND org_id = ''", userID)
+}
+
+// ListByOrg retrieves the URL mappings owned by an organization that pass
+// filter
+func (p *PostgresStore) ListByOrg(ctx context.Context, orgID string, filter model.MappingFilter) ([]model.URLMapping, error) {
+	return p.listMappings(ctx, filter, "org_id = $1", orgID)
+}
+
+// listMappings retrieves the URL mappings matching where and filter, newest
+// first
+func (p *PostgresStore) listMappings(ctx context.Context, filter model.MappingFilter, where string, args ...any) ([]model.URLMapping, error) {
+	if filter.Folder != "" {
+		args = append(args, filter.Folder)
+		where += fmt.Sprintf(" AND folder = $%d", len(args))
+	}
+	if filter.Tag != "" {
+		args = append(args, filter.Tag)
+		where += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM mapping_tags t WHERE t.domain = url_mappings.domain AND t.code = url_mappings.code AND t.tag = $%d)", len(args))
+	}
+
+	return p.queryMappings(ctx, where, "created_at DESC", 0, args...)
+}
+
+// queryMappings retrieves up to limit URL mappings matching where, sorted by
+// order. A limit of 0 retrieves them all.
+func (p *PostgresStore) queryMappings(ctx context.Context, where, order string, limit int, args ...any) ([]model.URLMapping, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT code, original_url, user_id, created_at, expires_at, clicks
-		FROM url_mappings 
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+		SELECT ` + mappingColumns + `, ` + mappingTags + `
+		FROM url_mappings
+		WHERE ` + where + `
+		ORDER BY ` + order + `
 	`
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf("LIMIT $%d", len(args))
+	}
 
-	rows, err := p.pool.Query(ctx, query, userID)
+	rows, err := p.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -144,26 +463,11 @@ func (p *PostgresStore) ListByUser(userID string) ([]model.URLMapping, error) {
 
 	var mappings []model.URLMapping
 	for rows.Next() {
-		var mapping model.URLMapping
-		var expiresAt sql.NullTime
-
-		err := rows.Scan(
-			&mapping.Code,
-			&mapping.Original,
-			&mapping.UserID,
-			&mapping.CreatedAt,
-			&expiresAt,
-			&mapping.Clicks,
-		)
+		mapping, err := scanMapping(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if expiresAt.Valid {
-			mapping.ExpiresAt = &expiresAt.Time
-		}
-
-		mappings = append(mappings, mapping)
+		mappings = append(mappings, *mapping)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -173,14 +477,14 @@ func (p *PostgresStore) ListByUser(userID string) ([]model.URLMapping, error) {
 	return mappings, nil
 }
 
-// Delete removes a URL mapping by code
-func (p *PostgresStore) Delete(code string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// Delete removes a URL mapping by domain and code
+func (p *PostgresStore) Delete(ctx context.Context, domain, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM url_mappings WHERE code = $1`
+	query := `DELETE FROM url_mappings WHERE domain = $1 AND code = $2`
 
-	result, err := p.pool.Exec(ctx, query, code)
+	result, err := p.pool.Exec(ctx, query, domain, code)
 	if err != nil {
 		return err
 	}
@@ -192,9 +496,27 @@ func (p *PostgresStore) Delete(code string) error {
 	return nil
 }
 
+// CountActive returns the number of mappings that are active and have not
+// expired or used up their max clicks
+func (p *PostgresStore) CountActive(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) FROM url_mappings
+		WHERE (expires_at IS NULL OR expires_at > NOW())
+		AND (activates_at IS NULL OR activates_at <= NOW())
+		AND (max_clicks IS NULL OR clicks < max_clicks)
+	`
+
+	var count int
+	err := p.pool.QueryRow(ctx, query).Scan(&count)
+	return count, err
+}
+
 // CleanupExpired removes expired URL mappings
-func (p *PostgresStore) CleanupExpired() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func (p *PostgresStore) CleanupExpired(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	query := `DELETE FROM url_mappings WHERE expires_at IS NOT NULL AND expires_at < NOW()`
@@ -203,29 +525,732 @@ func (p *PostgresStore) CleanupExpired() error {
 	return err
 }
 
-func (p *PostgresStore) Close() {
-	if p.pool != nil {
-		p.pool.Close()
+// SaveUTMTemplate creates the template or replaces the user's template of the same name
+func (p *PostgresStore) SaveUTMTemplate(ctx context.Context, template model.UTMTemplate) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO utm_templates (` + utmTemplateColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, name) DO UPDATE SET
+			utm_source = EXCLUDED.utm_source,
+			utm_medium = EXCLUDED.utm_medium,
+			utm_campaign = EXCLUDED.utm_campaign,
+			utm_term = EXCLUDED.utm_term,
+			utm_content = EXCLUDED.utm_content
+	`
+
+	_, err := p.pool.Exec(ctx, query,
+		template.UserID,
+		template.Name,
+		nullableString(template.UTM.Source),
+		nullableString(template.UTM.Medium),
+		nullableString(template.UTM.Campaign),
+		nullableString(template.UTM.Term),
+		nullableString(template.UTM.Content),
+		template.CreatedAt,
+	)
+
+	return err
+}
+
+// GetUTMTemplate retrieves one of the user's templates by name
+func (p *PostgresStore) GetUTMTemplate(ctx context.Context, userID, name string) (*model.UTMTemplate, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + utmTemplateColumns + `
+		FROM utm_templates
+		WHERE user_id = $1 AND name = $2
+	`
+
+	template, err := scanUTMTemplate(p.pool.QueryRow(ctx, query, userID, name))
+	if err == pgx.ErrNoRows {
+		return nil, nil
 	}
+	return template, err
 }
 
-func ResetPostgresStore(connString string) error {
-	cfg, err := pgx.ParseConfig(connString)
+// ListUTMTemplates retrieves the user's templates ordered by name
+func (p *PostgresStore) ListUTMTemplates(ctx context.Context, userID string) ([]model.UTMTemplate, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + utmTemplateColumns + `
+		FROM utm_templates
+		WHERE user_id = $1
+		ORDER BY name
+	`
+
+	rows, err := p.pool.Query(ctx, query, userID)
 	if err != nil {
-		return fmt.Errorf("failed to parse connection string: %w", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []model.UTMTemplate
+	for rows.Next() {
+		template, err := scanUTMTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *template)
 	}
-	db := stdlib.OpenDB(*cfg)
-	defer db.Close()
 
-	if err := goose.SetDialect("postgres"); err != nil {
+	return templates, rows.Err()
+}
+
+// DeleteUTMTemplate removes one of the user's templates by name
+func (p *PostgresStore) DeleteUTMTemplate(ctx context.Context, userID, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM utm_templates WHERE user_id = $1 AND name = $2`
+
+	result, err := p.pool.Exec(ctx, query, userID, name)
+	if err != nil {
 		return err
 	}
-	goose.SetBaseFS(migrationsFS)
-	if err := goose.Down(db, "migrations"); err != nil {
-		if strings.Contains(err.Error(), "no current version found") { // first migration run.
-			return nil
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SaveDomain registers a domain, or returns ErrDomainExists if its name is taken
+func (p *PostgresStore) SaveDomain(ctx context.Context, domain model.Domain) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO domains (` + domainColumns + `)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO NOTHING
+	`
+
+	result, err := p.pool.Exec(ctx, query, domain.Name, domain.UserID, domain.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrDomainExists
+	}
+
+	return nil
+}
+
+// GetDomain retrieves a domain by name
+func (p *PostgresStore) GetDomain(ctx context.Context, name string) (*model.Domain, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + domainColumns + ` FROM domains WHERE name = $1`
+
+	domain, err := scanDomain(p.pool.QueryRow(ctx, query, name))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return domain, err
+}
+
+// ListDomains retrieves the user's domains ordered by name
+func (p *PostgresStore) ListDomains(ctx context.Context, userID string) ([]model.Domain, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + domainColumns + ` FROM domains WHERE user_id = $1 ORDER BY name`
+
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []model.Domain
+	for rows.Next() {
+		domain, err := scanDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, *domain)
+	}
+
+	return domains, rows.Err()
+}
+
+// DeleteDomain removes a domain that no mapping is served on
+func (p *PostgresStore) DeleteDomain(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM domains
+		WHERE name = $1 AND NOT EXISTS (SELECT 1 FROM url_mappings WHERE domain = $1)
+	`
+
+	result, err := p.pool.Exec(ctx, query, name)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		// Tell a domain in use from a missing one
+		domain, err := p.GetDomain(ctx, name)
+		if err != nil {
+			return err
+		}
+		if domain != nil {
+			return ErrDomainInUse
+		}
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SaveOrganization creates an organization and its owner's membership in one transaction
+func (p *PostgresStore) SaveOrganization(ctx context.Context, org model.Organization, owner model.Membership) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO organizations (id, name, created_at) VALUES ($1, $2, $3)`,
+			org.ID, org.Name, org.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO org_members (`+memberColumns+`) VALUES ($1, $2, $3, $4)`,
+			owner.OrgID, owner.UserID, owner.Role, owner.CreatedAt,
+		)
+		return err
+	})
+}
+
+// GetOrganization retrieves an organization by ID
+func (p *PostgresStore) GetOrganization(ctx context.Context, id string) (*model.Organization, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT id, name, created_at FROM organizations WHERE id = $1`
+
+	var org model.Organization
+	err := p.pool.QueryRow(ctx, query, id).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// ListMemberships retrieves the user's memberships ordered by organization ID
+func (p *PostgresStore) ListMemberships(ctx context.Context, userID string) ([]model.Membership, error) {
+	return p.listMembers(ctx, `SELECT `+memberColumns+` FROM org_members WHERE user_id = $1 ORDER BY org_id`, userID)
+}
+
+// SaveMember adds a member or changes their role, unless that demotes the
+// organization's only owner
+func (p *PostgresStore) SaveMember(ctx context.Context, member model.Membership) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO org_members (` + memberColumns + `)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if member.Role != model.RoleOwner {
+			if err := checkLastOwner(ctx, tx, member.OrgID, member.UserID); err != nil {
+				return err
+			}
 		}
+
+		_, err := tx.Exec(ctx, query, member.OrgID, member.UserID, member.Role, member.CreatedAt)
+		return err
+	})
+}
+
+// checkLastOwner returns ErrLastOwner if userID is the only owner of orgID.
+// It locks the organization until tx ends, so that concurrent changes to its
+// members cannot each see another owner left.
+func checkLastOwner(ctx context.Context, tx pgx.Tx, orgID, userID string) error {
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM organizations WHERE id = $1 FOR UPDATE`, orgID); err != nil {
+		return err
+	}
+
+	query := `
+		SELECT COUNT(*) = 1 AND BOOL_OR(user_id = $2)
+		FROM org_members WHERE org_id = $1 AND role = $3
+	`
+
+	var last bool
+	if err := tx.QueryRow(ctx, query, orgID, userID, model.RoleOwner).Scan(&last); err != nil {
 		return err
 	}
+	if last {
+		return ErrLastOwner
+	}
 	return nil
 }
+
+// GetMember retrieves the membership of a user in an organization
+func (p *PostgresStore) GetMember(ctx context.Context, orgID, userID string) (*model.Membership, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + memberColumns + ` FROM org_members WHERE org_id = $1 AND user_id = $2`
+
+	member, err := scanMember(p.pool.QueryRow(ctx, query, orgID, userID))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return member, err
+}
+
+// ListMembers retrieves the organization's members ordered by user ID
+func (p *PostgresStore) ListMembers(ctx context.Context, orgID string) ([]model.Membership, error) {
+	return p.listMembers(ctx, `SELECT `+memberColumns+` FROM org_members WHERE org_id = $1 ORDER BY user_id`, orgID)
+}
+
+func (p *PostgresStore) listMembers(ctx context.Context, query string, args ...any) ([]model.Membership, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []model.Membership
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *member)
+	}
+
+	return members, rows.Err()
+}
+
+// DeleteMember removes a user from an organization, unless they are its
+// only owner
+func (p *PostgresStore) DeleteMember(ctx context.Context, orgID, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM org_members WHERE org_id = $1 AND user_id = $2`
+
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if err := checkLastOwner(ctx, tx, orgID, userID); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(ctx, query, orgID, userID)
+		if err != nil {
+			return err
+		}
+
+		if result.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+
+		return nil
+	})
+}
+
+// countActiveLinks counts the active mappings of the account given as $1
+// (user ID) and $2 (organization ID). Links of an organization count against
+// it whoever created them.
+const countActiveLinks = `
+	SELECT COUNT(*) FROM url_mappings
+	WHERE org_id = $2 AND ($2 <> '' OR user_id = $1)
+	AND (expires_at IS NULL OR expires_at > NOW())
+	AND (max_clicks IS NULL OR clicks < max_clicks)
+`
+
+// CountActiveLinks returns how many of the account's mappings have not
+// expired or used up their max clicks, scheduled ones included
+func (p *PostgresStore) CountActiveLinks(ctx context.Context, account model.Account) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var count int
+	err := p.pool.QueryRow(ctx, countActiveLinks, account.UserID, account.OrgID).Scan(&count)
+	return count, err
+}
+
+// MonthlyRedirects returns how many redirects the account's mappings served
+// in the calendar month of month, in UTC
+func (p *PostgresStore) MonthlyRedirects(ctx context.Context, account model.Account, month time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(SUM(redirects), 0) FROM monthly_redirects
+		WHERE user_id = $1 AND org_id = $2 AND month = date_trunc('month', $3::timestamptz AT TIME ZONE 'UTC')::date
+	`
+
+	var count int
+	err := p.pool.QueryRow(ctx, query, account.UserID, account.OrgID, month).Scan(&count)
+	return count, err
+}
+
+// SaveQuota creates or replaces the quota of an account
+func (p *PostgresStore) SaveQuota(ctx context.Context, quota model.Quota) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO quotas (user_id, org_id, active_links, monthly_redirects)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, org_id) DO UPDATE
+		SET active_links = EXCLUDED.active_links, monthly_redirects = EXCLUDED.monthly_redirects
+	`
+
+	_, err := p.pool.Exec(ctx, query, quota.Account.UserID, quota.Account.OrgID, quota.ActiveLinks, quota.MonthlyRedirects)
+	return err
+}
+
+// GetQuota retrieves the quota of an account
+func (p *PostgresStore) GetQuota(ctx context.Context, account model.Account) (*model.Quota, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT active_links, monthly_redirects FROM quotas WHERE user_id = $1 AND org_id = $2`
+
+	quota := model.Quota{Account: account}
+	err := p.pool.QueryRow(ctx, query, account.UserID, account.OrgID).Scan(&quota.ActiveLinks, &quota.MonthlyRedirects)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// accountMappings matches the url_mappings rows m of the account given as
+// $1 (user ID) and $2 (organization ID)
+const accountMappings = "m.org_id = $2 AND ($2 <> '' OR m.user_id = $1)"
+
+// ListTags returns the tags on the account's mappings ordered by tag, with
+// how many mappings carry each
+func (p *PostgresStore) ListTags(ctx context.Context, account model.Account) ([]model.TagCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT t.tag, COUNT(*) FROM mapping_tags t
+		JOIN url_mappings m ON m.domain = t.domain AND m.code = t.code
+		WHERE ` + accountMappings + `
+		GROUP BY t.tag
+		ORDER BY t.tag
+	`
+
+	rows, err := p.pool.Query(ctx, query, account.UserID, account.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []model.TagCount
+	for rows.Next() {
+		var tag model.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Links); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// RenameTag replaces from with to on the account's mappings and returns how
+// many mappings were changed. Mappings that already carry to just lose from.
+func (p *PostgresStore) RenameTag(ctx context.Context, account model.Account, from, to string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var renamed int
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		merged, err := tx.Exec(ctx, `
+			DELETE FROM mapping_tags t USING url_mappings m
+			WHERE m.domain = t.domain AND m.code = t.code AND `+accountMappings+` AND t.tag = $3 AND $3 <> $4
+			AND EXISTS (SELECT 1 FROM mapping_tags o WHERE o.domain = t.domain AND o.code = t.code AND o.tag = $4)
+		`, account.UserID, account.OrgID, from, to)
+		if err != nil {
+			return err
+		}
+
+		updated, err := tx.Exec(ctx, `
+			UPDATE mapping_tags t SET tag = $4 FROM url_mappings m
+			WHERE m.domain = t.domain AND m.code = t.code AND `+accountMappings+` AND t.tag = $3
+		`, account.UserID, account.OrgID, from, to)
+		if err != nil {
+			return err
+		}
+
+		renamed = int(merged.RowsAffected() + updated.RowsAffected())
+		if renamed == 0 {
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE url_mappings SET search_vector = `+searchVector+`
+			WHERE url_mappings.org_id = $2 AND ($2 <> '' OR url_mappings.user_id = $1)
+			AND EXISTS (SELECT 1 FROM mapping_tags t WHERE t.domain = url_mappings.domain AND t.code = url_mappings.code AND t.tag = $3)
+		`, account.UserID, account.OrgID, to)
+		return err
+	})
+	return renamed, err
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchMappings ranks the account's mappings by full-text match of every
+// word of query, each as a prefix, falling back to substring matches of the
+// whole query in the title or destination. Ties go to the closest trigram
+// match, then to the newest.
+func (p *PostgresStore) SearchMappings(ctx context.Context, account model.Account, query string, limit int) ([]model.URLMapping, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+
+	args := []any{account.UserID, account.OrgID, strings.Join(prefixes, " & "), "%" + likeEscaper.Replace(query) + "%", query}
+	where := `org_id = $2 AND ($2 <> '' OR user_id = $1)
+		AND (search_vector @@ to_tsquery('simple', $3) OR title ILIKE $4 OR original_url ILIKE $4)`
+	order := `ts_rank(search_vector, to_tsquery('simple', $3)) DESC,
+		GREATEST(similarity(title, $5), similarity(original_url, $5)) DESC,
+		created_at DESC`
+
+	return p.queryMappings(ctx, where, order, limit, args...)
+}
+
+// Ping checks that a connection to the database can be acquired and used
+func (p *PostgresStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return p.pool.Ping(ctx)
+}
+
+func (p *PostgresStore) Close() {
+	if p.pool != nil {
+		p.pool.Close()
+	}
+}
+
+// scanMapping reads a row selected with mappingColumns and mappingTags
+func scanMapping(row pgx.Row) (*model.URLMapping, error) {
+	var mapping model.URLMapping
+	var expiresAt sql.NullTime
+	var activatesAt sql.NullTime
+	var maxClicks sql.NullInt32
+	var redirectType sql.NullInt16
+	var queryPassthrough sql.NullString
+	var utm nullUTM
+	var passwordHash sql.NullString
+	var rules []byte
+	var variants []byte
+	var variantClicks []byte
+	var metadata []byte
+
+	err := row.Scan(
+		&mapping.Domain,
+		&mapping.Code,
+		&mapping.Original,
+		&mapping.UserID,
+		&mapping.CreatedAt,
+		&expiresAt,
+		&activatesAt,
+		&mapping.Clicks,
+		&maxClicks,
+		&redirectType,
+		&queryPassthrough,
+		&mapping.PathPassthrough,
+		&utm.Source,
+		&utm.Medium,
+		&utm.Campaign,
+		&utm.Term,
+		&utm.Content,
+		&passwordHash,
+		&rules,
+		&variants,
+		&mapping.StickyVariants,
+		&variantClicks,
+		&mapping.Title,
+		&mapping.Interstitial,
+		&mapping.OrgID,
+		&mapping.Folder,
+		&metadata,
+		&mapping.Tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		mapping.ExpiresAt = &expiresAt.Time
+	}
+	if activatesAt.Valid {
+		mapping.ActivatesAt = &activatesAt.Time
+	}
+	if maxClicks.Valid {
+		mapping.MaxClicks = int(maxClicks.Int32)
+	}
+	if redirectType.Valid {
+		mapping.RedirectType = int(redirectType.Int16)
+	}
+	mapping.QueryPassthrough = queryPassthrough.String
+	mapping.UTM = utm.UTM()
+	mapping.PasswordHash = passwordHash.String
+	if len(rules) > 0 {
+		if err := json.Unmarshal(rules, &mapping.Rules); err != nil {
+			return nil, fmt.Errorf("failed to decode routing rules of %s: %w", mapping.Code, err)
+		}
+	}
+	if len(variants) > 0 {
+		if err := json.Unmarshal(variants, &mapping.Variants); err != nil {
+			return nil, fmt.Errorf("failed to decode variants of %s: %w", mapping.Code, err)
+		}
+	}
+	if err := json.Unmarshal(variantClicks, &mapping.VariantClicks); err != nil {
+		return nil, fmt.Errorf("failed to decode variant clicks of %s: %w", mapping.Code, err)
+	}
+	if len(mapping.VariantClicks) == 0 {
+		mapping.VariantClicks = nil
+	}
+	if len(mapping.Tags) == 0 {
+		mapping.Tags = nil
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &mapping.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata of %s: %w", mapping.Code, err)
+		}
+	}
+
+	return &mapping, nil
+}
+
+// scanUTMTemplate reads a row selected with utmTemplateColumns
+func scanUTMTemplate(row pgx.Row) (*model.UTMTemplate, error) {
+	var template model.UTMTemplate
+	var utm nullUTM
+
+	err := row.Scan(
+		&template.UserID,
+		&template.Name,
+		&utm.Source,
+		&utm.Medium,
+		&utm.Campaign,
+		&utm.Term,
+		&utm.Content,
+		&template.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	template.UTM = utm.UTM()
+	return &template, nil
+}
+
+// scanDomain reads a row selected with domainColumns
+func scanDomain(row pgx.Row) (*model.Domain, error) {
+	var domain model.Domain
+	if err := row.Scan(&domain.Name, &domain.UserID, &domain.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &domain, nil
+}
+
+// scanMember reads a row selected with memberColumns
+func scanMember(row pgx.Row) (*model.Membership, error) {
+	var member model.Membership
+	if err := row.Scan(&member.OrgID, &member.UserID, &member.Role, &member.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// nullUTM scans the nullable utm_* columns
+type nullUTM struct {
+	Source, Medium, Campaign, Term, Content sql.NullString
+}
+
+func (u nullUTM) UTM() model.UTM {
+	return model.UTM{
+		Source:   u.Source.String,
+		Medium:   u.Medium.String,
+		Campaign: u.Campaign.String,
+		Term:     u.Term.String,
+		Content:  u.Content.String,
+	}
+}
+
+// nullableInt stores zero values as NULL
+func nullableInt(v int) *int {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// nullableList encodes a list for a JSONB column, storing an empty list as NULL
+func nullableList[T any](list []T) *string {
+	if len(list) == 0 {
+		return nil
+	}
+	// Lists hold plain structs, so encoding cannot fail
+	data, _ := json.Marshal(list)
+	encoded := string(data)
+	return &encoded
+}
+
+// variantClicksJSON encodes per variant click counts for the JSONB column
+func variantClicksJSON(clicks map[string]int) string {
+	if len(clicks) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(clicks)
+	return string(data)
+}
+
+// nullableMetadata encodes metadata for the JSONB column, NULL when there is none
+func nullableMetadata(metadata *model.Metadata) *string {
+	if metadata == nil {
+		return nil
+	}
+	data, _ := json.Marshal(metadata)
+	encoded := string(data)
+	return &encoded
+}
+
+// nullableString stores empty strings as NULL
+func nullableString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -30,6 +31,10 @@ func TestPostgresStoreIntegration(t *testing.T) {
 
 	defer cleanup()
 
+	t.Run("Ping", func(t *testing.T) {
+		assert.NoError(t, store.Ping(context.Background()))
+	})
+
 	t.Run("Save and Get", func(t *testing.T) {
 		mapping := model.URLMapping{
 			Code:      "test123",
@@ -40,20 +45,52 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		}
 
 		// Save the mapping
-		err := store.Save(mapping)
+		err := store.Save(context.Background(), mapping)
 		assert.NoError(t, err)
 
+		// Saving the code again does not replace it
+		taken := mapping
+		taken.Original = "https://other.example.com"
+		assert.ErrorIs(t, store.Save(context.Background(), taken), ErrCodeExists)
+
 		// Get the mapping
-		original, err := store.Get("test123")
+		original, err := store.Get(context.Background(), "", "test123")
 		assert.NoError(t, err)
-		assert.Equal(t, "https://example.com", *original)
+		assert.Equal(t, "https://example.com", original.Original)
 
 		// Test non-existent code
-		original, err = store.Get("nonexistent")
+		original, err = store.Get(context.Background(), "", "nonexistent")
 		assert.NoError(t, err)
 		assert.Nil(t, original)
 	})
 
+	t.Run("GetMapping", func(t *testing.T) {
+		expiresAt := time.Now().Add(-1 * time.Hour)
+		mapping := model.URLMapping{
+			Code:      "getmapping",
+			Original:  "https://getmapping.com",
+			UserID:    "user1",
+			CreatedAt: time.Now(),
+			ExpiresAt: &expiresAt,
+			Clicks:    4,
+		}
+
+		err := store.Save(context.Background(), mapping)
+		assert.NoError(t, err)
+
+		// Expired mappings are still returned for management purposes
+		found, err := store.GetMapping(context.Background(), "", "getmapping")
+		assert.NoError(t, err)
+		assert.NotNil(t, found)
+		assert.Equal(t, "https://getmapping.com", found.Original)
+		assert.Equal(t, 4, found.Clicks)
+		assert.NotNil(t, found.ExpiresAt)
+
+		found, err = store.GetMapping(context.Background(), "", "nonexistent")
+		assert.NoError(t, err)
+		assert.Nil(t, found)
+	})
+
 	t.Run("IncrementClickCount", func(t *testing.T) {
 		mapping := model.URLMapping{
 			Code:      "clicktest",
@@ -64,15 +101,15 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		}
 
 		// Save the mapping
-		err := store.Save(mapping)
+		err := store.Save(context.Background(), mapping)
 		assert.NoError(t, err)
 
 		// Increment click count
-		err = store.IncrementClickCount("clicktest")
+		err = store.IncrementClickCount(context.Background(), "", "clicktest")
 		assert.NoError(t, err)
 
 		// Verify click count was incremented by checking the mapping
-		mappings, err := store.ListByUser("user1")
+		mappings, err := store.ListByUser(context.Background(), "user1", model.MappingFilter{})
 		assert.NoError(t, err)
 
 		var foundMapping *model.URLMapping
@@ -86,6 +123,27 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		assert.Equal(t, 1, foundMapping.Clicks)
 	})
 
+	t.Run("ConsumeClick", func(t *testing.T) {
+		err := store.Save(context.Background(), model.URLMapping{
+			Code:      "singleuse",
+			Original:  "https://singleuse.com",
+			UserID:    "user1",
+			CreatedAt: time.Now(),
+			MaxClicks: 1,
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.ConsumeClick(context.Background(), "", "singleuse", 0))
+		assert.ErrorIs(t, store.ConsumeClick(context.Background(), "", "singleuse", 0), ErrClickLimitReached)
+		assert.ErrorIs(t, store.ConsumeClick(context.Background(), "", "missing", 0), ErrNotFound)
+
+		mapping, err := store.GetMapping(context.Background(), "", "singleuse")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, mapping.Clicks)
+		assert.Equal(t, 1, mapping.MaxClicks)
+		assert.True(t, mapping.Exhausted())
+	})
+
 	t.Run("ListByUser", func(t *testing.T) {
 		// Create multiple mappings for the same user
 		mappings := []model.URLMapping{
@@ -106,12 +164,12 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		}
 
 		for _, mapping := range mappings {
-			err := store.Save(mapping)
+			err := store.Save(context.Background(), mapping)
 			assert.NoError(t, err)
 		}
 
 		// List mappings for user1
-		userMappings, err := store.ListByUser("user1")
+		userMappings, err := store.ListByUser(context.Background(), "user1", model.MappingFilter{})
 		assert.NoError(t, err)
 		assert.GreaterOrEqual(t, len(userMappings), 2)
 
@@ -134,24 +192,110 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		}
 
 		// Save the mapping
-		err := store.Save(mapping)
+		err := store.Save(context.Background(), mapping)
 		assert.NoError(t, err)
 
 		// Verify it exists
-		original, err := store.Get("deletetest")
+		original, err := store.Get(context.Background(), "", "deletetest")
 		assert.NoError(t, err)
-		assert.Equal(t, "https://deletetest.com", *original)
+		assert.Equal(t, "https://deletetest.com", original.Original)
 
 		// Delete the mapping
-		err = store.Delete("deletetest")
+		err = store.Delete(context.Background(), "", "deletetest")
 		assert.NoError(t, err)
 
 		// Verify it's gone
-		original, err = store.Get("deletetest")
+		original, err = store.Get(context.Background(), "", "deletetest")
 		assert.NoError(t, err)
 		assert.Nil(t, original)
 	})
 
+	t.Run("RedirectType", func(t *testing.T) {
+		err := store.Save(context.Background(), model.URLMapping{
+			Code:         "perm301",
+			Original:     "https://permanent.com",
+			UserID:       "user1",
+			CreatedAt:    time.Now(),
+			RedirectType: 301,
+		})
+		assert.NoError(t, err)
+
+		mapping, err := store.Get(context.Background(), "", "perm301")
+		assert.NoError(t, err)
+		assert.Equal(t, 301, mapping.RedirectType)
+
+		// Unset redirect types are stored as NULL and read back as 0
+		mapping, err = store.GetMapping(context.Background(), "", "test123")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, mapping.RedirectType)
+	})
+
+	t.Run("Passthrough", func(t *testing.T) {
+		err := store.Save(context.Background(), model.URLMapping{
+			Code:             "prefix",
+			Original:         "https://docs.example.com/v1",
+			UserID:           "user1",
+			CreatedAt:        time.Now(),
+			QueryPassthrough: model.QueryPassthroughMerge,
+			PathPassthrough:  true,
+		})
+		assert.NoError(t, err)
+
+		mapping, err := store.Get(context.Background(), "", "prefix")
+		assert.NoError(t, err)
+		assert.Equal(t, model.QueryPassthroughMerge, mapping.QueryPassthrough)
+		assert.True(t, mapping.PathPassthrough)
+
+		mapping, err = store.GetMapping(context.Background(), "", "test123")
+		assert.NoError(t, err)
+		assert.Equal(t, model.QueryPassthroughNone, mapping.QueryPassthrough)
+		assert.False(t, mapping.PathPassthrough)
+	})
+
+	t.Run("UTM", func(t *testing.T) {
+		utm := model.UTM{Source: "newsletter", Medium: "email", Campaign: "spring"}
+		err := store.Save(context.Background(), model.URLMapping{
+			Code:      "campaign",
+			Original:  "https://example.com?utm_campaign=spring&utm_medium=email&utm_source=newsletter",
+			UserID:    "user1",
+			CreatedAt: time.Now(),
+			UTM:       utm,
+		})
+		assert.NoError(t, err)
+
+		mapping, err := store.GetMapping(context.Background(), "", "campaign")
+		assert.NoError(t, err)
+		assert.Equal(t, utm, mapping.UTM)
+	})
+
+	t.Run("UTMTemplates", func(t *testing.T) {
+		ctx := context.Background()
+		created := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+
+		assert.NoError(t, store.SaveUTMTemplate(ctx, model.UTMTemplate{UserID: "user1", Name: "social", UTM: model.UTM{Source: "twitter"}, CreatedAt: created}))
+		assert.NoError(t, store.SaveUTMTemplate(ctx, model.UTMTemplate{UserID: "user1", Name: "email", UTM: model.UTM{Source: "newsletter", Medium: "email"}, CreatedAt: created}))
+
+		// Replacing a template keeps its creation time
+		assert.NoError(t, store.SaveUTMTemplate(ctx, model.UTMTemplate{UserID: "user1", Name: "social", UTM: model.UTM{Source: "mastodon"}, CreatedAt: time.Now()}))
+
+		template, err := store.GetUTMTemplate(ctx, "user1", "social")
+		assert.NoError(t, err)
+		assert.Equal(t, model.UTM{Source: "mastodon"}, template.UTM)
+		assert.True(t, created.Equal(template.CreatedAt))
+
+		templates, err := store.ListUTMTemplates(ctx, "user1")
+		assert.NoError(t, err)
+		assert.Len(t, templates, 2)
+		assert.Equal(t, "email", templates[0].Name)
+
+		assert.NoError(t, store.DeleteUTMTemplate(ctx, "user1", "email"))
+		assert.ErrorIs(t, store.DeleteUTMTemplate(ctx, "user1", "email"), ErrNotFound)
+
+		template, err = store.GetUTMTemplate(ctx, "user1", "email")
+		assert.NoError(t, err)
+		assert.Nil(t, template)
+	})
+
 	t.Run("Expired URLs", func(t *testing.T) {
 		expiresAt := time.Now().Add(-1 * time.Hour) // Expired 1 hour ago
 		mapping := model.URLMapping{
@@ -164,15 +308,369 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		}
 
 		// Save the mapping
-		err := store.Save(mapping)
+		err := store.Save(context.Background(), mapping)
 		assert.NoError(t, err)
 
 		// Try to get the expired URL
-		original, err := store.Get("expired")
+		original, err := store.Get(context.Background(), "", "expired")
 		assert.NoError(t, err)
 		assert.Nil(t, original) // Should return nil for expired URLs
 	})
 
+	t.Run("RoutingRules", func(t *testing.T) {
+		rules := []model.RoutingRule{
+			{Platform: model.PlatformIOS, URL: "https://apps.apple.com/app/id123"},
+			{Language: "pt", Headers: map[string]string{"X-Beta": ""}, URL: "https://example.com/pt"},
+		}
+		err := store.Save(context.Background(), model.URLMapping{
+			Code:      "routed",
+			Original:  "https://example.com",
+			UserID:    "user1",
+			CreatedAt: time.Now(),
+			Rules:     rules,
+		})
+		assert.NoError(t, err)
+
+		mapping, err := store.Get(context.Background(), "", "routed")
+		assert.NoError(t, err)
+		assert.Equal(t, rules, mapping.Rules)
+
+		mapping.Rules = nil
+		assert.NoError(t, store.Update(context.Background(), *mapping))
+
+		mapping, err = store.GetMapping(context.Background(), "", "routed")
+		assert.NoError(t, err)
+		assert.Nil(t, mapping.Rules)
+	})
+
+	t.Run("Variants", func(t *testing.T) {
+		variants := []model.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 50},
+			{Name: "b", URL: "https://example.com/b", Weight: 50},
+		}
+		err := store.Save(context.Background(), model.URLMapping{
+			Code:           "split",
+			Original:       "https://example.com",
+			UserID:         "user1",
+			CreatedAt:      time.Now(),
+			Variants:       variants,
+			StickyVariants: true,
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.IncrementVariantClickCount(context.Background(), "", "split", "a"))
+		assert.NoError(t, store.IncrementVariantClickCount(context.Background(), "", "split", "a"))
+		assert.NoError(t, store.IncrementVariantClickCount(context.Background(), "", "split", "b"))
+		assert.ErrorIs(t, store.IncrementVariantClickCount(context.Background(), "", "missing", "a"), ErrNotFound)
+
+		mapping, err := store.GetMapping(context.Background(), "", "split")
+		assert.NoError(t, err)
+		assert.Equal(t, variants, mapping.Variants)
+		assert.True(t, mapping.StickyVariants)
+		assert.Equal(t, map[string]int{"a": 2, "b": 1}, mapping.VariantClicks)
+
+		// Updates replace the variants but keep their counts
+		mapping.Variants = nil
+		assert.NoError(t, store.Update(context.Background(), *mapping))
+		mapping, err = store.GetMapping(context.Background(), "", "split")
+		assert.NoError(t, err)
+		assert.Nil(t, mapping.Variants)
+		assert.Equal(t, map[string]int{"a": 2, "b": 1}, mapping.VariantClicks)
+	})
+
+	t.Run("Interstitial", func(t *testing.T) {
+		err := store.Save(context.Background(), model.URLMapping{
+			Code:         "preview",
+			Original:     "https://example.com",
+			UserID:       "user1",
+			CreatedAt:    time.Now(),
+			Title:        "Quarterly report",
+			Interstitial: true,
+		})
+		assert.NoError(t, err)
+
+		mapping, err := store.Get(context.Background(), "", "preview")
+		assert.NoError(t, err)
+		assert.Equal(t, "Quarterly report", mapping.Title)
+		assert.True(t, mapping.Interstitial)
+
+		mapping.Title = ""
+		mapping.Interstitial = false
+		assert.NoError(t, store.Update(context.Background(), *mapping))
+		mapping, err = store.Get(context.Background(), "", "preview")
+		assert.NoError(t, err)
+		assert.Empty(t, mapping.Title)
+		assert.False(t, mapping.Interstitial)
+	})
+
+	t.Run("ActivatesAt", func(t *testing.T) {
+		launch := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+		mapping := model.URLMapping{
+			Code:        "launch",
+			Original:    "https://launch.com",
+			UserID:      "user1",
+			CreatedAt:   time.Now(),
+			ActivatesAt: &launch,
+		}
+		assert.NoError(t, store.Save(context.Background(), mapping))
+
+		// Links are hidden from Get until they activate
+		original, err := store.Get(context.Background(), "", "launch")
+		assert.NoError(t, err)
+		assert.Nil(t, original)
+
+		saved, err := store.GetMapping(context.Background(), "", "launch")
+		assert.NoError(t, err)
+		assert.True(t, launch.Equal(*saved.ActivatesAt))
+
+		mapping.ActivatesAt = nil
+		assert.NoError(t, store.Update(context.Background(), mapping))
+
+		original, err = store.Get(context.Background(), "", "launch")
+		assert.NoError(t, err)
+		assert.NotNil(t, original)
+
+		assert.ErrorIs(t, store.Update(context.Background(), model.URLMapping{Code: "missing"}), ErrNotFound)
+	})
+
+	t.Run("Domains", func(t *testing.T) {
+		ctx := context.Background()
+		assert.NoError(t, store.SaveDomain(ctx, model.Domain{Name: "go.example.com", UserID: "user1", CreatedAt: time.Now()}))
+		assert.ErrorIs(t, store.SaveDomain(ctx, model.Domain{Name: "go.example.com", UserID: "user2", CreatedAt: time.Now()}), ErrDomainExists)
+
+		domain, err := store.GetDomain(ctx, "go.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "user1", domain.UserID)
+		domain, err = store.GetDomain(ctx, "missing.example.com")
+		assert.NoError(t, err)
+		assert.Nil(t, domain)
+
+		domains, err := store.ListDomains(ctx, "user1")
+		assert.NoError(t, err)
+		assert.Len(t, domains, 1)
+
+		// The same code may be used on another domain
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: "samecode", Original: "https://default.com", UserID: "user1", CreatedAt: time.Now()}))
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Domain: "go.example.com", Code: "samecode", Original: "https://branded.com", UserID: "user1", CreatedAt: time.Now()}))
+		assert.Error(t, store.Save(ctx, model.URLMapping{Domain: "go.example.com", Code: "samecode", Original: "https://clash.com", UserID: "user1", CreatedAt: time.Now()}))
+
+		mapping, err := store.Get(ctx, "go.example.com", "samecode")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://branded.com", mapping.Original)
+		assert.Equal(t, "go.example.com", mapping.Domain)
+		assert.NoError(t, store.IncrementClickCount(ctx, "go.example.com", "samecode"))
+		mapping, err = store.Get(ctx, "", "samecode")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, mapping.Clicks)
+
+		assert.ErrorIs(t, store.DeleteDomain(ctx, "go.example.com"), ErrDomainInUse)
+		assert.NoError(t, store.Delete(ctx, "go.example.com", "samecode"))
+		assert.NoError(t, store.DeleteDomain(ctx, "go.example.com"))
+		assert.ErrorIs(t, store.DeleteDomain(ctx, "go.example.com"), ErrNotFound)
+	})
+
+	t.Run("Organizations", func(t *testing.T) {
+		ctx := context.Background()
+		orgID := fmt.Sprintf("org%d", time.Now().UnixNano()%1e12)
+		assert.NoError(t, store.SaveOrganization(ctx, model.Organization{ID: orgID, Name: "Acme", CreatedAt: time.Now()},
+			model.Membership{OrgID: orgID, UserID: "orgowner", Role: model.RoleOwner, CreatedAt: time.Now()}))
+
+		org, err := store.GetOrganization(ctx, orgID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Acme", org.Name)
+		org, err = store.GetOrganization(ctx, "missing")
+		assert.NoError(t, err)
+		assert.Nil(t, org)
+
+		assert.NoError(t, store.SaveMember(ctx, model.Membership{OrgID: orgID, UserID: "orgviewer", Role: model.RoleViewer, CreatedAt: time.Now()}))
+		assert.NoError(t, store.SaveMember(ctx, model.Membership{OrgID: orgID, UserID: "orgviewer", Role: model.RoleEditor, CreatedAt: time.Now()}))
+		member, err := store.GetMember(ctx, orgID, "orgviewer")
+		assert.NoError(t, err)
+		assert.Equal(t, model.RoleEditor, member.Role)
+
+		members, err := store.ListMembers(ctx, orgID)
+		assert.NoError(t, err)
+		assert.Len(t, members, 2)
+		memberships, err := store.ListMemberships(ctx, "orgviewer")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, memberships)
+
+		assert.NoError(t, store.DeleteMember(ctx, orgID, "orgviewer"))
+		assert.ErrorIs(t, store.DeleteMember(ctx, orgID, "orgviewer"), ErrNotFound)
+
+		// The only owner can neither be demoted nor removed
+		assert.ErrorIs(t, store.SaveMember(ctx, model.Membership{OrgID: orgID, UserID: "orgowner", Role: model.RoleAdmin, CreatedAt: time.Now()}), ErrLastOwner)
+		assert.ErrorIs(t, store.DeleteMember(ctx, orgID, "orgowner"), ErrLastOwner)
+
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: orgID, Original: "https://team.com", UserID: "orgowner", OrgID: orgID, CreatedAt: time.Now()}))
+		shared, err := store.ListByOrg(ctx, orgID, model.MappingFilter{})
+		assert.NoError(t, err)
+		assert.Len(t, shared, 1)
+		assert.Equal(t, orgID, shared[0].OrgID)
+		personal, err := store.ListByUser(ctx, "orgowner", model.MappingFilter{})
+		assert.NoError(t, err)
+		assert.Empty(t, personal)
+	})
+
+	t.Run("Quotas", func(t *testing.T) {
+		ctx := context.Background()
+		orgID := fmt.Sprintf("qorg%d", time.Now().UnixNano()%1e12)
+		account := model.Account{OrgID: orgID}
+
+		quota, err := store.GetQuota(ctx, account)
+		assert.NoError(t, err)
+		assert.Nil(t, quota)
+
+		links := 10
+		assert.NoError(t, store.SaveQuota(ctx, model.Quota{Account: account, ActiveLinks: &links}))
+		quota, err = store.GetQuota(ctx, account)
+		assert.NoError(t, err)
+		assert.Equal(t, &links, quota.ActiveLinks)
+		assert.Nil(t, quota.MonthlyRedirects)
+
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: orgID, Original: "https://quota.com", UserID: "quotauser", OrgID: orgID, CreatedAt: time.Now()}))
+		count, err := store.CountActiveLinks(ctx, account)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		assert.NoError(t, store.IncrementClickCount(ctx, "", orgID))
+		assert.NoError(t, store.ConsumeClick(ctx, "", orgID, 0))
+		redirects, err := store.MonthlyRedirects(ctx, account, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 2, redirects)
+		redirects, err = store.MonthlyRedirects(ctx, model.Account{UserID: "quotauser"}, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, redirects)
+
+		// At the redirect limit, clicks are refused and not counted
+		assert.ErrorIs(t, store.ConsumeClick(ctx, "", orgID, 2), ErrRedirectLimitReached)
+		mapping, err := store.GetMapping(ctx, "", orgID)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, mapping.Clicks)
+
+		assert.ErrorIs(t, store.SaveWithinLimit(ctx, model.URLMapping{Code: orgID + "b", Original: "https://quota.com", OrgID: orgID, CreatedAt: time.Now()}, 1), ErrActiveLinksLimitReached)
+		assert.NoError(t, store.SaveWithinLimit(ctx, model.URLMapping{Code: orgID + "b", Original: "https://quota.com", OrgID: orgID, CreatedAt: time.Now()}, 2))
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		ctx := context.Background()
+		userID := fmt.Sprintf("taguser%d", time.Now().UnixNano()%1e12)
+		account := model.Account{UserID: userID}
+
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: userID + "a", Original: "https://a.com", UserID: userID, CreatedAt: time.Now(), Tags: []string{"q3", "slides"}, Folder: "Sales"}))
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: userID + "b", Original: "https://b.com", UserID: userID, CreatedAt: time.Now(), Tags: []string{"q3"}}))
+
+		mapping, err := store.GetMapping(ctx, "", userID+"a")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"q3", "slides"}, mapping.Tags)
+		assert.Equal(t, "Sales", mapping.Folder)
+
+		mappings, err := store.ListByUser(ctx, userID, model.MappingFilter{Tag: "q3"})
+		assert.NoError(t, err)
+		assert.Len(t, mappings, 2)
+		mappings, err = store.ListByUser(ctx, userID, model.MappingFilter{Tag: "q3", Folder: "Sales"})
+		assert.NoError(t, err)
+		assert.Len(t, mappings, 1)
+
+		mapping.Tags = []string{"deck"}
+		mapping.Folder = ""
+		assert.NoError(t, store.Update(ctx, *mapping))
+		mapping, err = store.GetMapping(ctx, "", userID+"a")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"deck"}, mapping.Tags)
+		assert.Empty(t, mapping.Folder)
+
+		renamed, err := store.RenameTag(ctx, account, "q3", "deck")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, renamed)
+		tags, err := store.ListTags(ctx, account)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.TagCount{{Tag: "deck", Links: 2}}, tags)
+
+		renamed, err = store.RenameTag(ctx, account, "deck", "deck")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, renamed)
+
+		assert.NoError(t, store.Delete(ctx, "", userID+"a"))
+		tags, err = store.ListTags(ctx, account)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.TagCount{{Tag: "deck", Links: 1}}, tags)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		ctx := context.Background()
+		userID := fmt.Sprintf("searchuser%d", time.Now().UnixNano()%1e12)
+		account := model.Account{UserID: userID}
+		now := time.Now()
+
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: userID + "a", Original: "https://example.com/launch/deck.pdf", UserID: userID, CreatedAt: now}))
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: userID + "b", Original: "https://example.com/memo", UserID: userID, Title: "Launch memo", CreatedAt: now}))
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: userID + "c", Original: "https://example.com/notes", UserID: userID, Tags: []string{"launch"}, CreatedAt: now}))
+
+		mappings, err := store.SearchMappings(ctx, account, "launch", 0)
+		assert.NoError(t, err)
+		if assert.Len(t, mappings, 3) {
+			assert.Equal(t, userID+"b", mappings[0].Code)
+			assert.Equal(t, userID+"c", mappings[1].Code)
+			assert.Equal(t, userID+"a", mappings[2].Code)
+		}
+
+		mappings, err = store.SearchMappings(ctx, account, "laun pdf", 0)
+		assert.NoError(t, err)
+		assert.Len(t, mappings, 1)
+
+		mappings, err = store.SearchMappings(ctx, account, "launch", 1)
+		assert.NoError(t, err)
+		assert.Len(t, mappings, 1)
+
+		_, err = store.RenameTag(ctx, account, "launch", "release")
+		assert.NoError(t, err)
+		mappings, err = store.SearchMappings(ctx, account, "release", 0)
+		assert.NoError(t, err)
+		if assert.Len(t, mappings, 1) {
+			assert.Equal(t, userID+"c", mappings[0].Code)
+		}
+	})
+
+	t.Run("SaveMetadata", func(t *testing.T) {
+		ctx := context.Background()
+		code := fmt.Sprintf("meta%d", time.Now().UnixNano()%1e12)
+		metadata := model.Metadata{Title: "Launch", Description: "All about it", Image: "https://example.com/cover.png", FetchedAt: time.Now().UTC().Truncate(time.Second)}
+
+		assert.NoError(t, store.Save(ctx, model.URLMapping{Code: code, Original: "https://example.com/launch", UserID: "user1", CreatedAt: time.Now()}))
+		mapping, err := store.GetMapping(ctx, "", code)
+		assert.NoError(t, err)
+		assert.Nil(t, mapping.Metadata)
+
+		assert.NoError(t, store.SaveMetadata(ctx, "", code, "https://example.com/launch", metadata))
+		assert.ErrorIs(t, store.SaveMetadata(ctx, "", code, "https://example.com/other", model.Metadata{}), ErrNotFound)
+
+		mapping.Title = "Renamed"
+		assert.NoError(t, store.Update(ctx, *mapping))
+		mapping, err = store.GetMapping(ctx, "", code)
+		assert.NoError(t, err)
+		if assert.NotNil(t, mapping.Metadata) {
+			assert.Equal(t, metadata.Title, mapping.Metadata.Title)
+			assert.True(t, metadata.FetchedAt.Equal(mapping.Metadata.FetchedAt))
+		}
+	})
+
+	t.Run("CountActive", func(t *testing.T) {
+		before, err := store.CountActive(context.Background())
+		assert.NoError(t, err)
+
+		future := time.Now().Add(time.Hour)
+		past := time.Now().Add(-time.Hour)
+
+		assert.NoError(t, store.Save(context.Background(), model.URLMapping{Code: "countactive1", Original: "https://a.com", UserID: "user1", CreatedAt: time.Now(), ExpiresAt: &future}))
+		assert.NoError(t, store.Save(context.Background(), model.URLMapping{Code: "countactive2", Original: "https://b.com", UserID: "user1", CreatedAt: time.Now(), ExpiresAt: &past}))
+		assert.NoError(t, store.Save(context.Background(), model.URLMapping{Code: "countactive3", Original: "https://c.com", UserID: "user1", CreatedAt: time.Now(), ActivatesAt: &future}))
+
+		after, err := store.CountActive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, before+1, after)
+	})
+
 	t.Run("CleanupExpired", func(t *testing.T) {
 		expiresAt := time.Now().Add(-1 * time.Hour) // Expired 1 hour ago
 		mapping := model.URLMapping{
@@ -185,15 +683,15 @@ func TestPostgresStoreIntegration(t *testing.T) {
 		}
 
 		// Save the mapping
-		err := store.Save(mapping)
+		err := store.Save(context.Background(), mapping)
 		assert.NoError(t, err)
 
 		// Run cleanup
-		err = store.CleanupExpired()
+		err = store.CleanupExpired(context.Background())
 		assert.NoError(t, err)
 
 		// Verify the expired mapping was removed
-		original, err := store.Get("cleanuptest")
+		original, err := store.Get(context.Background(), "", "cleanuptest")
 		assert.NoError(t, err)
 		assert.Nil(t, original)
 	})
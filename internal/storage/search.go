@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+// Weights of the fields of a mapping in search results. They match the
+// ts_rank defaults for the A, B and C labels PostgresStore gives the same
+// fields.
+const (
+	weightName = 1.0 // code and title
+	weightTag  = 0.4
+	weightURL  = 0.2
+)
+
+// searchTerms splits text into the lowercase words search matches on. Words
+// are runs of letters and digits, so URLs break up into their parts.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// mappingTerms returns the words of the searchable fields of mapping, each
+// with the weight of the most relevant field it appears in
+func mappingTerms(mapping model.URLMapping) map[string]float64 {
+	terms := make(map[string]float64)
+	add := func(text string, weight float64) {
+		for _, term := range searchTerms(text) {
+			terms[term] = max(terms[term], weight)
+		}
+	}
+	add(mapping.Code, weightName)
+	add(mapping.Title, weightName)
+	for _, tag := range mapping.Tags {
+		add(tag, weightTag)
+	}
+	add(mapping.Original, weightURL)
+	return terms
+}
+
+// searchIndex is an inverted index from words to the mappings they appear
+// in, with the weight of the word in each
+type searchIndex map[string]map[mappingKey]float64
+
+func (idx searchIndex) add(key mappingKey, mapping model.URLMapping) {
+	for term, weight := range mappingTerms(mapping) {
+		keys, exists := idx[term]
+		if !exists {
+			keys = make(map[mappingKey]float64)
+			idx[term] = keys
+		}
+		keys[key] = weight
+	}
+}
+
+func (idx searchIndex) remove(key mappingKey, mapping model.URLMapping) {
+	for term := range mappingTerms(mapping) {
+		delete(idx[term], key)
+		if len(idx[term]) == 0 {
+			delete(idx, term)
+		}
+	}
+}
+
+// search scores the mappings that match every term. A term matches the
+// words it is a prefix of; whole words count twice as much as prefixes.
+func (idx searchIndex) search(terms []string) map[mappingKey]float64 {
+	var scores map[mappingKey]float64
+	for i, term := range terms {
+		matches := make(map[mappingKey]float64)
+		for word, keys := range idx {
+			if !strings.HasPrefix(word, term) {
+				continue
+			}
+			for key, weight := range keys {
+				if word != term {
+					weight /= 2
+				}
+				matches[key] = max(matches[key], weight)
+			}
+		}
+
+		if i == 0 {
+			scores = matches
+			continue
+		}
+		for key := range scores {
+			if weight, matched := matches[key]; matched {
+				scores[key] += weight
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+	return scores
+}
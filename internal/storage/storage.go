@@ -1,12 +1,121 @@
 package storage
 
-import "github.com/wiredmatt/go_short/internal/model"
+import (
+	"context"
+	"errors"
+	"time"
 
+	"github.com/wiredmatt/go_short/internal/model"
+)
+
+var (
+	// ErrNotFound is returned by stores that report missing codes as errors
+	ErrNotFound = errors.New("code not found")
+	// ErrCodeExists is returned by Save when the code is already taken on the mapping's domain
+	ErrCodeExists = errors.New("code already exists")
+	// ErrClickLimitReached is returned by ConsumeClick once a mapping has used up its max clicks
+	ErrClickLimitReached = errors.New("click limit reached")
+	// ErrRedirectLimitReached is returned by ConsumeClick once the mapping's account has served its monthly redirects
+	ErrRedirectLimitReached = errors.New("monthly redirect limit reached")
+	// ErrActiveLinksLimitReached is returned by SaveWithinLimit once the mapping's account has as many active links as allowed
+	ErrActiveLinksLimitReached = errors.New("active link limit reached")
+	// ErrDomainExists is returned by SaveDomain for names that are already registered
+	ErrDomainExists = errors.New("domain already registered")
+	// ErrDomainInUse is returned by DeleteDomain while mappings are served on the domain
+	ErrDomainInUse = errors.New("domain has mappings")
+	// ErrLastOwner is returned by SaveMember and DeleteMember for changes that would leave an organization without an owner
+	ErrLastOwner = errors.New("organization must keep an owner")
+)
+
+// Store keeps mappings by domain and code, with the empty domain standing for
+// the default one
 type Store interface {
-	Save(mapping model.URLMapping) error
-	Get(code string) (*string, error)
-	IncrementClickCount(code string) error
-	ListByUser(userID string) ([]model.URLMapping, error)
-	Delete(code string) error
+	// Save inserts a new mapping, or returns ErrCodeExists if its code is
+	// taken on its domain. Existing mappings are never replaced.
+	Save(ctx context.Context, mapping model.URLMapping) error
+	// SaveWithinLimit inserts a new mapping like Save, unless its account
+	// already has maxActiveLinks active mappings, as counted by
+	// CountActiveLinks. The count and the insert are a single atomic step,
+	// so concurrent saves cannot go past the limit; ErrActiveLinksLimitReached
+	// is returned instead.
+	SaveWithinLimit(ctx context.Context, mapping model.URLMapping, maxActiveLinks int) error
+	// Get returns the mapping for code on domain unless it has expired or is not active yet
+	Get(ctx context.Context, domain, code string) (*model.URLMapping, error)
+	GetMapping(ctx context.Context, domain, code string) (*model.URLMapping, error)
+	// Update replaces the settings and tags of an existing mapping, found by
+	// its domain and code. Its owner, creation time, click count and metadata
+	// are kept.
+	Update(ctx context.Context, mapping model.URLMapping) error
+	// SaveMetadata sets the metadata of the mapping, or returns ErrNotFound
+	// if it no longer exists or no longer points to original
+	SaveMetadata(ctx context.Context, domain, code, original string, metadata model.Metadata) error
+	// IncrementClickCount counts a click, along with a redirect in the
+	// monthly usage of the mapping's account
+	IncrementClickCount(ctx context.Context, domain, code string) error
+	// ConsumeClick counts a click only if the mapping has clicks left, as a
+	// single atomic step, and returns ErrClickLimitReached otherwise, or
+	// ErrNotFound if there is no such mapping. Like
+	// IncrementClickCount it counts the redirect in the monthly usage. When
+	// maxRedirects is above 0 and the account has already served that many
+	// redirects this month, nothing is counted and ErrRedirectLimitReached is
+	// returned.
+	ConsumeClick(ctx context.Context, domain, code string, maxRedirects int) error
+	// IncrementVariantClickCount counts a click served by the named variant
+	IncrementVariantClickCount(ctx context.Context, domain, code, variant string) error
+	// ListByUser returns the user's personal mappings that pass filter,
+	// leaving out those owned by organizations
+	ListByUser(ctx context.Context, userID string, filter model.MappingFilter) ([]model.URLMapping, error)
+	ListByOrg(ctx context.Context, orgID string, filter model.MappingFilter) ([]model.URLMapping, error)
+	Delete(ctx context.Context, domain, code string) error
+	CountActive(ctx context.Context) (int, error)
+	// SaveUTMTemplate creates the template or replaces the user's template of the same name
+	SaveUTMTemplate(ctx context.Context, template model.UTMTemplate) error
+	GetUTMTemplate(ctx context.Context, userID, name string) (*model.UTMTemplate, error)
+	// ListUTMTemplates returns the user's templates ordered by name
+	ListUTMTemplates(ctx context.Context, userID string) ([]model.UTMTemplate, error)
+	DeleteUTMTemplate(ctx context.Context, userID, name string) error
+	// SaveDomain registers a domain, or returns ErrDomainExists if its name is taken
+	SaveDomain(ctx context.Context, domain model.Domain) error
+	GetDomain(ctx context.Context, name string) (*model.Domain, error)
+	// ListDomains returns the user's domains ordered by name
+	ListDomains(ctx context.Context, userID string) ([]model.Domain, error)
+	// DeleteDomain removes a domain, or returns ErrDomainInUse while it has mappings
+	DeleteDomain(ctx context.Context, name string) error
+	// SaveOrganization creates an organization with owner as its first
+	// member, as a single step
+	SaveOrganization(ctx context.Context, org model.Organization, owner model.Membership) error
+	GetOrganization(ctx context.Context, id string) (*model.Organization, error)
+	// ListMemberships returns the user's memberships ordered by organization ID
+	ListMemberships(ctx context.Context, userID string) ([]model.Membership, error)
+	// SaveMember adds a member or changes their role, keeping when they
+	// joined. Demoting the only owner returns ErrLastOwner; the check and
+	// the change are a single step.
+	SaveMember(ctx context.Context, member model.Membership) error
+	GetMember(ctx context.Context, orgID, userID string) (*model.Membership, error)
+	// ListMembers returns the organization's members ordered by user ID
+	ListMembers(ctx context.Context, orgID string) ([]model.Membership, error)
+	// DeleteMember removes a member. Removing the only owner returns
+	// ErrLastOwner; the check and the removal are a single step.
+	DeleteMember(ctx context.Context, orgID, userID string) error
+	// CountActiveLinks returns how many of the account's mappings have not
+	// expired or used up their max clicks, scheduled ones included
+	CountActiveLinks(ctx context.Context, account model.Account) (int, error)
+	// MonthlyRedirects returns how many redirects the account's mappings
+	// served in the calendar month of month, in UTC
+	MonthlyRedirects(ctx context.Context, account model.Account, month time.Time) (int, error)
+	// SaveQuota creates or replaces the quota of an account
+	SaveQuota(ctx context.Context, quota model.Quota) error
+	GetQuota(ctx context.Context, account model.Account) (*model.Quota, error)
+	// ListTags returns the tags on the account's mappings ordered by tag
+	ListTags(ctx context.Context, account model.Account) ([]model.TagCount, error)
+	// RenameTag replaces from with to on the account's mappings and returns
+	// how many mappings it changed
+	RenameTag(ctx context.Context, account model.Account, from, to string) (int, error)
+	// SearchMappings returns up to limit of the account's mappings matching
+	// every word of query in their code, title, tags or destination, most
+	// relevant first. A limit of 0 returns every match.
+	SearchMappings(ctx context.Context, account model.Account, query string, limit int) ([]model.URLMapping, error)
+	// Ping reports whether the backend is reachable
+	Ping(ctx context.Context) error
 	Close()
 }
@@ -0,0 +1,83 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wiredmatt/go_short/internal/config"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup_Exporters(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	for _, exporter := range []string{"none", "stdout"} {
+		t.Run(exporter, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), config.TelemetryConfig{
+				Exporter:    exporter,
+				Endpoint:    "http://localhost:4318",
+				ServiceName: "go_short_test",
+				SampleRatio: 1,
+			})
+
+			assert.NoError(t, err)
+			assert.NotNil(t, shutdown)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestSetup_OTLPExportsToTracesPath(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var mu sync.Mutex
+	var paths []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	shutdown, err := Setup(context.Background(), config.TelemetryConfig{
+		Exporter:    "otlp",
+		Endpoint:    collector.URL,
+		ServiceName: "go_short_test",
+		SampleRatio: 1,
+	})
+	assert.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "span")
+	span.End()
+	assert.NoError(t, shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/v1/traces"}, paths)
+}
+
+func TestTracesURL(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"http://localhost:4318":              "http://localhost:4318/v1/traces",
+		"http://localhost:4318/":             "http://localhost:4318/v1/traces",
+		"https://collector.example.com/otlp": "https://collector.example.com/otlp/v1/traces",
+	} {
+		got, err := tracesURL(endpoint)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, endpoint)
+	}
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TelemetryConfig{Exporter: "carrier-pigeon"})
+
+	assert.Error(t, err)
+	assert.Nil(t, shutdown)
+}